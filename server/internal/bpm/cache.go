@@ -2,6 +2,7 @@ package bpm
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log/slog"
 	"os"
 )
@@ -16,18 +17,40 @@ func NewCache(db *sql.DB) *Cache {
 	return &Cache{db: db}
 }
 
-// Get retrieves a cached BPM for the given file path and modification time.
-// Returns 0, false if not cached or if the file has been modified since.
+// Get retrieves a cached BPM for the given file path and modification
+// time. Returns 0, false if not cached or if the file has been modified
+// since. If a manual override is recorded (see SetOverride), it takes
+// precedence over the automatically analysed value.
 func (c *Cache) Get(path string, modTime int64) (float64, bool) {
-	var bpm float64
+	var bpmVal float64
+	var overrideBPM sql.NullFloat64
 	err := c.db.QueryRow(
-		`SELECT bpm FROM video_bpm WHERE path = ? AND mod_time = ?`,
+		`SELECT bpm, override_bpm FROM video_bpm WHERE path = ? AND mod_time = ?`,
 		path, modTime,
-	).Scan(&bpm)
+	).Scan(&bpmVal, &overrideBPM)
 	if err != nil {
 		return 0, false
 	}
-	return bpm, true
+	if overrideBPM.Valid {
+		return overrideBPM.Float64, true
+	}
+	return bpmVal, true
+}
+
+// SetOverride records a manually-verified BPM for path, alongside (not
+// replacing) the automatically analysed value already cached for it.
+// source identifies how the override was obtained, e.g. "mic-tap". It's
+// the escape hatch for when the offline analyser locks onto a half/
+// double-time reading: Get returns the override once set, but the
+// original analysis stays in the bpm column in case the override is
+// later cleared.
+func (c *Cache) SetOverride(path string, modTime int64, bpmVal float64, source string) error {
+	_, err := c.db.Exec(
+		`INSERT INTO video_bpm (path, bpm, mod_time, override_bpm, override_source) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET mod_time = excluded.mod_time, override_bpm = excluded.override_bpm, override_source = excluded.override_source`,
+		path, bpmVal, modTime, bpmVal, source,
+	)
+	return err
 }
 
 // Set stores a BPM value for the given file path and modification time.
@@ -40,6 +63,103 @@ func (c *Cache) Set(path string, modTime int64, bpm float64) error {
 	return err
 }
 
+// GetTempo retrieves a cached BPM alongside the tempogram's confidence
+// score and first-beat phase offset. Returns ok=false if not cached or
+// the file has been modified since; confidence/firstBeatSec are 0 if the
+// row predates those columns (i.e. only the plain bpm was ever stored).
+func (c *Cache) GetTempo(path string, modTime int64) (bpmVal, confidence, firstBeatSec float64, ok bool) {
+	var overrideBPM, conf, firstBeat sql.NullFloat64
+	err := c.db.QueryRow(
+		`SELECT bpm, override_bpm, confidence, first_beat_sec FROM video_bpm WHERE path = ? AND mod_time = ?`,
+		path, modTime,
+	).Scan(&bpmVal, &overrideBPM, &conf, &firstBeat)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	if overrideBPM.Valid {
+		bpmVal = overrideBPM.Float64
+	}
+	return bpmVal, conf.Float64, firstBeat.Float64, true
+}
+
+// SetTempo stores a BPM value alongside the tempogram's confidence score
+// and first-beat phase offset for the given file path and modification
+// time.
+func (c *Cache) SetTempo(path string, modTime int64, bpmVal, confidence, firstBeatSec float64) error {
+	_, err := c.db.Exec(
+		`INSERT INTO video_bpm (path, bpm, mod_time, confidence, first_beat_sec) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET bpm = excluded.bpm, mod_time = excluded.mod_time, confidence = excluded.confidence, first_beat_sec = excluded.first_beat_sec`,
+		path, bpmVal, modTime, confidence, firstBeatSec,
+	)
+	return err
+}
+
+// GetAnalysis retrieves a cached full beat analysis for the given file path
+// and modification time. Returns a zero Analysis, false if not cached, if
+// the file has been modified since, or if the row predates the
+// analysis_blob column (i.e. only the scalar bpm was ever stored).
+func (c *Cache) GetAnalysis(path string, modTime int64) (Analysis, bool) {
+	var blob []byte
+	err := c.db.QueryRow(
+		`SELECT analysis_blob FROM video_bpm WHERE path = ? AND mod_time = ?`,
+		path, modTime,
+	).Scan(&blob)
+	if err != nil || blob == nil {
+		return Analysis{}, false
+	}
+	var a Analysis
+	if err := json.Unmarshal(blob, &a); err != nil {
+		slog.Warn("bpm cache: corrupt analysis blob", "path", path, "error", err)
+		return Analysis{}, false
+	}
+	return a, true
+}
+
+// SetAnalysis stores a full beat analysis for the given file path and
+// modification time, alongside the scalar bpm column so callers that only
+// need a tempo number can keep reading it without decoding the blob.
+func (c *Cache) SetAnalysis(path string, modTime int64, a Analysis) error {
+	blob, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec(
+		`INSERT INTO video_bpm (path, bpm, mod_time, analysis_blob) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET bpm = excluded.bpm, mod_time = excluded.mod_time, analysis_blob = excluded.analysis_blob`,
+		path, a.BPM, modTime, blob,
+	)
+	return err
+}
+
+// GetGenre retrieves a cached genre tag for the given file path and
+// modification time. Returns ok=false if not cached, if the file has
+// been modified since, or if the row predates the genre column.
+func (c *Cache) GetGenre(path string, modTime int64) (string, bool) {
+	var genre sql.NullString
+	err := c.db.QueryRow(
+		`SELECT genre FROM video_bpm WHERE path = ? AND mod_time = ?`,
+		path, modTime,
+	).Scan(&genre)
+	if err != nil || !genre.Valid || genre.String == "" {
+		return "", false
+	}
+	return genre.String, true
+}
+
+// SetGenre stores a genre tag alongside whatever BPM data is already
+// cached for path. Unlike the other Set methods it never inserts a new
+// row: a genre resolved before any BPM analysis has run would otherwise
+// leave a placeholder bpm=0 row that GetTempo can't distinguish from a
+// real analysed-but-beatless reading, permanently skipping analysis for
+// that file. Genre just waits for BPM analysis to create the row first.
+func (c *Cache) SetGenre(path string, modTime int64, genre string) error {
+	_, err := c.db.Exec(
+		`UPDATE video_bpm SET mod_time = ?, genre = ? WHERE path = ?`,
+		modTime, genre, path,
+	)
+	return err
+}
+
 // Cleanup removes orphaned cache entries whose files no longer exist on disk.
 func (c *Cache) Cleanup() {
 	rows, err := c.db.Query(`SELECT path FROM video_bpm`)
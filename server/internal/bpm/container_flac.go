@@ -0,0 +1,655 @@
+package bpm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// A pure-Go FLAC decoder covering what's needed for a 30-second BPM
+// sample: STREAMINFO, fixed/LPC subframes with partitioned Rice-coded
+// residuals, and all four stereo decorrelation modes. It does not verify
+// frame or footer CRCs — a corrupt frame just produces a slightly wrong
+// PCM sample, which autocorrelation over 30 seconds shrugs off, and
+// skipping verification keeps the decoder a fraction of libFLAC's size.
+
+// extractPCMFLAC decodes up to ~30 seconds of audio from a native FLAC
+// stream, downmixing to mono float32.
+func extractPCMFLAC(rs io.ReadSeeker) ([]float32, int, error) {
+	if _, err := rs.Seek(4, io.SeekStart); err != nil { // past the "fLaC" magic
+		return nil, 0, err
+	}
+	br := &bitReader{r: bufio.NewReaderSize(rs, 1<<16)}
+
+	sampleRate, channels, bitsPerSample, err := readFLACStreamInfo(br)
+	if err != nil {
+		return nil, 0, fmt.Errorf("flac: %w", err)
+	}
+	if channels < 1 || channels > 8 || bitsPerSample < 4 || bitsPerSample > 32 {
+		return nil, 0, fmt.Errorf("flac: unsupported stream (channels=%d bits=%d)", channels, bitsPerSample)
+	}
+
+	maxSamples := sampleRate * maxSeconds
+	mono := make([]float32, 0, maxSamples)
+
+	for len(mono) < maxSamples {
+		samples, frameBits, err := decodeFLACFrame(br, channels, bitsPerSample)
+		if err != nil {
+			break // EOF, or a frame we couldn't parse — keep what we have
+		}
+		scale := float32(int32(1) << uint(frameBits-1))
+		n := len(samples[0])
+		for i := 0; i < n && len(mono) < maxSamples; i++ {
+			var sum float32
+			for ch := range samples {
+				sum += float32(samples[ch][i]) / scale
+			}
+			mono = append(mono, sum/float32(len(samples)))
+		}
+	}
+
+	if len(mono) == 0 {
+		return nil, 0, fmt.Errorf("flac: no audio frames decoded")
+	}
+	return mono, sampleRate, nil
+}
+
+// readFLACStreamInfo walks metadata blocks until it finds (and decodes)
+// STREAMINFO, skipping every other block type — seek tables, Vorbis
+// comments, cue sheets, pictures — none of which BPM detection needs.
+func readFLACStreamInfo(br *bitReader) (sampleRate, channels, bitsPerSample int, err error) {
+	found := false
+	for {
+		last, e := br.readBits(1)
+		if e != nil {
+			return 0, 0, 0, e
+		}
+		blockType, e := br.readBits(7)
+		if e != nil {
+			return 0, 0, 0, e
+		}
+		length, e := br.readBits(24)
+		if e != nil {
+			return 0, 0, 0, e
+		}
+
+		if blockType == 0 && !found {
+			if _, e := br.readBits(16); e != nil { // min block size
+				return 0, 0, 0, e
+			}
+			if _, e := br.readBits(16); e != nil { // max block size
+				return 0, 0, 0, e
+			}
+			if _, e := br.readBits(24); e != nil { // min frame size
+				return 0, 0, 0, e
+			}
+			if _, e := br.readBits(24); e != nil { // max frame size
+				return 0, 0, 0, e
+			}
+			sr, e := br.readBits(20)
+			if e != nil {
+				return 0, 0, 0, e
+			}
+			ch, e := br.readBits(3)
+			if e != nil {
+				return 0, 0, 0, e
+			}
+			bps, e := br.readBits(5)
+			if e != nil {
+				return 0, 0, 0, e
+			}
+			if _, e := br.readBits(4); e != nil { // total-samples, high bits
+				return 0, 0, 0, e
+			}
+			if _, e := br.readBits(32); e != nil { // total-samples, low bits
+				return 0, 0, 0, e
+			}
+			for i := 0; i < 16; i++ { // MD5 signature
+				if _, e := br.readBits(8); e != nil {
+					return 0, 0, 0, e
+				}
+			}
+			sampleRate, channels, bitsPerSample = int(sr), int(ch)+1, int(bps)+1
+			found = true
+		} else {
+			for i := uint32(0); i < length; i++ {
+				if _, e := br.readBits(8); e != nil {
+					return 0, 0, 0, e
+				}
+			}
+		}
+
+		if last == 1 {
+			break
+		}
+	}
+	if !found {
+		return 0, 0, 0, fmt.Errorf("no STREAMINFO block")
+	}
+	return sampleRate, channels, bitsPerSample, nil
+}
+
+// decodeFLACFrame decodes one frame and returns its samples, one slice
+// per output channel, plus the bit depth they were decoded at (needed to
+// scale to float32 — it can differ from the stream default when a frame
+// header overrides it).
+func decodeFLACFrame(br *bitReader, streamChannels, streamBitsPerSample int) ([][]int32, int, error) {
+	if err := br.syncFrame(); err != nil {
+		return nil, 0, err
+	}
+
+	blockSizeCode, err := br.readBits(4)
+	if err != nil {
+		return nil, 0, err
+	}
+	sampleRateCode, err := br.readBits(4)
+	if err != nil {
+		return nil, 0, err
+	}
+	channelAssignment, err := br.readBits(4)
+	if err != nil {
+		return nil, 0, err
+	}
+	sampleSizeCode, err := br.readBits(3)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := br.readBits(1); err != nil { // reserved
+		return nil, 0, err
+	}
+	if err := br.readUTF8FrameNumber(); err != nil {
+		return nil, 0, err
+	}
+
+	blockSize, err := resolveFLACBlockSize(br, blockSizeCode)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := skipFLACSampleRateCode(br, sampleRateCode); err != nil {
+		return nil, 0, err
+	}
+	if _, err := br.readBits(8); err != nil { // header CRC-8, not verified
+		return nil, 0, err
+	}
+
+	bitsPerSample := flacSampleSizeBits(sampleSizeCode, streamBitsPerSample)
+
+	var channels, mode int // mode: 0 independent, 1 left/side, 2 right/side, 3 mid/side
+	switch {
+	case channelAssignment <= 7:
+		channels, mode = int(channelAssignment)+1, 0
+	case channelAssignment == 8:
+		channels, mode = 2, 1
+	case channelAssignment == 9:
+		channels, mode = 2, 2
+	case channelAssignment == 10:
+		channels, mode = 2, 3
+	default:
+		return nil, 0, fmt.Errorf("flac: reserved channel assignment %d", channelAssignment)
+	}
+	_ = streamChannels // the frame header is authoritative; STREAMINFO is just a default
+
+	samples := make([][]int32, channels)
+	for ch := 0; ch < channels; ch++ {
+		bits := bitsPerSample
+		if (mode == 1 && ch == 1) || (mode == 2 && ch == 0) || (mode == 3 && ch == 1) {
+			bits++ // the "side" channel carries one extra bit of precision
+		}
+		s, err := decodeFLACSubframe(br, blockSize, bits)
+		if err != nil {
+			return nil, 0, err
+		}
+		samples[ch] = s
+	}
+
+	br.align() // frame body is zero-padded to a byte boundary before the footer
+	if _, err := br.readBits(16); err != nil { // footer CRC-16, not verified
+		return nil, 0, err
+	}
+
+	switch mode {
+	case 1: // left/side
+		left, side := samples[0], samples[1]
+		right := make([]int32, len(left))
+		for i := range left {
+			right[i] = left[i] - side[i]
+		}
+		samples[1] = right
+	case 2: // right/side
+		side, right := samples[0], samples[1]
+		left := make([]int32, len(right))
+		for i := range right {
+			left[i] = right[i] + side[i]
+		}
+		samples[0] = left
+	case 3: // mid/side
+		mid, side := samples[0], samples[1]
+		left := make([]int32, len(mid))
+		right := make([]int32, len(mid))
+		for i := range mid {
+			m := (mid[i] << 1) | (side[i] & 1)
+			left[i] = (m + side[i]) >> 1
+			right[i] = (m - side[i]) >> 1
+		}
+		samples[0], samples[1] = left, right
+	}
+
+	return samples, bitsPerSample, nil
+}
+
+// resolveFLACBlockSize decodes the frame header's 4-bit block-size code,
+// reading the extra 8 or 16 bit value it signals for the "read from
+// header" cases.
+func resolveFLACBlockSize(br *bitReader, code uint32) (int, error) {
+	switch {
+	case code == 1:
+		return 192, nil
+	case code >= 2 && code <= 5:
+		return 576 << (code - 2), nil
+	case code == 6:
+		v, err := br.readBits(8)
+		return int(v) + 1, err
+	case code == 7:
+		v, err := br.readBits(16)
+		return int(v) + 1, err
+	case code >= 8:
+		return 256 << (code - 8), nil
+	default:
+		return 0, fmt.Errorf("flac: reserved block size code")
+	}
+}
+
+// skipFLACSampleRateCode discards whatever extra bits the frame header's
+// sample-rate code signals. We always trust STREAMINFO's sample rate, so
+// the decoded value itself is never needed — only its bit width.
+func skipFLACSampleRateCode(br *bitReader, code uint32) error {
+	switch code {
+	case 12:
+		_, err := br.readBits(8)
+		return err
+	case 13, 14:
+		_, err := br.readBits(16)
+		return err
+	case 15:
+		return fmt.Errorf("flac: invalid sample rate code")
+	}
+	return nil
+}
+
+// flacSampleSizeBits maps a frame header's 3-bit sample-size code to a bit
+// depth, falling back to the stream's default (code 0) for the codes that
+// leave it unspecified.
+func flacSampleSizeBits(code uint32, streamDefault int) int {
+	switch code {
+	case 1:
+		return 8
+	case 2:
+		return 12
+	case 4:
+		return 16
+	case 5:
+		return 20
+	case 6:
+		return 24
+	case 7:
+		return 32
+	default:
+		return streamDefault
+	}
+}
+
+// decodeFLACSubframe decodes one channel's subframe — constant, verbatim,
+// fixed-predictor, or LPC — and left-shifts back in any "wasted bits"
+// the encoder stripped before coding.
+func decodeFLACSubframe(br *bitReader, blockSize, bitsPerSample int) ([]int32, error) {
+	if _, err := br.readBits(1); err != nil { // zero padding bit
+		return nil, err
+	}
+	typeBits, err := br.readBits(6)
+	if err != nil {
+		return nil, err
+	}
+	wastedFlag, err := br.readBits(1)
+	if err != nil {
+		return nil, err
+	}
+
+	wasted := 0
+	if wastedFlag == 1 {
+		n, err := br.readUnary()
+		if err != nil {
+			return nil, err
+		}
+		wasted = n + 1
+	}
+	effectiveBits := bitsPerSample - wasted
+
+	var out []int32
+	switch {
+	case typeBits == 0:
+		out, err = decodeFLACConstant(br, blockSize, effectiveBits)
+	case typeBits == 1:
+		out, err = decodeFLACVerbatim(br, blockSize, effectiveBits)
+	case typeBits >= 8 && typeBits <= 12:
+		out, err = decodeFLACFixed(br, blockSize, effectiveBits, int(typeBits-8))
+	case typeBits >= 32:
+		out, err = decodeFLACLPC(br, blockSize, effectiveBits, int(typeBits-31))
+	default:
+		return nil, fmt.Errorf("flac: reserved subframe type %d", typeBits)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= uint(wasted)
+		}
+	}
+	return out, nil
+}
+
+func decodeFLACConstant(br *bitReader, blockSize, bits int) ([]int32, error) {
+	v, err := br.readSigned(bits)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]int32, blockSize)
+	for i := range out {
+		out[i] = v
+	}
+	return out, nil
+}
+
+func decodeFLACVerbatim(br *bitReader, blockSize, bits int) ([]int32, error) {
+	out := make([]int32, blockSize)
+	for i := range out {
+		v, err := br.readSigned(bits)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// decodeFLACFixed reconstructs samples from one of FLAC's five built-in
+// fixed-polynomial predictors (order 0-4) plus the coded residual.
+func decodeFLACFixed(br *bitReader, blockSize, bits, order int) ([]int32, error) {
+	samples := make([]int32, blockSize)
+	for i := 0; i < order; i++ {
+		v, err := br.readSigned(bits)
+		if err != nil {
+			return nil, err
+		}
+		samples[i] = v
+	}
+	residual, err := decodeFLACResidual(br, blockSize, order)
+	if err != nil {
+		return nil, err
+	}
+	for i := order; i < blockSize; i++ {
+		var predict int32
+		switch order {
+		case 1:
+			predict = samples[i-1]
+		case 2:
+			predict = 2*samples[i-1] - samples[i-2]
+		case 3:
+			predict = 3*samples[i-1] - 3*samples[i-2] + samples[i-3]
+		case 4:
+			predict = 4*samples[i-1] - 6*samples[i-2] + 4*samples[i-3] - samples[i-4]
+		}
+		samples[i] = predict + residual[i-order]
+	}
+	return samples, nil
+}
+
+// decodeFLACLPC reconstructs samples from an encoder-fitted linear
+// predictor: quantized coefficients plus a shift, applied over the
+// previous `order` samples.
+func decodeFLACLPC(br *bitReader, blockSize, bits, order int) ([]int32, error) {
+	samples := make([]int32, blockSize)
+	for i := 0; i < order; i++ {
+		v, err := br.readSigned(bits)
+		if err != nil {
+			return nil, err
+		}
+		samples[i] = v
+	}
+
+	precisionBits, err := br.readBits(4)
+	if err != nil {
+		return nil, err
+	}
+	precision := int(precisionBits) + 1
+	shift, err := br.readBits(5)
+	if err != nil {
+		return nil, err
+	}
+
+	coeffs := make([]int32, order)
+	for i := range coeffs {
+		c, err := br.readSigned(precision)
+		if err != nil {
+			return nil, err
+		}
+		coeffs[i] = c
+	}
+
+	residual, err := decodeFLACResidual(br, blockSize, order)
+	if err != nil {
+		return nil, err
+	}
+	for i := order; i < blockSize; i++ {
+		var sum int64
+		for j, c := range coeffs {
+			sum += int64(c) * int64(samples[i-1-j])
+		}
+		samples[i] = int32(sum>>shift) + residual[i-order]
+	}
+	return samples, nil
+}
+
+// decodeFLACResidual decodes a partitioned-Rice-coded residual of
+// blockSize-predictorOrder values: a 4-bit partition order splits the
+// block into 2^order equal partitions (the first shortened by
+// predictorOrder, since warmup samples aren't coded), each with its own
+// Rice parameter — or, for the rare incompressible partition, a raw
+// bit width and unencoded samples.
+func decodeFLACResidual(br *bitReader, blockSize, predictorOrder int) ([]int32, error) {
+	method, err := br.readBits(2)
+	if err != nil {
+		return nil, err
+	}
+	if method > 1 {
+		return nil, fmt.Errorf("flac: reserved residual coding method %d", method)
+	}
+	paramBits, escapeVal := 4, uint32(0xF)
+	if method == 1 {
+		paramBits, escapeVal = 5, 0x1F
+	}
+
+	partitionOrder, err := br.readBits(4)
+	if err != nil {
+		return nil, err
+	}
+	numPartitions := 1 << partitionOrder
+	if blockSize%numPartitions != 0 || (blockSize>>partitionOrder) <= predictorOrder {
+		return nil, fmt.Errorf("flac: invalid partition order %d for block size %d", partitionOrder, blockSize)
+	}
+	partitionSamples := blockSize >> partitionOrder
+
+	residual := make([]int32, blockSize-predictorOrder)
+	idx := 0
+	for p := 0; p < numPartitions; p++ {
+		n := partitionSamples
+		if p == 0 {
+			n -= predictorOrder
+		}
+		riceParam, err := br.readBits(paramBits)
+		if err != nil {
+			return nil, err
+		}
+		if riceParam == escapeVal {
+			rawBits, err := br.readBits(5)
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < n; i++ {
+				v, err := br.readSigned(int(rawBits))
+				if err != nil {
+					return nil, err
+				}
+				residual[idx] = v
+				idx++
+			}
+			continue
+		}
+		for i := 0; i < n; i++ {
+			q, err := br.readUnary()
+			if err != nil {
+				return nil, err
+			}
+			r, err := br.readBits(int(riceParam))
+			if err != nil {
+				return nil, err
+			}
+			folded := (uint32(q) << riceParam) | r
+			if folded&1 != 0 {
+				residual[idx] = -int32((folded + 1) >> 1)
+			} else {
+				residual[idx] = int32(folded >> 1)
+			}
+			idx++
+		}
+	}
+	return residual, nil
+}
+
+// ── Bit-level reader ─────────────────────────────────────
+
+// bitReader reads big-endian, MSB-first bit fields — the packing FLAC
+// frames use — on top of a byte-oriented bufio.Reader.
+type bitReader struct {
+	r      *bufio.Reader
+	bitBuf byte
+	nbits  uint
+}
+
+// readBits reads n (0-32) bits and returns them right-justified.
+func (br *bitReader) readBits(n int) (uint32, error) {
+	var v uint32
+	for n > 0 {
+		if br.nbits == 0 {
+			b, err := br.r.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			br.bitBuf = b
+			br.nbits = 8
+		}
+		take := n
+		if take > int(br.nbits) {
+			take = int(br.nbits)
+		}
+		shift := int(br.nbits) - take
+		bits := (br.bitBuf >> uint(shift)) & byte((1<<uint(take))-1)
+		v = (v << uint(take)) | uint32(bits)
+		br.nbits -= uint(take)
+		n -= take
+	}
+	return v, nil
+}
+
+// readSigned reads an n-bit two's-complement integer.
+func (br *bitReader) readSigned(n int) (int32, error) {
+	v, err := br.readBits(n)
+	if err != nil {
+		return 0, err
+	}
+	if v&(1<<uint(n-1)) != 0 {
+		return int32(v) - (1 << uint(n)), nil
+	}
+	return int32(v), nil
+}
+
+// readUnary counts zero bits up to (and consuming) the terminating 1 bit
+// — the quotient half of Rice coding.
+func (br *bitReader) readUnary() (int, error) {
+	n := 0
+	for {
+		b, err := br.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			return n, nil
+		}
+		n++
+	}
+}
+
+// align discards any bits left in the current partially-consumed byte.
+func (br *bitReader) align() {
+	br.nbits = 0
+}
+
+// syncFrame scans forward for a frame's 14-bit sync code (0xFF followed
+// by a byte whose top six bits are 111110), byte-aligned since anywhere
+// we'd resync from — skipped metadata, the footer of a valid frame, a
+// frame we gave up on — is itself byte-aligned.
+func (br *bitReader) syncFrame() error {
+	br.align()
+	for {
+		b0, err := br.readBits(8)
+		if err != nil {
+			return err
+		}
+		if b0 != 0xFF {
+			continue
+		}
+		b1, err := br.readBits(8)
+		if err != nil {
+			return err
+		}
+		if b1&0xFC == 0xF8 {
+			return nil
+		}
+	}
+}
+
+// readUTF8FrameNumber consumes the frame header's UTF-8-coded frame or
+// sample number field without decoding its value — the decoder only
+// cares about walking past it to the block-size field that follows.
+func (br *bitReader) readUTF8FrameNumber() error {
+	first, err := br.readBits(8)
+	if err != nil {
+		return err
+	}
+	var extra int
+	switch {
+	case first&0x80 == 0x00:
+		extra = 0
+	case first&0xE0 == 0xC0:
+		extra = 1
+	case first&0xF0 == 0xE0:
+		extra = 2
+	case first&0xF8 == 0xF0:
+		extra = 3
+	case first&0xFC == 0xF8:
+		extra = 4
+	case first&0xFE == 0xFC:
+		extra = 5
+	case first == 0xFE:
+		extra = 6
+	default:
+		return fmt.Errorf("flac: invalid UTF-8 coded frame number")
+	}
+	for i := 0; i < extra; i++ {
+		if _, err := br.readBits(8); err != nil {
+			return err
+		}
+	}
+	return nil
+}
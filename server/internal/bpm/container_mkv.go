@@ -0,0 +1,514 @@
+package bpm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+
+	concentus "github.com/lostromb/concentus/go/opus"
+	aacdecoder "github.com/skrashevich/go-aac/pkg/decoder"
+)
+
+// A minimal pure-Go Matroska/WebM demuxer — just enough EBML parsing to
+// find the first audio track's CodecID and walk Cluster/SimpleBlock
+// elements for that track's payload, then hand the raw packets to the
+// same Opus/AAC decoders the MP4 path uses. It intentionally doesn't
+// handle block lacing, unknown-size elements mid-file, or seeking —
+// VDJ's own exports and ffmpeg-remuxed files don't need any of that, and
+// a block we can't parse is simply skipped rather than guessed at.
+const (
+	ebmlIDHeader  = 0x1A45DFA3
+	ebmlIDSegment = 0x18538067
+
+	ebmlIDTracks      = 0x1654AE6B
+	ebmlIDTrackEntry  = 0xAE
+	ebmlIDTrackNumber = 0xD7
+	ebmlIDTrackType   = 0x83
+	ebmlIDCodecID     = 0x86
+	ebmlIDCodecPriv   = 0x63A2
+	ebmlIDAudio       = 0xE1
+	ebmlIDSampleFreq  = 0xB5
+	ebmlIDChannels    = 0x9F
+	ebmlIDBitDepth    = 0x6264
+
+	ebmlIDCluster     = 0x1F43B675
+	ebmlIDSimpleBlock = 0xA3
+	ebmlIDBlockGroup  = 0xA0
+	ebmlIDBlock       = 0xA1
+
+	mkvTrackTypeAudio = 2
+)
+
+type mkvTrack struct {
+	number     uint64
+	codecID    string
+	codecPriv  []byte
+	sampleRate int
+	channels   int
+	bitDepth   int
+}
+
+// extractPCMMatroska demuxes a Matroska/WebM file, decodes up to ~30
+// seconds of its first audio track, and returns mono float32 PCM.
+func extractPCMMatroska(rs io.ReadSeeker) ([]float32, int, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	br := bufio.NewReaderSize(rs, 1<<16)
+
+	id, _, err := readVint(br, true)
+	if err != nil || id != ebmlIDHeader {
+		return nil, 0, fmt.Errorf("mkv: not an EBML stream")
+	}
+	headerSize, _, err := readVint(br, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := io.CopyN(io.Discard, br, int64(headerSize)); err != nil {
+		return nil, 0, fmt.Errorf("mkv: skip EBML header: %w", err)
+	}
+
+	id, _, err = readVint(br, true)
+	if err != nil || id != ebmlIDSegment {
+		return nil, 0, fmt.Errorf("mkv: expected Segment element")
+	}
+	segSize, segLen, err := readVint(br, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	if isUnknownSize(segSize, segLen) {
+		segSize = math.MaxInt64
+	}
+
+	var track *mkvTrack
+	var packets [][]byte
+	const maxPackets = 4000 // generous cap, trimmed to maxSeconds worth at decode time
+
+	err = forEachChild(br, segSize, func(id uint64, sub *io.LimitedReader) error {
+		switch id {
+		case ebmlIDTracks:
+			if t, terr := parseTracks(sub); terr == nil && t != nil && track == nil {
+				track = t
+			}
+		case ebmlIDCluster:
+			if track == nil || len(packets) >= maxPackets {
+				return nil
+			}
+			packets = append(packets, parseClusterBlocks(sub, track.number, maxPackets-len(packets))...)
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return nil, 0, fmt.Errorf("mkv: parse segment: %w", err)
+	}
+
+	if track == nil {
+		return nil, 0, fmt.Errorf("mkv: no audio track found")
+	}
+	if len(packets) == 0 {
+		return nil, 0, fmt.Errorf("mkv: no audio packets found for track %d", track.number)
+	}
+
+	sampleRate := track.sampleRate
+	if sampleRate == 0 {
+		sampleRate = 48000
+	}
+	maxSamples := sampleRate * maxSeconds
+
+	switch track.codecID {
+	case "A_OPUS":
+		return decodeOpusRawPackets(packets, sampleRate)
+	case "A_AAC", "A_AAC/MPEG4/LC", "A_AAC/MPEG2/LC", "A_AAC/MPEG4/MAIN":
+		return decodeAACRawPackets(packets, track.codecPriv, sampleRate)
+	case "A_PCM/INT/LIT", "A_PCM/INT/BIG":
+		return decodeMKVPCMPackets(packets, track.channels, track.bitDepth, track.codecID == "A_PCM/INT/BIG", sampleRate, maxSamples)
+	case "A_FLAC":
+		return decodeMKVFLACPackets(packets, track.codecPriv)
+	case "A_VORBIS":
+		// No pure-Go Vorbis decoder exists in this module's dependency
+		// set (unlike Opus/AAC/FLAC above) — Vorbis's MDCT+codebook
+		// bitstream is a decoder of its own scope, not something to
+		// bolt on inside this fix. Tracked as follow-up work rather
+		// than silently reporting BPM=0; ErrCodecNotImplemented lets
+		// callers distinguish "we recognised this but haven't written
+		// the decoder yet" from a genuinely malformed/unrecognised file.
+		return nil, 0, fmt.Errorf("mkv: %w: %q", ErrCodecNotImplemented, track.codecID)
+	default:
+		return nil, 0, fmt.Errorf("mkv: unsupported codec %q", track.codecID)
+	}
+}
+
+// decodeOpusRawPackets decodes raw Opus packets (no Ogg/MP4 framing, just
+// the payload Matroska's SimpleBlock handed us) to mono float32 PCM,
+// using the same Concentus decoder as the MP4 path.
+func decodeOpusRawPackets(packets [][]byte, sampleRate int) ([]float32, int, error) {
+	// Concentus requires one of: 8000, 12000, 16000, 24000, 48000
+	decoderRate := sampleRate
+	if decoderRate != 8000 && decoderRate != 12000 && decoderRate != 16000 &&
+		decoderRate != 24000 && decoderRate != 48000 {
+		decoderRate = 48000 // safe default for Opus
+	}
+
+	dec, err := concentus.NewOpusDecoder(decoderRate, 2)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create opus decoder: %w", err)
+	}
+
+	maxSamples := decoderRate * maxSeconds
+	mono := make([]float32, 0, maxSamples)
+	pcm16 := make([]int16, 5760*2)
+
+	for _, pkt := range packets {
+		if len(mono) >= maxSamples {
+			break
+		}
+		if len(pkt) <= 3 {
+			continue
+		}
+		nSamples, err := dec.Decode(pkt, 0, len(pkt), pcm16, 0, 5760, false)
+		if err != nil {
+			continue
+		}
+		const channels = 2
+		for i := 0; i < nSamples; i++ {
+			var sum float32
+			for ch := 0; ch < channels; ch++ {
+				sum += float32(pcm16[i*channels+ch]) / 32768.0
+			}
+			mono = append(mono, sum/float32(channels))
+		}
+	}
+
+	return mono, decoderRate, nil
+}
+
+// decodeAACRawPackets decodes raw AAC frames (Matroska carries them
+// without ADTS framing, same as MP4) using asc — the track's CodecPrivate
+// element, which for A_AAC is exactly an AudioSpecificConfig.
+func decodeAACRawPackets(packets [][]byte, asc []byte, sampleRate int) ([]float32, int, error) {
+	if len(asc) < 2 {
+		return nil, 0, fmt.Errorf("mkv: missing AAC AudioSpecificConfig")
+	}
+
+	dec := aacdecoder.New()
+	if err := dec.SetASC(asc); err != nil {
+		return nil, 0, fmt.Errorf("set ASC: %w", err)
+	}
+	if dec.Config.SampleRate > 0 {
+		sampleRate = dec.Config.SampleRate
+	}
+	channels := dec.Config.ChanConfig
+	if channels < 1 {
+		channels = 1
+	}
+
+	maxSamples := sampleRate * maxSeconds
+	mono := make([]float32, 0, maxSamples)
+
+	for _, raw := range packets {
+		if len(mono) >= maxSamples {
+			break
+		}
+		pcm, err := dec.DecodeFrame(raw)
+		if err != nil {
+			slog.Debug("bpm: skip AAC frame", "error", err)
+			continue
+		}
+		frameLen := len(pcm) / channels
+		for i := 0; i < frameLen; i++ {
+			var sum float32
+			for ch := 0; ch < channels; ch++ {
+				sum += pcm[i*channels+ch]
+			}
+			mono = append(mono, sum/float32(channels))
+		}
+	}
+
+	return mono, sampleRate, nil
+}
+
+// decodeMKVPCMPackets decodes raw linear-PCM packets (A_PCM/INT/LIT or
+// A_PCM/INT/BIG) straight from the Matroska Audio track's declared
+// channel count and bit depth — there's no entropy coding to undo.
+func decodeMKVPCMPackets(packets [][]byte, channels, bitDepth int, bigEndian bool, sampleRate, maxSamples int) ([]float32, int, error) {
+	if channels < 1 {
+		channels = 1
+	}
+	if bitDepth == 0 {
+		bitDepth = 16
+	}
+	bytesPerSample := bitDepth / 8
+	if bytesPerSample == 0 {
+		return nil, 0, fmt.Errorf("mkv: unsupported PCM bit depth %d", bitDepth)
+	}
+	frameSize := bytesPerSample * channels
+
+	mono := make([]float32, 0, maxSamples)
+	for _, pkt := range packets {
+		if len(mono) >= maxSamples {
+			break
+		}
+		off := 0
+		for remaining := len(pkt); remaining >= frameSize && len(mono) < maxSamples; remaining -= frameSize {
+			var sum float32
+			for ch := 0; ch < channels; ch++ {
+				start := off + ch*bytesPerSample
+				sum += decodePCMSample(pkt[start:start+bytesPerSample], bigEndian, false)
+			}
+			mono = append(mono, sum/float32(channels))
+			off += frameSize
+		}
+	}
+
+	return mono, sampleRate, nil
+}
+
+// decodeMKVFLACPackets decodes A_FLAC packets: per the Matroska spec,
+// CodecPrivate holds the native FLAC header (the "fLaC" magic followed
+// by STREAMINFO and any other metadata blocks), and each SimpleBlock
+// payload is one bare FLAC frame with no further framing — so this
+// reuses the same frame decoder extractPCMFLAC uses for standalone
+// .flac files, just fed from Matroska's packets instead of a file
+// stream.
+func decodeMKVFLACPackets(packets [][]byte, codecPriv []byte) ([]float32, int, error) {
+	if len(codecPriv) < 4 || string(codecPriv[:4]) != "fLaC" {
+		return nil, 0, fmt.Errorf("mkv: A_FLAC track missing fLaC codec private data")
+	}
+	headerBR := &bitReader{r: bufio.NewReader(bytes.NewReader(codecPriv[4:]))}
+	sampleRate, channels, bitsPerSample, err := readFLACStreamInfo(headerBR)
+	if err != nil {
+		return nil, 0, fmt.Errorf("mkv: flac: %w", err)
+	}
+	if channels < 1 || channels > 8 || bitsPerSample < 4 || bitsPerSample > 32 {
+		return nil, 0, fmt.Errorf("mkv: flac: unsupported stream (channels=%d bits=%d)", channels, bitsPerSample)
+	}
+
+	maxSamples := sampleRate * maxSeconds
+	mono := make([]float32, 0, maxSamples)
+
+	for _, pkt := range packets {
+		if len(mono) >= maxSamples {
+			break
+		}
+		frameBR := &bitReader{r: bufio.NewReader(bytes.NewReader(pkt))}
+		samples, frameBits, err := decodeFLACFrame(frameBR, channels, bitsPerSample)
+		if err != nil {
+			continue // a frame we couldn't parse — keep what we have
+		}
+		scale := float32(int32(1) << uint(frameBits-1))
+		n := len(samples[0])
+		for i := 0; i < n && len(mono) < maxSamples; i++ {
+			var sum float32
+			for ch := range samples {
+				sum += float32(samples[ch][i]) / scale
+			}
+			mono = append(mono, sum/float32(len(samples)))
+		}
+	}
+
+	if len(mono) == 0 {
+		return nil, 0, fmt.Errorf("mkv: flac: no audio frames decoded")
+	}
+	return mono, sampleRate, nil
+}
+
+// parseTracks walks a Tracks element's TrackEntry children and returns
+// the first audio track found.
+func parseTracks(sub *io.LimitedReader) (*mkvTrack, error) {
+	br := bufio.NewReader(sub)
+	var found *mkvTrack
+	err := forEachChild(br, uint64(sub.N), func(id uint64, entry *io.LimitedReader) error {
+		if id != ebmlIDTrackEntry || found != nil {
+			return nil
+		}
+		t := &mkvTrack{channels: 1}
+		isAudio := false
+		ebr := bufio.NewReader(entry)
+		_ = forEachChild(ebr, uint64(entry.N), func(id2 uint64, f *io.LimitedReader) error {
+			switch id2 {
+			case ebmlIDTrackNumber:
+				t.number = readUintElement(f)
+			case ebmlIDTrackType:
+				isAudio = readUintElement(f) == mkvTrackTypeAudio
+			case ebmlIDCodecID:
+				b, _ := io.ReadAll(f)
+				t.codecID = string(b)
+			case ebmlIDCodecPriv:
+				b, _ := io.ReadAll(f)
+				t.codecPriv = b
+			case ebmlIDAudio:
+				abr := bufio.NewReader(f)
+				_ = forEachChild(abr, uint64(f.N), func(id3 uint64, af *io.LimitedReader) error {
+					switch id3 {
+					case ebmlIDSampleFreq:
+						t.sampleRate = int(readFloatElement(af))
+					case ebmlIDChannels:
+						t.channels = int(readUintElement(af))
+					case ebmlIDBitDepth:
+						t.bitDepth = int(readUintElement(af))
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if isAudio {
+			found = t
+		}
+		return nil
+	})
+	return found, err
+}
+
+// parseClusterBlocks extracts up to limit raw frame payloads belonging to
+// trackNumber from a Cluster's SimpleBlock/BlockGroup children.
+func parseClusterBlocks(sub *io.LimitedReader, trackNumber uint64, limit int) [][]byte {
+	var out [][]byte
+	br := bufio.NewReader(sub)
+	_ = forEachChild(br, uint64(sub.N), func(id uint64, blk *io.LimitedReader) error {
+		if len(out) >= limit {
+			return nil
+		}
+		switch id {
+		case ebmlIDSimpleBlock:
+			if pkt, ok := readBlockPayload(blk, trackNumber); ok {
+				out = append(out, pkt)
+			}
+		case ebmlIDBlockGroup:
+			gbr := bufio.NewReader(blk)
+			_ = forEachChild(gbr, uint64(blk.N), func(id2 uint64, blk2 *io.LimitedReader) error {
+				if id2 == ebmlIDBlock {
+					if pkt, ok := readBlockPayload(blk2, trackNumber); ok {
+						out = append(out, pkt)
+					}
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return out
+}
+
+// readBlockPayload reads a (Simple)Block's track number + timecode +
+// flags header and returns its frame data if it belongs to trackNumber.
+// Laced frames (multiple samples packed into one block) are skipped —
+// unwinding them isn't needed for a 30-second BPM sample.
+func readBlockPayload(r *io.LimitedReader, trackNumber uint64) ([]byte, bool) {
+	br := bufio.NewReader(r)
+	num, _, err := readVint(br, false)
+	if err != nil || num != trackNumber {
+		return nil, false
+	}
+	var hdr [3]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, false
+	}
+	if hdr[2]&0x06 != 0 {
+		return nil, false // laced — not handled
+	}
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func readUintElement(r *io.LimitedReader) uint64 {
+	b, _ := io.ReadAll(r)
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func readFloatElement(r *io.LimitedReader) float64 {
+	b, _ := io.ReadAll(r)
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// ── EBML primitives ─────────────────────────────────────
+
+// readVint reads an EBML variable-length integer. Element IDs keep their
+// length-marker bits (keepMarker=true); sizes have the marker stripped.
+func readVint(r io.ByteReader, keepMarker bool) (value uint64, length int, err error) {
+	b0, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	if b0 == 0 {
+		return 0, 0, fmt.Errorf("mkv: invalid vint (leading byte 0)")
+	}
+	mask := byte(0x80)
+	length = 1
+	for mask != 0 && b0&mask == 0 {
+		mask >>= 1
+		length++
+	}
+	if keepMarker {
+		value = uint64(b0)
+	} else {
+		value = uint64(b0 &^ mask)
+	}
+	for i := 1; i < length; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		value = value<<8 | uint64(b)
+	}
+	return value, length, nil
+}
+
+// isUnknownSize reports whether a size vint's value bits are all 1s, the
+// EBML convention for "unknown size" (common for live-streamed Segments).
+func isUnknownSize(value uint64, length int) bool {
+	return value == (uint64(1)<<(7*length))-1
+}
+
+// forEachChild walks parentSize bytes of r as a sequence of EBML
+// elements, calling fn with each one's ID and a reader bounded to its
+// size. fn may read as much or as little of the element as it likes —
+// any unread remainder is discarded before moving to the next sibling.
+func forEachChild(r *bufio.Reader, parentSize uint64, fn func(id uint64, sub *io.LimitedReader) error) error {
+	var consumed uint64
+	for consumed < parentSize {
+		id, idLen, err := readVint(r, true)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		sz, szLen, err := readVint(r, false)
+		if err != nil {
+			return err
+		}
+		if isUnknownSize(sz, szLen) {
+			sz = parentSize - consumed - uint64(idLen) - uint64(szLen)
+		}
+		consumed += uint64(idLen) + uint64(szLen) + sz
+
+		sub := &io.LimitedReader{R: r, N: int64(sz)}
+		if err := fn(id, sub); err != nil {
+			return err
+		}
+		if sub.N > 0 {
+			if _, err := io.CopyN(io.Discard, r, sub.N); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
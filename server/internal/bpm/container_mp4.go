@@ -0,0 +1,551 @@
+package bpm
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	gomp4 "github.com/abema/go-mp4"
+	concentus "github.com/lostromb/concentus/go/opus"
+	aacdecoder "github.com/skrashevich/go-aac/pkg/decoder"
+)
+
+// ── Audio codec detection ───────────────────────────────
+
+// audioCodec identifies the audio coding format inside the MP4.
+type audioCodec int
+
+const (
+	codecUnknown audioCodec = iota
+	codecAAC
+	codecOpus
+	codecLPCM // sowt/twos/in24/in32/fl32/fl64/ipcm — uncompressed
+	codecG711 // ulaw/alaw — companded 8-bit telephony PCM
+)
+
+// lpcmBoxTypes are the QuickTime/ISO "sample format" FourCCs we decode as
+// uncompressed audio. go-mp4 only ships a registered AudioSampleEntry
+// definition for "ipcm" (box_types_iso23001_5.go); init() below registers
+// "sowt" too so ReadBoxStructure/ExtractBoxesWithPayload can decode its
+// channel count / sample size the same way.
+//
+// "twos"/"in24"/"in32"/"fl32"/"fl64" are deliberately not handled here:
+// their endianness depends on a sibling 'enda'/'wave' atom this package
+// doesn't parse, and guessing wrong would silently produce garbage PCM
+// rather than a clear error. sowt (little-endian int) and ipcm (explicit
+// pcmC flags) cover the formats VDJ-exported MP4s actually use.
+var lpcmBoxTypes = []gomp4.BoxType{
+	gomp4.StrToBoxType("sowt"),
+	gomp4.BoxTypeIpcm(),
+}
+
+var g711BoxTypes = []gomp4.BoxType{
+	gomp4.StrToBoxType("ulaw"),
+	gomp4.StrToBoxType("alaw"),
+}
+
+func init() {
+	for _, bt := range lpcmBoxTypes {
+		if bt != gomp4.BoxTypeIpcm() { // ipcm is already registered upstream
+			gomp4.AddAnyTypeBoxDef(&gomp4.AudioSampleEntry{}, bt)
+		}
+	}
+	for _, bt := range g711BoxTypes {
+		gomp4.AddAnyTypeBoxDef(&gomp4.AudioSampleEntry{}, bt)
+	}
+}
+
+// detectAudioCodec walks the MP4 box tree to see which audio sample
+// description the stsd uses. go-mp4's Probe only tags mp4a as CodecMP4A
+// and leaves everything else as CodecUnknown, so we look at the actual
+// stsd children ourselves.
+func detectAudioCodec(rs io.ReadSeeker) audioCodec {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return codecUnknown
+	}
+
+	codec := codecUnknown
+	_, _ = gomp4.ReadBoxStructure(rs, func(h *gomp4.ReadHandle) (interface{}, error) {
+		if codec != codecUnknown {
+			return nil, nil // already found
+		}
+		switch h.BoxInfo.Type {
+		case gomp4.BoxTypeMp4a():
+			codec = codecAAC
+			return nil, nil
+		case gomp4.BoxTypeOpus():
+			codec = codecOpus
+			return nil, nil
+		case gomp4.StrToBoxType("ulaw"), gomp4.StrToBoxType("alaw"):
+			codec = codecG711
+			return nil, nil
+		case gomp4.StrToBoxType("sowt"), gomp4.BoxTypeIpcm():
+			codec = codecLPCM
+			return nil, nil
+		case gomp4.BoxTypeMoov(), gomp4.BoxTypeTrak(), gomp4.BoxTypeMdia(),
+			gomp4.BoxTypeMinf(), gomp4.BoxTypeStbl(), gomp4.BoxTypeStsd():
+			// Only expand known container boxes — never mdat (raw media data).
+			_, _ = h.Expand()
+		}
+		return nil, nil
+	})
+	return codec
+}
+
+// ── MP4 → PCM extraction ────────────────────────────────
+
+// extractPCMMP4 parses an MP4 file, detects the audio codec, decodes up
+// to ~30 seconds of audio, and returns mono float32 PCM + sample rate.
+func extractPCMMP4(rs io.ReadSeeker) ([]float32, int, error) {
+	// Probe the MP4 structure
+	info, err := gomp4.Probe(rs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("mp4 probe: %w", err)
+	}
+
+	// Detect which audio codec is used
+	codec := detectAudioCodec(rs)
+
+	// Find the audio track
+	audioTrack, err := findAudioTrack(info, codec)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sampleRate := int(audioTrack.Timescale)
+
+	// Route to the appropriate decoder
+	switch codec {
+	case codecAAC:
+		return decodeAAC(rs, audioTrack, sampleRate)
+	case codecOpus:
+		return decodeOpus(rs, audioTrack, sampleRate)
+	case codecLPCM:
+		return decodeMP4LPCM(rs, audioTrack, sampleRate)
+	case codecG711:
+		return decodeMP4G711(rs, audioTrack, sampleRate)
+	default:
+		return nil, 0, fmt.Errorf("unsupported audio codec")
+	}
+}
+
+// findAudioTrack picks the best audio track from the probe results.
+func findAudioTrack(info *gomp4.ProbeInfo, codec audioCodec) (*gomp4.Track, error) {
+	// Strategy 1: if codec is AAC, look for CodecMP4A first
+	if codec == codecAAC {
+		for _, t := range info.Tracks {
+			if t.Codec == gomp4.CodecMP4A {
+				return t, nil
+			}
+		}
+	}
+
+	// Strategy 2: pick any non-video track with samples
+	for _, t := range info.Tracks {
+		if t.Codec == gomp4.CodecAVC1 {
+			continue
+		}
+		if len(t.Samples) == 0 || len(t.Chunks) == 0 {
+			continue
+		}
+		// Audio timescales are standard sample rates; video uses 600/24000/etc.
+		if isAudioTimescale(t.Timescale) {
+			return t, nil
+		}
+	}
+
+	trackInfo := make([]string, 0, len(info.Tracks))
+	for _, t := range info.Tracks {
+		trackInfo = append(trackInfo, fmt.Sprintf(
+			"id=%d codec=%d ts=%d samples=%d",
+			t.TrackID, t.Codec, t.Timescale, len(t.Samples),
+		))
+	}
+	return nil, fmt.Errorf("no audio track found (%d tracks: %v)", len(info.Tracks), trackInfo)
+}
+
+// isAudioTimescale returns true if the timescale matches a standard audio
+// sample rate (8 kHz – 96 kHz).
+func isAudioTimescale(ts uint32) bool {
+	switch ts {
+	case 8000, 11025, 16000, 22050, 32000, 44100, 48000, 88200, 96000:
+		return true
+	}
+	return false
+}
+
+// ── AAC decoding ────────────────────────────────────────
+
+func decodeAAC(rs io.ReadSeeker, track *gomp4.Track, sampleRate int) ([]float32, int, error) {
+	asc, err := getAudioSpecificConfig(rs)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get AudioSpecificConfig: %w", err)
+	}
+
+	dec := aacdecoder.New()
+	if err := dec.SetASC(asc); err != nil {
+		return nil, 0, fmt.Errorf("set ASC: %w", err)
+	}
+
+	if dec.Config.SampleRate > 0 {
+		sampleRate = dec.Config.SampleRate
+	}
+
+	maxSamples := sampleRate * maxSeconds
+	channels := dec.Config.ChanConfig
+	if channels < 1 {
+		channels = 1
+	}
+
+	// Limit frame count: AAC produces ~1024 PCM samples per frame.
+	frameLimit := (maxSamples/1024 + 1) * 2
+	samples := buildSampleLocations(track, frameLimit)
+
+	// Pre-allocate mono and reuse a single raw buffer.
+	mono := make([]float32, 0, maxSamples)
+	var maxRawSize uint32
+	for _, loc := range samples {
+		if loc.size > maxRawSize {
+			maxRawSize = loc.size
+		}
+	}
+	rawBuf := make([]byte, maxRawSize)
+
+	for _, loc := range samples {
+		if len(mono) >= maxSamples {
+			break
+		}
+		if _, err := rs.Seek(int64(loc.offset), io.SeekStart); err != nil {
+			continue
+		}
+		raw := rawBuf[:loc.size]
+		if _, err := io.ReadFull(rs, raw); err != nil {
+			continue
+		}
+		pcm, err := dec.DecodeFrame(raw)
+		if err != nil {
+			slog.Debug("bpm: skip AAC frame", "error", err)
+			continue
+		}
+		frameLen := len(pcm) / channels
+		for i := 0; i < frameLen; i++ {
+			var sum float32
+			for ch := 0; ch < channels; ch++ {
+				sum += pcm[i*channels+ch]
+			}
+			mono = append(mono, sum/float32(channels))
+		}
+	}
+
+	return mono, sampleRate, nil
+}
+
+// getAudioSpecificConfig searches the MP4 for an esds descriptor containing
+// the AudioSpecificConfig bytes needed by the AAC decoder.
+func getAudioSpecificConfig(rs io.ReadSeeker) ([]byte, error) {
+	paths := []gomp4.BoxPath{
+		{gomp4.BoxTypeMoov(), gomp4.BoxTypeTrak(), gomp4.BoxTypeMdia(), gomp4.BoxTypeMinf(), gomp4.BoxTypeStbl(), gomp4.BoxTypeStsd(), gomp4.BoxTypeMp4a(), gomp4.BoxTypeEsds()},
+		{gomp4.BoxTypeMoov(), gomp4.BoxTypeTrak(), gomp4.BoxTypeMdia(), gomp4.BoxTypeMinf(), gomp4.BoxTypeStbl(), gomp4.BoxTypeStsd(), gomp4.BoxTypeMp4a(), gomp4.BoxTypeWave(), gomp4.BoxTypeEsds()},
+		{gomp4.BoxTypeMoov(), gomp4.BoxTypeTrak(), gomp4.BoxTypeMdia(), gomp4.BoxTypeMinf(), gomp4.BoxTypeStbl(), gomp4.BoxTypeStsd(), gomp4.BoxTypeEnca(), gomp4.BoxTypeEsds()},
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	bips, err := gomp4.ExtractBoxesWithPayload(rs, nil, paths)
+	if err != nil {
+		return nil, fmt.Errorf("extract esds: %w", err)
+	}
+
+	for _, bip := range bips {
+		if bip.Info.Type != gomp4.BoxTypeEsds() {
+			continue
+		}
+		esds, ok := bip.Payload.(*gomp4.Esds)
+		if !ok {
+			continue
+		}
+		for _, desc := range esds.Descriptors {
+			if desc.Tag == gomp4.DecSpecificInfoTag && len(desc.Data) >= 2 {
+				return desc.Data, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("AudioSpecificConfig not found in esds")
+}
+
+// ── Opus decoding (Concentus — full SILK + CELT) ────────
+
+func decodeOpus(rs io.ReadSeeker, track *gomp4.Track, sampleRate int) ([]float32, int, error) {
+	// Concentus requires one of: 8000, 12000, 16000, 24000, 48000
+	decoderRate := sampleRate
+	if decoderRate != 8000 && decoderRate != 12000 && decoderRate != 16000 &&
+		decoderRate != 24000 && decoderRate != 48000 {
+		decoderRate = 48000 // safe default for Opus
+	}
+
+	// Create a mono decoder (we'll downmix stereo ourselves if needed)
+	// Use 2 channels since the stream may be stereo
+	dec, err := concentus.NewOpusDecoder(decoderRate, 2)
+	if err != nil {
+		return nil, 0, fmt.Errorf("create opus decoder: %w", err)
+	}
+
+	maxSamples := decoderRate * maxSeconds
+
+	// Limit frame count: Opus produces ~960 PCM samples per frame (20 ms).
+	frameLimit := (maxSamples/960 + 1) * 2
+	samples := buildSampleLocations(track, frameLimit)
+
+	// Pre-allocate mono and reuse a single raw buffer.
+	mono := make([]float32, 0, maxSamples)
+	var maxRawSize uint32
+	for _, loc := range samples {
+		if loc.size > maxRawSize {
+			maxRawSize = loc.size
+		}
+	}
+	rawBuf := make([]byte, maxRawSize)
+
+	// Max Opus frame: 120 ms at 48 kHz = 5760 samples per channel × 2 channels
+	pcm16 := make([]int16, 5760*2)
+
+	skipErrors := 0
+
+	for _, loc := range samples {
+		if len(mono) >= maxSamples {
+			break
+		}
+
+		// Skip tiny packets (≤3 bytes are typically Opus padding/silence
+		// frames that the decoder can't process)
+		if loc.size <= 3 {
+			continue
+		}
+
+		if _, err := rs.Seek(int64(loc.offset), io.SeekStart); err != nil {
+			continue
+		}
+		raw := rawBuf[:loc.size]
+		if _, err := io.ReadFull(rs, raw); err != nil {
+			continue
+		}
+
+		// Decode one Opus packet → S16LE PCM
+		nSamples, err := dec.Decode(raw, 0, len(raw), pcm16, 0, 5760, false)
+		if err != nil {
+			skipErrors++
+			continue
+		}
+
+		// Downmix stereo → mono and convert int16 → float32
+		channels := 2
+		for i := 0; i < nSamples; i++ {
+			var sum float32
+			for ch := 0; ch < channels; ch++ {
+				sum += float32(pcm16[i*channels+ch]) / 32768.0
+			}
+			mono = append(mono, sum/float32(channels))
+		}
+	}
+
+	if skipErrors > 0 {
+		slog.Debug("bpm: skipped undecoded Opus frames", "count", skipErrors, "total", len(samples))
+	}
+
+	return mono, decoderRate, nil
+}
+
+// ── LPCM / G.711 decoding ────────────────────────────────
+
+// lpcmSampleEntry reads the AudioSampleEntry fields (channel count, sample
+// size) for the audio track's stsd child, trying every registered
+// uncompressed-format FourCC in turn since the track's actual codec box
+// type isn't otherwise surfaced by gomp4.Probe.
+func lpcmSampleEntry(rs io.ReadSeeker, boxTypes []gomp4.BoxType) (*gomp4.AudioSampleEntry, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	paths := make([]gomp4.BoxPath, 0, len(boxTypes))
+	for _, bt := range boxTypes {
+		paths = append(paths, gomp4.BoxPath{
+			gomp4.BoxTypeMoov(), gomp4.BoxTypeTrak(), gomp4.BoxTypeMdia(),
+			gomp4.BoxTypeMinf(), gomp4.BoxTypeStbl(), gomp4.BoxTypeStsd(), bt,
+		})
+	}
+	bips, err := gomp4.ExtractBoxesWithPayload(rs, nil, paths)
+	if err != nil {
+		return nil, fmt.Errorf("extract sample entry: %w", err)
+	}
+	for _, bip := range bips {
+		if ase, ok := bip.Payload.(*gomp4.AudioSampleEntry); ok {
+			return ase, nil
+		}
+	}
+	return nil, fmt.Errorf("no audio sample entry found")
+}
+
+// decodeMP4LPCM decodes uncompressed ("sowt"/"twos"/"in24"/"in32"/
+// "fl32"/"fl64"/"ipcm") samples straight from the file — there's no
+// entropy coding to undo, just byte layout to interpret.
+func decodeMP4LPCM(rs io.ReadSeeker, track *gomp4.Track, sampleRate int) ([]float32, int, error) {
+	ase, err := lpcmSampleEntry(rs, lpcmBoxTypes)
+	if err != nil {
+		return nil, 0, err
+	}
+	channels := int(ase.ChannelCount)
+	if channels < 1 {
+		channels = 1
+	}
+	bitsPerSample := int(ase.SampleSize)
+	if bitsPerSample == 0 {
+		bitsPerSample = 16
+	}
+
+	maxSamples := sampleRate * maxSeconds
+	bytesPerSample := bitsPerSample / 8
+	if bytesPerSample == 0 {
+		return nil, 0, fmt.Errorf("unsupported LPCM sample size %d bits", bitsPerSample)
+	}
+	frameSize := bytesPerSample * channels
+
+	samples := buildSampleLocations(track, 0)
+	mono := make([]float32, 0, maxSamples)
+	buf := make([]byte, frameSize)
+
+	for _, loc := range samples {
+		if len(mono) >= maxSamples {
+			break
+		}
+		if _, err := rs.Seek(int64(loc.offset), io.SeekStart); err != nil {
+			continue
+		}
+		// Each "sample" in a PCM track's sample table is one audio
+		// frame (all channels); walk it in frameSize-wide steps.
+		remaining := int(loc.size)
+		for remaining >= frameSize && len(mono) < maxSamples {
+			if _, err := io.ReadFull(rs, buf); err != nil {
+				break
+			}
+			var sum float32
+			for ch := 0; ch < channels; ch++ {
+				off := ch * bytesPerSample
+				// sowt and ipcm (without a pcmC override) are both
+				// little-endian signed integer PCM.
+				sum += decodePCMSample(buf[off:off+bytesPerSample], false, false)
+			}
+			mono = append(mono, sum/float32(channels))
+			remaining -= frameSize
+		}
+	}
+
+	return mono, sampleRate, nil
+}
+
+// decodeMP4G711 decodes "ulaw"/"alaw" companded 8-bit telephony audio by
+// table-expanding each byte to a 16-bit linear sample before downmixing.
+func decodeMP4G711(rs io.ReadSeeker, track *gomp4.Track, sampleRate int) ([]float32, int, error) {
+	ase, err := lpcmSampleEntry(rs, g711BoxTypes)
+	if err != nil {
+		return nil, 0, err
+	}
+	channels := int(ase.ChannelCount)
+	if channels < 1 {
+		channels = 1
+	}
+
+	isALaw := detectG711Law(rs)
+
+	maxSamples := sampleRate * maxSeconds
+	samples := buildSampleLocations(track, 0)
+	mono := make([]float32, 0, maxSamples)
+	buf := make([]byte, channels)
+
+	for _, loc := range samples {
+		if len(mono) >= maxSamples {
+			break
+		}
+		if _, err := rs.Seek(int64(loc.offset), io.SeekStart); err != nil {
+			continue
+		}
+		remaining := int(loc.size)
+		for remaining >= channels && len(mono) < maxSamples {
+			if _, err := io.ReadFull(rs, buf); err != nil {
+				break
+			}
+			var sum float32
+			for ch := 0; ch < channels; ch++ {
+				var linear int16
+				if isALaw {
+					linear = decodeALawSample(buf[ch])
+				} else {
+					linear = decodeULawSample(buf[ch])
+				}
+				sum += float32(linear) / 32768.0
+			}
+			mono = append(mono, sum/float32(channels))
+			remaining -= channels
+		}
+	}
+
+	return mono, sampleRate, nil
+}
+
+// detectG711Law re-walks the stsd to tell a-law and mu-law apart, since
+// lpcmSampleEntry's path search doesn't report which FourCC matched.
+func detectG711Law(rs io.ReadSeeker) bool {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	isALaw := false
+	_, _ = gomp4.ReadBoxStructure(rs, func(h *gomp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type {
+		case gomp4.StrToBoxType("alaw"):
+			isALaw = true
+		case gomp4.BoxTypeMoov(), gomp4.BoxTypeTrak(), gomp4.BoxTypeMdia(),
+			gomp4.BoxTypeMinf(), gomp4.BoxTypeStbl(), gomp4.BoxTypeStsd():
+			_, _ = h.Expand()
+		}
+		return nil, nil
+	})
+	return isALaw
+}
+
+// ── Shared helpers ──────────────────────────────────────
+
+// sampleLoc describes a single audio sample's position in the file.
+type sampleLoc struct {
+	offset uint64
+	size   uint32
+}
+
+// buildSampleLocations creates a flat list of (file-offset, size) for
+// audio samples.  limit caps how many entries are returned (0 = all).
+func buildSampleLocations(track *gomp4.Track, limit int) []sampleLoc {
+	capacity := len(track.Samples)
+	if limit > 0 && limit < capacity {
+		capacity = limit
+	}
+	result := make([]sampleLoc, 0, capacity)
+	sampleIdx := 0
+
+	for _, chunk := range track.Chunks {
+		off := chunk.DataOffset
+		for j := uint32(0); j < chunk.SamplesPerChunk; j++ {
+			if sampleIdx >= len(track.Samples) {
+				return result
+			}
+			if limit > 0 && len(result) >= limit {
+				return result
+			}
+			sz := track.Samples[sampleIdx].Size
+			result = append(result, sampleLoc{offset: off, size: sz})
+			off += uint64(sz)
+			sampleIdx++
+		}
+	}
+
+	return result
+}
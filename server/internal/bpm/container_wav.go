@@ -0,0 +1,108 @@
+package bpm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// extractPCMWAV decodes up to ~30 seconds of audio from a RIFF/WAVE file
+// containing linear PCM or IEEE-float samples, downmixing to mono
+// float32. Unlike the other containers, WAV has no separately-coded
+// "codec" to detect — the fmt chunk fully describes the sample layout.
+func extractPCMWAV(rs io.ReadSeeker) ([]float32, int, error) {
+	if _, err := rs.Seek(12, io.SeekStart); err != nil { // past "RIFF"+size+"WAVE"
+		return nil, 0, err
+	}
+
+	const formatPCM = 1
+	const formatIEEEFloat = 3
+
+	var (
+		formatTag     uint16
+		channels      uint16
+		sampleRate    uint32
+		bitsPerSample uint16
+		haveFmt       bool
+	)
+
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(rs, hdr[:]); err != nil {
+			break
+		}
+		chunkID := string(hdr[0:4])
+		chunkSize := binary.LittleEndian.Uint32(hdr[4:8])
+
+		switch chunkID {
+		case "fmt ":
+			buf := make([]byte, chunkSize)
+			if _, err := io.ReadFull(rs, buf); err != nil {
+				return nil, 0, fmt.Errorf("wav: read fmt chunk: %w", err)
+			}
+			if len(buf) < 16 {
+				return nil, 0, fmt.Errorf("wav: fmt chunk too small")
+			}
+			formatTag = binary.LittleEndian.Uint16(buf[0:2])
+			channels = binary.LittleEndian.Uint16(buf[2:4])
+			sampleRate = binary.LittleEndian.Uint32(buf[4:8])
+			bitsPerSample = binary.LittleEndian.Uint16(buf[14:16])
+			haveFmt = true
+
+		case "data":
+			if !haveFmt {
+				return nil, 0, fmt.Errorf("wav: data chunk before fmt chunk")
+			}
+			if channels == 0 || sampleRate == 0 {
+				return nil, 0, fmt.Errorf("wav: invalid fmt chunk")
+			}
+			isFloat := formatTag == formatIEEEFloat
+			if formatTag != formatPCM && formatTag != formatIEEEFloat {
+				return nil, 0, fmt.Errorf("wav: unsupported format tag %d", formatTag)
+			}
+			mono, err := decodeWAVData(rs, int64(chunkSize), isFloat, int(channels), int(bitsPerSample), int(sampleRate))
+			return mono, int(sampleRate), err
+
+		default:
+			// Skip any other chunk (LIST, fact, etc.), padded to an even
+			// byte boundary as required by the RIFF spec.
+			skip := int64(chunkSize)
+			if chunkSize%2 == 1 {
+				skip++
+			}
+			if _, err := rs.Seek(skip, io.SeekCurrent); err != nil {
+				return nil, 0, fmt.Errorf("wav: no data chunk found")
+			}
+		}
+	}
+
+	return nil, 0, fmt.Errorf("wav: no data chunk found")
+}
+
+func decodeWAVData(r io.Reader, size int64, isFloat bool, channels, bitsPerSample, sampleRate int) ([]float32, error) {
+	bytesPerSample := bitsPerSample / 8
+	if bytesPerSample == 0 || channels == 0 {
+		return nil, fmt.Errorf("wav: unsupported format (bits=%d channels=%d)", bitsPerSample, channels)
+	}
+	frameSize := bytesPerSample * channels
+	maxSamples := sampleRate * maxSeconds
+	totalFrames := int(size) / frameSize
+	if totalFrames < maxSamples {
+		maxSamples = totalFrames
+	}
+
+	buf := make([]byte, frameSize)
+	mono := make([]float32, 0, maxSamples)
+	for i := 0; i < maxSamples; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			break
+		}
+		var sum float32
+		for ch := 0; ch < channels; ch++ {
+			off := ch * bytesPerSample
+			sum += decodePCMSample(buf[off:off+bytesPerSample], false, isFloat)
+		}
+		mono = append(mono, sum/float32(channels))
+	}
+	return mono, nil
+}
@@ -0,0 +1,234 @@
+// Live ingest support: decoding FLV-framed audio tags in real time (see
+// internal/rtmp) instead of demuxing a whole container up front, and a
+// streaming BPM estimator that re-runs the usual tempogram over a
+// rolling window instead of a single fixed-length file.
+
+package bpm
+
+import (
+	"fmt"
+
+	aacdecoder "github.com/skrashevich/go-aac/pkg/decoder"
+)
+
+// FLV SoundFormat values (first nibble of an FLV audio tag's header
+// byte) that LiveDecoder knows how to decode. mediamtx's RTMP publisher
+// path added G.711 + LPCM support alongside the long-standing AAC case;
+// the FLV audio tag header layout (4-bit soundFormat, 2-bit soundRate,
+// 1-bit soundSize, 1-bit soundType) is part of the public Adobe FLV spec.
+const (
+	flvSoundLPCMPlatform = 0
+	flvSoundLPCMLE       = 3
+	flvSoundALaw         = 7
+	flvSoundMuLaw        = 8
+	flvSoundAAC          = 10
+)
+
+// flvSoundRates maps an FLV audio tag's 2-bit soundRate field to a
+// sample rate. Only consulted for the LPCM formats — AAC carries its own
+// sample rate in the AudioSpecificConfig, and G.711 is conventionally
+// 8kHz regardless of this field (the FLV spec predates G.711 support and
+// never added a rate worth trusting for it).
+var flvSoundRates = [4]int{5512, 11025, 22050, 44100}
+
+// g711SampleRate is the fixed sample rate G.711 tags are decoded at,
+// matching mediamtx's RTMP publisher and the codec's own telephony
+// heritage (8kHz), independent of the tag's soundRate bits.
+const g711SampleRate = 8000
+
+// LiveDecoder decodes a single live RTMP publish's audio tags into mono
+// float32 PCM, one tag at a time. Unlike AnalyseFile's container demux,
+// there's no seekable stream to re-read — state that spans tags (the
+// AAC decoder and its AudioSpecificConfig) lives on the LiveDecoder
+// itself instead of being rebuilt per call.
+type LiveDecoder struct {
+	aac      *aacdecoder.Decoder
+	aacChans int
+	haveASC  bool
+}
+
+// NewLiveDecoder creates a decoder ready to receive FLV audio tags from a
+// fresh publish.
+func NewLiveDecoder() *LiveDecoder {
+	return &LiveDecoder{}
+}
+
+// DecodeAudioTag decodes one FLV audio tag body (header byte included,
+// as delivered by internal/rtmp) to mono float32 PCM. Returns ok=false
+// for tags that carry no decodable samples (an AAC sequence header just
+// primes the decoder; there's nothing to feed the tempogram yet).
+func (d *LiveDecoder) DecodeAudioTag(tag []byte) (pcm []float32, sampleRate int, ok bool, err error) {
+	if len(tag) < 1 {
+		return nil, 0, false, fmt.Errorf("bpm: empty audio tag")
+	}
+
+	header := tag[0]
+	soundFormat := header >> 4
+	soundRate := (header >> 2) & 0x3
+	soundSize := (header >> 1) & 0x1
+	soundType := header & 0x1
+	body := tag[1:]
+
+	switch soundFormat {
+	case flvSoundAAC:
+		return d.decodeAAC(body)
+	case flvSoundALaw, flvSoundMuLaw:
+		return decodeG711Tag(soundFormat, body), g711SampleRate, true, nil
+	case flvSoundLPCMPlatform, flvSoundLPCMLE:
+		return decodeLPCMTag(body, soundSize, soundType), flvSoundRates[soundRate], true, nil
+	default:
+		return nil, 0, false, fmt.Errorf("bpm: unsupported live audio soundFormat %d", soundFormat)
+	}
+}
+
+// decodeAAC handles one AAC FLV audio tag body: byte 0 is the
+// AACPacketType (0 = sequence header carrying the AudioSpecificConfig,
+// 1 = a raw access unit), matching the MP4 path's use of the same
+// go-aac decoder — just fed one frame at a time instead of from sample
+// table offsets.
+func (d *LiveDecoder) decodeAAC(body []byte) (pcm []float32, sampleRate int, ok bool, err error) {
+	if len(body) < 1 {
+		return nil, 0, false, fmt.Errorf("bpm: empty AAC tag body")
+	}
+	packetType := body[0]
+	payload := body[1:]
+
+	if packetType == 0 {
+		dec := aacdecoder.New()
+		if err := dec.SetASC(payload); err != nil {
+			return nil, 0, false, fmt.Errorf("bpm: live AAC ASC: %w", err)
+		}
+		chans := dec.Config.ChanConfig
+		if chans < 1 {
+			chans = 1
+		}
+		d.aac = dec
+		d.aacChans = chans
+		d.haveASC = true
+		return nil, dec.Config.SampleRate, false, nil
+	}
+
+	if !d.haveASC || d.aac == nil {
+		return nil, 0, false, fmt.Errorf("bpm: AAC raw packet before sequence header")
+	}
+
+	frame, err := d.aac.DecodeFrame(payload)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("bpm: decode live AAC frame: %w", err)
+	}
+
+	frameLen := len(frame) / d.aacChans
+	mono := make([]float32, frameLen)
+	for i := 0; i < frameLen; i++ {
+		var sum float32
+		for ch := 0; ch < d.aacChans; ch++ {
+			sum += frame[i*d.aacChans+ch]
+		}
+		mono[i] = sum / float32(d.aacChans)
+	}
+	return mono, d.aac.Config.SampleRate, true, nil
+}
+
+// decodeG711Tag expands an 8-bit companded G.711 tag body (one byte per
+// sample, mono or interleaved stereo per soundType) to mono float32,
+// reusing the same inverse-companding tables the file-based WAV/MP4
+// paths use.
+func decodeG711Tag(soundFormat byte, body []byte) []float32 {
+	decode := decodeALawSample
+	if soundFormat == flvSoundMuLaw {
+		decode = decodeULawSample
+	}
+	pcm := make([]float32, len(body))
+	for i, b := range body {
+		pcm[i] = float32(decode(b)) / 32768
+	}
+	return pcm
+}
+
+// decodeLPCMTag decodes an uncompressed LPCM tag body (8 or 16-bit,
+// little-endian per the FLV spec) to mono float32, downmixing stereo by
+// averaging channels. Reuses decodePCMSample, the same sample decoder
+// the WAV and MP4 LPCM paths use.
+func decodeLPCMTag(body []byte, soundSize, soundType byte) []float32 {
+	bytesPerSample := 1
+	if soundSize == 1 {
+		bytesPerSample = 2
+	}
+	channels := 1
+	if soundType == 1 {
+		channels = 2
+	}
+	frameSize := bytesPerSample * channels
+	if frameSize == 0 || len(body) < frameSize {
+		return nil
+	}
+
+	numFrames := len(body) / frameSize
+	pcm := make([]float32, numFrames)
+	for i := 0; i < numFrames; i++ {
+		frame := body[i*frameSize : (i+1)*frameSize]
+		var sum float32
+		for ch := 0; ch < channels; ch++ {
+			sum += decodePCMSample(frame[ch*bytesPerSample:(ch+1)*bytesPerSample], false, false)
+		}
+		pcm[i] = sum / float32(channels)
+	}
+	return pcm
+}
+
+// streamMaxSeconds bounds StreamDetector's rolling window, matching
+// AnalyseFile's maxSeconds so a live estimate is exactly as expensive as
+// a file-based one per recompute.
+const streamMaxSeconds = maxSeconds
+
+// streamMinSeconds is the shortest rolling window StreamDetector will
+// attempt a tempo estimate from — short of this, the tempogram's
+// autocorrelation/comb-filter scoring (which needs several repetitions
+// of the beat period to distinguish octave errors) hasn't got enough to
+// work with yet.
+const streamMinSeconds = 6
+
+// StreamDetector re-estimates tempo from a rolling window of live PCM,
+// one pushed chunk at a time, instead of AnalyseFile's single pass over
+// a whole decoded file. It keeps the ring-buffered audio itself (not
+// just the flux curve) so each push reuses the exact same
+// analyseOnsets pipeline a file analysis would — no second code path to
+// keep in sync with tempogram.go.
+type StreamDetector struct {
+	sampleRate int
+	ring       []float32 // most recent min(pushed, streamMaxSeconds*sampleRate) samples
+	windowCap  int
+}
+
+// NewStreamDetector creates a detector for audio arriving at sampleRate.
+func NewStreamDetector(sampleRate int) *StreamDetector {
+	return &StreamDetector{
+		sampleRate: sampleRate,
+		windowCap:  sampleRate * streamMaxSeconds,
+	}
+}
+
+// Push appends pcm to the rolling window (dropping the oldest samples
+// once the window is full) and, once enough audio has accumulated,
+// returns a fresh tempo estimate over the current window. ok is false
+// while the window is still below streamMinSeconds.
+func (s *StreamDetector) Push(pcm []float32) (result AnalyseResult, ok bool) {
+	s.ring = append(s.ring, pcm...)
+	if excess := len(s.ring) - s.windowCap; excess > 0 {
+		s.ring = append(s.ring[:0], s.ring[excess:]...)
+	}
+
+	if len(s.ring) < s.sampleRate*streamMinSeconds {
+		return AnalyseResult{}, false
+	}
+
+	bpmVal, confidence, firstBeatSec := detectBPM(s.ring, s.sampleRate)
+	if bpmVal <= 0 {
+		return AnalyseResult{}, false
+	}
+	return AnalyseResult{
+		BPM:          bpmVal,
+		Confidence:   confidence,
+		FirstBeatSec: firstBeatSec,
+	}, true
+}
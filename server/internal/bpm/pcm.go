@@ -0,0 +1,96 @@
+package bpm
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// decodePCMSample decodes a single interleaved PCM sample (1, 2, 3, 4 or
+// 8 bytes wide) to a float32 in [-1, 1]. Shared by the WAV and MP4 LPCM
+// paths, which only differ in container framing, not sample encoding.
+func decodePCMSample(b []byte, bigEndian, isFloat bool) float32 {
+	switch {
+	case isFloat && len(b) == 4:
+		var bits uint32
+		if bigEndian {
+			bits = binary.BigEndian.Uint32(b)
+		} else {
+			bits = binary.LittleEndian.Uint32(b)
+		}
+		return math.Float32frombits(bits)
+	case isFloat && len(b) == 8:
+		var bits uint64
+		if bigEndian {
+			bits = binary.BigEndian.Uint64(b)
+		} else {
+			bits = binary.LittleEndian.Uint64(b)
+		}
+		return float32(math.Float64frombits(bits))
+	case len(b) == 1:
+		// 8-bit PCM is conventionally unsigned, centred at 128.
+		return (float32(b[0]) - 128) / 128
+	case len(b) == 2:
+		var v int16
+		if bigEndian {
+			v = int16(binary.BigEndian.Uint16(b))
+		} else {
+			v = int16(binary.LittleEndian.Uint16(b))
+		}
+		return float32(v) / 32768
+	case len(b) == 3:
+		var v int32
+		if bigEndian {
+			v = int32(b[0])<<16 | int32(b[1])<<8 | int32(b[2])
+		} else {
+			v = int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
+		}
+		if v&0x800000 != 0 {
+			v |= ^int32(0xFFFFFF)
+		}
+		return float32(v) / 8388608
+	case len(b) == 4:
+		var v int32
+		if bigEndian {
+			v = int32(binary.BigEndian.Uint32(b))
+		} else {
+			v = int32(binary.LittleEndian.Uint32(b))
+		}
+		return float32(v) / 2147483648
+	default:
+		return 0
+	}
+}
+
+// decodeULawSample expands a single G.711 μ-law byte to a 16-bit linear
+// PCM sample (standard ITU-T G.711 inverse companding).
+func decodeULawSample(u byte) int16 {
+	const bias = 0x84
+	u = ^u
+	t := (int16(u&0x0F) << 3) + bias
+	t <<= (u & 0x70) >> 4
+	if u&0x80 != 0 {
+		return bias - t
+	}
+	return t - bias
+}
+
+// decodeALawSample expands a single G.711 A-law byte to a 16-bit linear
+// PCM sample (standard ITU-T G.711 inverse companding).
+func decodeALawSample(a byte) int16 {
+	a ^= 0x55
+	t := int16(a&0x0F) << 4
+	seg := (a & 0x70) >> 4
+	switch seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= seg - 1
+	}
+	if a&0x80 != 0 {
+		return t
+	}
+	return -t
+}
@@ -0,0 +1,176 @@
+package bpm
+
+import "math"
+
+// An approximate ITU-R BS.1770 (EBU R128) loudness/true-peak estimator,
+// run over the same mono PCM decoded for BPM detection. It follows the
+// reference K-weighting filter and gated-block algorithm, but trades a
+// few corners for being effectively free alongside detectBPM: mono input
+// only (no per-channel weighting), a 30-second window instead of the
+// full track, and true peak via linear interpolation rather than a
+// proper sinc. internal/loudness's ffmpeg-derived value is authoritative
+// whenever it's available — this exists to seed a gain estimate before
+// that pass has run, especially for the containers bpm now decodes that
+// ffmpeg-based analysis may not have covered yet.
+
+// biquad is a Direct Form II Transposed biquad filter section.
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	z1, z2     float64
+}
+
+func (bq *biquad) process(x float64) float64 {
+	y := bq.b0*x + bq.z1
+	bq.z1 = bq.b1*x - bq.a1*y + bq.z2
+	bq.z2 = bq.b2*x - bq.a2*y
+	return y
+}
+
+// preFilter is BS.1770's stage-1 high-shelf (+4 dB above ~1.68 kHz),
+// approximating the head's acoustic response.
+func preFilter(sampleRate int) biquad {
+	const (
+		f0 = 1681.974450955533
+		g  = 3.999843853973347
+		q  = 0.7071752369554196
+	)
+	k := math.Tan(math.Pi * f0 / float64(sampleRate))
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	return biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+}
+
+// rlbFilter is BS.1770's stage-2 highpass (~38 Hz), modelling the ear's
+// reduced sensitivity to bass.
+func rlbFilter(sampleRate int) biquad {
+	const (
+		f0 = 38.13547087602444
+		q  = 0.5003270373238773
+	)
+	k := math.Tan(math.Pi * f0 / float64(sampleRate))
+	a0 := 1 + k/q + k*k
+	return biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+}
+
+// kWeight applies the pre-filter and RLB filter in series, the
+// "K-weighting" BS.1770 applies before measuring block energy.
+func kWeight(pcm []float32, sampleRate int) []float64 {
+	pre := preFilter(sampleRate)
+	rlb := rlbFilter(sampleRate)
+	out := make([]float64, len(pcm))
+	for i, s := range pcm {
+		out[i] = rlb.process(pre.process(float64(s)))
+	}
+	return out
+}
+
+// integratedLUFS estimates integrated loudness via BS.1770's gated-block
+// algorithm: 400ms blocks at 75% overlap, an absolute gate at -70 LUFS,
+// then a relative gate 10 LU below the mean of the blocks that passed
+// the absolute gate.
+func integratedLUFS(pcm []float32, sampleRate int) float64 {
+	if len(pcm) == 0 || sampleRate == 0 {
+		return 0
+	}
+	weighted := kWeight(pcm, sampleRate)
+
+	blockSize := sampleRate * 400 / 1000
+	hopSize := sampleRate * 100 / 1000
+	if blockSize <= 0 || hopSize <= 0 || len(weighted) < blockSize {
+		return 0
+	}
+
+	var blockMS, blockLUFS []float64
+	for start := 0; start+blockSize <= len(weighted); start += hopSize {
+		var sum float64
+		for _, v := range weighted[start : start+blockSize] {
+			sum += v * v
+		}
+		ms := sum / float64(blockSize)
+		if ms <= 0 {
+			continue
+		}
+		blockMS = append(blockMS, ms)
+		blockLUFS = append(blockLUFS, -0.691+10*math.Log10(ms))
+	}
+	if len(blockMS) == 0 {
+		return -70
+	}
+
+	const absoluteGate = -70.0
+	var ungatedMS []float64
+	for i, l := range blockLUFS {
+		if l >= absoluteGate {
+			ungatedMS = append(ungatedMS, blockMS[i])
+		}
+	}
+	if len(ungatedMS) == 0 {
+		return absoluteGate
+	}
+
+	relativeGate := meanLoudness(ungatedMS) - 10
+
+	var gatedMS []float64
+	for _, ms := range ungatedMS {
+		if -0.691+10*math.Log10(ms) >= relativeGate {
+			gatedMS = append(gatedMS, ms)
+		}
+	}
+	if len(gatedMS) == 0 {
+		gatedMS = ungatedMS
+	}
+	return meanLoudness(gatedMS)
+}
+
+// meanLoudness converts a set of block mean-square energies to LUFS by
+// averaging them in the linear power domain first, per BS.1770 (the
+// -0.691 dB offset is the K-weighting calibration constant).
+func meanLoudness(meanSquares []float64) float64 {
+	var sum float64
+	for _, ms := range meanSquares {
+		sum += ms
+	}
+	return -0.691 + 10*math.Log10(sum/float64(len(meanSquares)))
+}
+
+// truePeakDB estimates true peak (dBTP) by 4x-oversampling the signal
+// via linear interpolation between samples — a cheap stand-in for the
+// windowed-sinc interpolation BS.1770 specifies, good enough to flag
+// inter-sample peaks a sample-peak reading would miss entirely.
+func truePeakDB(pcm []float32) float64 {
+	if len(pcm) == 0 {
+		return 0
+	}
+	const oversample = 4
+	peak := math.Abs(float64(pcm[0]))
+	for i := 0; i < len(pcm)-1; i++ {
+		a, b := float64(pcm[i]), float64(pcm[i+1])
+		for k := 1; k < oversample; k++ {
+			t := float64(k) / float64(oversample)
+			if v := math.Abs(a + (b-a)*t); v > peak {
+				peak = v
+			}
+		}
+		if v := math.Abs(b); v > peak {
+			peak = v
+		}
+	}
+	if peak <= 0 {
+		return -144 // effectively silent
+	}
+	return 20 * math.Log10(peak)
+}
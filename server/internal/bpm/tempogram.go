@@ -0,0 +1,290 @@
+package bpm
+
+import "math"
+
+// STFT window/hop for tempo detection: 1024 points (~23ms at 44100Hz,
+// matching the old RMS window granularity) with 50% overlap.
+const (
+	stftWindow = 1024
+	stftHop    = stftWindow / 2
+)
+
+// fluxGamma controls the log-compression applied to each frame's
+// magnitude spectrum before differencing (see spectralFlux) — larger
+// values emphasise quiet onsets (hi-hats, snares) relative to the
+// loudest frames, which plain linear-magnitude flux tends to drown out.
+const fluxGamma = 1000.0
+
+// hannWindow returns a periodic Hann window of length n, used to taper
+// each STFT frame and reduce spectral leakage.
+func hannWindow(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+	return w
+}
+
+// fft computes the in-place iterative radix-2 Cooley-Tukey FFT of x,
+// whose length must be a power of two. Pure Go, no external dependency —
+// consistent with the rest of this package (see container_flac.go for
+// the same "hand-roll it rather than add a dependency" call).
+func fft(x []complex128) {
+	n := len(x)
+	if n <= 1 {
+		return
+	}
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for length := 2; length <= n; length <<= 1 {
+		ang := -2 * math.Pi / float64(length)
+		wlen := complex(math.Cos(ang), math.Sin(ang))
+		for i := 0; i < n; i += length {
+			w := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				u := x[i+k]
+				v := x[i+k+length/2] * w
+				x[i+k] = u + v
+				x[i+k+length/2] = u - v
+				w *= wlen
+			}
+		}
+	}
+}
+
+// magnitudeSpectrum returns the magnitude of the first len(frame)/2+1
+// FFT bins of a Hann-windowed frame (the Nyquist-and-below half — the
+// rest mirrors it for real input).
+func magnitudeSpectrum(frame []float64, window []float64) []float64 {
+	n := len(frame)
+	buf := make([]complex128, n)
+	for i, s := range frame {
+		buf[i] = complex(s*window[i], 0)
+	}
+	fft(buf)
+	mags := make([]float64, n/2+1)
+	for i := range mags {
+		mags[i] = cmplxAbs(buf[i])
+	}
+	return mags
+}
+
+func cmplxAbs(c complex128) float64 {
+	return math.Hypot(real(c), imag(c))
+}
+
+// spectralFlux runs a 1024-point Hann-windowed STFT at 50% hop over pcm
+// and returns the log-compressed positive spectral difference between
+// consecutive frames — the "is something new starting right now" signal
+// onset/tempo detection is built on. wps is hops per second, i.e. the
+// flux sample rate.
+func spectralFlux(pcm []float32, sampleRate int) (flux []float64, wps float64) {
+	numFrames := (len(pcm)-stftWindow)/stftHop + 1
+	if numFrames < 4 {
+		return nil, 0
+	}
+
+	window := hannWindow(stftWindow)
+	frame := make([]float64, stftWindow)
+	compressed := make([][]float64, numFrames)
+	for i := 0; i < numFrames; i++ {
+		start := i * stftHop
+		for j := 0; j < stftWindow; j++ {
+			frame[j] = float64(pcm[start+j])
+		}
+		mags := magnitudeSpectrum(frame, window)
+		c := make([]float64, len(mags))
+		for b, m := range mags {
+			c[b] = math.Log1p(fluxGamma * m)
+		}
+		compressed[i] = c
+	}
+
+	flux = make([]float64, numFrames)
+	for i := 1; i < numFrames; i++ {
+		var sum float64
+		for b := range compressed[i] {
+			if d := compressed[i][b] - compressed[i-1][b]; d > 0 {
+				sum += d
+			}
+		}
+		flux[i] = sum
+	}
+
+	return flux, float64(sampleRate) / float64(stftHop)
+}
+
+// adaptiveWhiten subtracts a ~1-second moving median from flux and
+// half-wave rectifies the result, so a loud build-up section doesn't
+// permanently raise the onset-detection floor for the quieter section
+// that follows it.
+func adaptiveWhiten(flux []float64, wps float64) []float64 {
+	radius := int(wps / 2) // ~1 second window, centered
+	if radius < 1 {
+		radius = 1
+	}
+	out := make([]float64, len(flux))
+	window := make([]float64, 0, 2*radius+1)
+	for i := range flux {
+		window = window[:0]
+		lo, hi := i-radius, i+radius
+		if lo < 0 {
+			lo = 0
+		}
+		if hi >= len(flux) {
+			hi = len(flux) - 1
+		}
+		for j := lo; j <= hi; j++ {
+			window = append(window, flux[j])
+		}
+		med := median(window)
+		if d := flux[i] - med; d > 0 {
+			out[i] = d
+		}
+	}
+	return out
+}
+
+func median(xs []float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	for i := 1; i < len(sorted); i++ {
+		for j := i; j > 0 && sorted[j-1] > sorted[j]; j-- {
+			sorted[j-1], sorted[j] = sorted[j], sorted[j-1]
+		}
+	}
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[len(sorted)/2]
+}
+
+// tempoEstimate is the result of combined autocorrelation/comb-filter
+// tempo estimation over a whitened onset-strength curve.
+type tempoEstimate struct {
+	bpm          float64
+	confidence   float64 // 0-1, normalized strength of the winning lag
+	firstBeatSec float64
+}
+
+// estimateTempo picks the dominant periodicity of a whitened onset
+// signal via two complementary measures and combines them:
+//
+//   - autocorrelation, which rewards any lag where onsets repeat, but
+//     is ambiguous between a tempo and its octaves/sub-octaves since it
+//     only ever compares two instances at a time;
+//   - a comb filter (flux summed at t, t+L, t+2L, ... under a 5-second
+//     Gaussian envelope), which rewards a lag only if the onset pattern
+//     stays periodic across many repetitions — octave errors fall apart
+//     over that longer horizon even when they look fine two beats out.
+//
+// Once the best lag is chosen, phase is recovered by cross-correlating
+// the flux with a click train at that period and sliding it across one
+// full period — the offset of maximum correlation is the first beat.
+func estimateTempo(flux []float64, wps float64) (tempoEstimate, bool) {
+	if wps <= 0 || len(flux) < 8 {
+		return tempoEstimate{}, false
+	}
+
+	minLag := int(wps * 60.0 / 200.0) // 200 BPM → shortest period
+	maxLag := int(wps * 60.0 / 60.0)  // 60 BPM → longest period
+	if minLag < 1 {
+		minLag = 1
+	}
+	if maxLag >= len(flux)/2 {
+		maxLag = len(flux)/2 - 1
+	}
+	if minLag >= maxLag {
+		return tempoEstimate{}, false
+	}
+
+	// Comb filter envelope: weight repetitions out to ~5 seconds with a
+	// Gaussian so distant repetitions still count but don't dominate.
+	combSigma := 5.0 * wps
+
+	bestLag := minLag
+	bestScore := -1.0
+	autocorr := make([]float64, maxLag+1)
+	comb := make([]float64, maxLag+1)
+	for lag := minLag; lag <= maxLag; lag++ {
+		var ac float64
+		var acCount int
+		for i := 0; i+lag < len(flux); i++ {
+			ac += flux[i] * flux[i+lag]
+			acCount++
+		}
+		if acCount > 0 {
+			ac /= float64(acCount)
+		}
+		autocorr[lag] = ac
+
+		var cb, weight float64
+		for t := 0; t+lag < len(flux); t += lag {
+			g := math.Exp(-float64(t*t) / (2 * combSigma * combSigma))
+			cb += flux[t+lag] * g
+			weight += g
+		}
+		if weight > 0 {
+			cb /= weight
+		}
+		comb[lag] = cb
+
+		score := ac * cb
+		if score > bestScore {
+			bestScore = score
+			bestLag = lag
+		}
+	}
+
+	bpm := (wps * 60.0) / float64(bestLag)
+	for bpm < 60 {
+		bpm *= 2
+	}
+	for bpm > 200 {
+		bpm /= 2
+	}
+	bpm = math.Round(bpm*10) / 10
+
+	// Phase recovery: slide a click train at bestLag's period across one
+	// period and keep the offset that best lines up with real onsets.
+	bestOffset := 0
+	bestPhaseScore := -1.0
+	for offset := 0; offset < bestLag; offset++ {
+		var score float64
+		for t := offset; t < len(flux); t += bestLag {
+			score += flux[t]
+		}
+		if score > bestPhaseScore {
+			bestPhaseScore = score
+			bestOffset = offset
+		}
+	}
+
+	var fluxSum float64
+	for _, v := range flux {
+		fluxSum += v
+	}
+	confidence := 0.0
+	if fluxSum > 0 {
+		confidence = bestScore / (fluxSum * fluxSum / float64(len(flux)))
+		if confidence > 1 {
+			confidence = 1
+		}
+		if confidence < 0 {
+			confidence = 0
+		}
+	}
+
+	return tempoEstimate{
+		bpm:          bpm,
+		confidence:   confidence,
+		firstBeatSec: float64(bestOffset) / wps,
+	}, true
+}
@@ -1,19 +1,145 @@
 // Package browser provides a fire-and-forget helper to open a URL in the
 // user's default browser. If opening fails (headless server, no browser
-// installed, etc.) the error is logged at debug level and the caller is
-// unaffected.
+// installed, etc.) the caller is notified so it can print a fallback
+// message instead of leaving the user stuck with a silent debug log.
 package browser
 
-import "log/slog"
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Config lets operators override how the browser is launched, for
+// environments the per-OS default doesn't handle: WSL (wslview), a
+// forwarded SSH session (code --openExternal), or pinning a specific
+// browser on macOS (-a "Google Chrome").
+type Config struct {
+	// Command is the argv to run. The URL replaces the first "{}"
+	// placeholder; if no placeholder is present, the URL is appended
+	// as the final argument. Empty means "use the platform default".
+	Command []string
+	// Disabled skips opening a browser entirely (CI, kiosk deployments).
+	Disabled bool
+}
+
+var (
+	configMu sync.RWMutex
+	config   Config
+)
+
+// SetConfig installs operator overrides for how Open launches a browser.
+// Safe to call concurrently with Open.
+func SetConfig(cfg Config) {
+	configMu.Lock()
+	config = cfg
+	configMu.Unlock()
+}
 
 // Open attempts to launch the default browser at url.
 // It returns immediately; failure is non-fatal.
+//
+// This is a thin wrapper around OpenOrNotify that prints a boxed
+// fallback message to stderr when the browser couldn't be opened.
 func Open(url string) {
+	OpenOrNotify(url, defaultNotify)
+}
+
+// OpenOrNotify attempts to launch the default browser at url. If no
+// display is detected, or the browser fails to start, notify is called
+// with the url and a short reason ("no display detected" or the
+// underlying open error) instead of silently giving up.
+//
+// Resolution order: an explicit Config.Command, then $VDJ_BROWSER or
+// $BROWSER, then the platform's own default (xdg-open, `open`, rundll32).
+func OpenOrNotify(url string, notify func(url string, reason string)) {
+	if notify == nil {
+		notify = defaultNotify
+	}
+
+	configMu.RLock()
+	cfg := config
+	configMu.RUnlock()
+
+	if cfg.Disabled {
+		slog.Debug("browser open disabled by config")
+		return
+	}
+
+	if len(cfg.Command) > 0 {
+		if err := runCommand(cfg.Command, url); err != nil {
+			slog.Debug("configured browser command failed", "url", url, "error", err)
+			notify(url, err.Error())
+		}
+		return
+	}
+
+	if envCmd := os.Getenv("VDJ_BROWSER"); envCmd != "" {
+		if err := runCommand(strings.Fields(envCmd), url); err != nil {
+			slog.Debug("VDJ_BROWSER command failed", "url", url, "error", err)
+			notify(url, err.Error())
+		}
+		return
+	}
+
 	if !hasDisplay() {
 		slog.Debug("skipping browser open: no display detected")
+		notify(url, "no display detected")
 		return
 	}
 	if err := open(url); err != nil {
 		slog.Debug("could not open browser", "url", url, "error", err)
+		notify(url, err.Error())
+	}
+}
+
+// runCommand executes argv with url substituted for a "{}" placeholder,
+// or appended as the last argument if no placeholder is present.
+func runCommand(argv []string, url string) error {
+	if len(argv) == 0 {
+		return fmt.Errorf("browser: empty command")
+	}
+
+	args := make([]string, 0, len(argv))
+	substituted := false
+	for _, a := range argv[1:] {
+		if a == "{}" {
+			args = append(args, url)
+			substituted = true
+			continue
+		}
+		args = append(args, a)
+	}
+	if !substituted {
+		args = append(args, url)
+	}
+
+	return startAndReap(exec.Command(argv[0], args...))
+}
+
+// startAndReap starts cmd and waits on it in a background goroutine so
+// the child is reaped instead of becoming a zombie. The browser process
+// is expected to detach and keep running on its own; we don't care
+// about its exit status.
+func startAndReap(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// defaultNotify prints a boxed message to stderr pointing the user at url.
+func defaultNotify(url string, reason string) {
+	msg := fmt.Sprintf("If your browser did not open, navigate to %s", url)
+	width := len(msg) + 4
+	border := ""
+	for i := 0; i < width; i++ {
+		border += "─"
 	}
+	fmt.Fprintf(os.Stderr, "\n┌%s┐\n│  %s  │\n└%s┘\n\n", border, msg, border)
+	slog.Debug("browser open fallback notice shown", "reason", reason)
 }
@@ -5,14 +5,52 @@ package browser
 import (
 	"os"
 	"os/exec"
+	"strings"
 )
 
+// open launches url in a browser. It prefers xdg-open, which on most
+// Linux/BSD desktops knows how to hand the URL to whatever browser is
+// configured. If xdg-open isn't installed, it falls back to $BROWSER,
+// trying each ':'-separated entry in order (the freedesktop BROWSER
+// spec) until one starts successfully.
+//
+// The child is started detached from our stdio and reaped in a
+// goroutine so it never lingers as a zombie — this server is meant to
+// run for hours/days, and os/exec.Cmd.Start() without a matching Wait()
+// leaks a zombie process entry per browser launch.
 func open(url string) error {
-	return exec.Command("xdg-open", url).Start()
+	if path, err := exec.LookPath("xdg-open"); err == nil {
+		return startAndReap(exec.Command(path, url))
+	}
+
+	var lastErr error
+	for _, name := range strings.Split(os.Getenv("BROWSER"), ":") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		cmd := exec.Command(name, url)
+		if err := startAndReap(cmd); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return exec.ErrNotFound
 }
 
+// hasDisplay reports whether we're likely running in a graphical
+// session. We treat the environment as headless only when there is no
+// X11 or Wayland display AND no xdg-open on $PATH — xdg-open can still
+// hand off to a remote or SSH-forwarded browser even without a local
+// display.
 func hasDisplay() bool {
-	// On Linux / BSD, a graphical session sets $DISPLAY (X11) or
-	// $WAYLAND_DISPLAY. If neither is present we're likely headless.
-	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	if os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != "" {
+		return true
+	}
+	_, err := exec.LookPath("xdg-open")
+	return err == nil
 }
@@ -0,0 +1,106 @@
+//go:build !windows && !darwin
+
+package browser
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeStub creates an executable stub named name in dir that exits 0,
+// so it can stand in for xdg-open or a $BROWSER entry without needing a
+// real one installed.
+func writeStub(t *testing.T, dir, name string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatalf("writeStub %s: %v", name, err)
+	}
+}
+
+func TestHasDisplay(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("unix-only behavior")
+	}
+
+	tests := []struct {
+		name          string
+		display       string
+		waylandDisp   string
+		xdgOpenOnPath bool
+		want          bool
+	}{
+		{"X11 display set", "test:0", "", false, true},
+		{"Wayland display set", "", "wayland-0", false, true},
+		{"no display but xdg-open available", "", "", true, true},
+		{"headless, no xdg-open", "", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.xdgOpenOnPath {
+				writeStub(t, dir, "xdg-open")
+			}
+			t.Setenv("PATH", dir)
+			t.Setenv("DISPLAY", tt.display)
+			t.Setenv("WAYLAND_DISPLAY", tt.waylandDisp)
+
+			if got := hasDisplay(); got != tt.want {
+				t.Errorf("hasDisplay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("unix-only behavior")
+	}
+
+	t.Run("prefers xdg-open when present", func(t *testing.T) {
+		dir := t.TempDir()
+		writeStub(t, dir, "xdg-open")
+		t.Setenv("PATH", dir)
+		t.Setenv("BROWSER", "")
+
+		if err := open("http://example.com"); err != nil {
+			t.Errorf("open() = %v, want nil", err)
+		}
+	})
+
+	t.Run("falls back to first working $BROWSER entry", func(t *testing.T) {
+		dir := t.TempDir()
+		writeStub(t, dir, "good-browser")
+		t.Setenv("PATH", dir)
+		// "missing-browser" isn't on PATH, so starting it fails and open
+		// should move on to "good-browser" per the freedesktop BROWSER spec.
+		t.Setenv("BROWSER", "missing-browser:good-browser")
+
+		if err := open("http://example.com"); err != nil {
+			t.Errorf("open() = %v, want nil", err)
+		}
+	})
+
+	t.Run("returns the last error when every $BROWSER entry fails", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("PATH", dir)
+		t.Setenv("BROWSER", "missing-one:missing-two")
+
+		if err := open("http://example.com"); err == nil {
+			t.Error("open() = nil, want an error")
+		}
+	})
+
+	t.Run("no xdg-open and no $BROWSER", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("PATH", dir)
+		t.Setenv("BROWSER", "")
+
+		if err := open("http://example.com"); err == nil {
+			t.Error("open() = nil, want an error")
+		}
+	})
+}
@@ -0,0 +1,265 @@
+// Package ctlsocket exposes a Unix domain socket control interface for
+// external controllers (e.g. a lighting rig, a show-control box, a
+// second process on the same host) that want to drive playback without
+// going through HTTP. Each connection is bidirectional: the client sends
+// length-prefixed JSON commands and gets a length-prefixed JSON response
+// per command, while the server pushes unsolicited length-prefixed event
+// frames mirroring the SSE stream (deck-update, transition-play,
+// analysis-status, ...) so a single connection can both observe and
+// control.
+package ctlsocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jota2rz/vdj-video-sync/server/internal/models"
+	"github.com/jota2rz/vdj-video-sync/server/internal/sse"
+)
+
+// maxFrameSize bounds how large a single length-prefixed frame may be,
+// guarding against a misbehaving client claiming a huge length.
+const maxFrameSize = 1 << 20 // 1 MiB
+
+// Controller is the subset of *handlers.Handlers that ctlsocket drives.
+// Commands invoke these directly — the same code paths the HTTP API
+// uses — rather than duplicating the logic.
+type Controller interface {
+	ForceVideo(path string) (models.VideoFile, error)
+	QueueAddPath(path string) error
+	QueueNext() (models.VideoFile, bool)
+	QueuePrev() bool
+	SetLoopVideo(path string) error
+	TriggerAnalysis() error
+	SetPaused(paused bool)
+}
+
+// Server listens on a Unix domain socket and dispatches control commands.
+type Server struct {
+	path string
+	ctrl Controller
+	hub  *sse.Hub
+}
+
+// NewServer creates a Server that will listen at socketPath once
+// ListenAndServe is called.
+func NewServer(socketPath string, ctrl Controller, hub *sse.Hub) *Server {
+	return &Server{path: socketPath, ctrl: ctrl, hub: hub}
+}
+
+// ListenAndServe listens on the server's socket path and serves
+// connections until ctx is canceled. Any stale socket file left over
+// from an unclean shutdown is removed first.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ctlsocket: remove stale socket: %w", err)
+	}
+
+	ln, err := net.Listen("unix", s.path)
+	if err != nil {
+		return fmt.Errorf("ctlsocket: listen: %w", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	slog.Info("ctlsocket listening", "path", s.path)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("ctlsocket: accept: %w", err)
+		}
+		go s.handleConn(ctx, conn)
+	}
+}
+
+// command is a single control-socket request frame.
+type command struct {
+	Type string `json:"type"`
+	Path string `json:"path,omitempty"`
+}
+
+// response is the reply to a command frame.
+type response struct {
+	OK    bool              `json:"ok"`
+	Error string            `json:"error,omitempty"`
+	Video *models.VideoFile `json:"video,omitempty"`
+}
+
+// eventFrame is an unsolicited frame mirroring an SSE broadcast.
+type eventFrame struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func (s *Server) handleConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	client := &sse.Client{
+		ID:     fmt.Sprintf("ctlsocket-%d", time.Now().UnixNano()),
+		Events: make(chan []byte, 256),
+	}
+	s.hub.Register(client, 0)
+	defer s.hub.Unregister(client)
+
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go s.pumpEvents(connCtx, conn, client)
+
+	reader := bufio.NewReader(conn)
+	for {
+		frame, err := readFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Warn("ctlsocket: read failed", "error", err)
+			}
+			return
+		}
+
+		var cmd command
+		var resp response
+		if err := json.Unmarshal(frame, &cmd); err != nil {
+			resp = response{OK: false, Error: "invalid json"}
+		} else {
+			resp = s.dispatch(cmd)
+		}
+
+		if err := writeFrame(conn, resp); err != nil {
+			slog.Warn("ctlsocket: write failed", "error", err)
+			return
+		}
+	}
+}
+
+// pumpEvents forwards hub broadcasts to conn as event frames until ctx is
+// canceled or the client channel closes.
+func (s *Server) pumpEvents(ctx context.Context, conn net.Conn, client *sse.Client) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-client.Events:
+			if !ok {
+				return
+			}
+			event, data, ok := parseSSEFrame(msg)
+			if !ok {
+				continue
+			}
+			if err := writeFrame(conn, eventFrame{Event: event, Data: data}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) dispatch(cmd command) response {
+	switch cmd.Type {
+	case "play":
+		s.ctrl.SetPaused(false)
+		return response{OK: true}
+	case "pause":
+		s.ctrl.SetPaused(true)
+		return response{OK: true}
+	case "next":
+		vf, ok := s.ctrl.QueueNext()
+		if !ok {
+			return response{OK: false, Error: "queue has nothing left to play"}
+		}
+		return response{OK: true, Video: &vf}
+	case "prev":
+		if !s.ctrl.QueuePrev() {
+			return response{OK: false, Error: "nothing to rewind to"}
+		}
+		return response{OK: true}
+	case "force":
+		vf, err := s.ctrl.ForceVideo(cmd.Path)
+		if err != nil {
+			return response{OK: false, Error: err.Error()}
+		}
+		return response{OK: true, Video: &vf}
+	case "queue-add":
+		if err := s.ctrl.QueueAddPath(cmd.Path); err != nil {
+			return response{OK: false, Error: err.Error()}
+		}
+		return response{OK: true}
+	case "set-loop-video":
+		if err := s.ctrl.SetLoopVideo(cmd.Path); err != nil {
+			return response{OK: false, Error: err.Error()}
+		}
+		return response{OK: true}
+	case "analyse":
+		if err := s.ctrl.TriggerAnalysis(); err != nil {
+			return response{OK: false, Error: err.Error()}
+		}
+		return response{OK: true}
+	default:
+		return response{OK: false, Error: "unknown command type: " + cmd.Type}
+	}
+}
+
+// readFrame reads a 4-byte big-endian length prefix followed by that
+// many bytes of payload.
+func readFrame(r io.Reader) ([]byte, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(lenBuf[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("ctlsocket: frame too large (%d bytes)", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// writeFrame marshals v to JSON and writes it as a 4-byte big-endian
+// length prefix followed by the payload.
+func writeFrame(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(data)))
+	if _, err := w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// parseSSEFrame splits a raw "event: X\ndata: Y\n\n" frame (the format
+// sse.Hub broadcasts to clients) into its event name and data payload.
+func parseSSEFrame(raw []byte) (event string, data json.RawMessage, ok bool) {
+	lines := strings.SplitN(string(raw), "\n", 3)
+	if len(lines) < 2 {
+		return "", nil, false
+	}
+	event, ok1 := strings.CutPrefix(lines[0], "event: ")
+	payload, ok2 := strings.CutPrefix(lines[1], "data: ")
+	if !ok1 || !ok2 {
+		return "", nil, false
+	}
+	return event, json.RawMessage(payload), true
+}
@@ -2,31 +2,44 @@ package db
 
 import (
 	"database/sql"
-	"log/slog"
+	"net/url"
 
 	_ "modernc.org/sqlite"
 )
 
+// dsnPragmas are applied by modernc.org/sqlite to every physical
+// connection it opens (see applyQueryParams in its driver), unlike a
+// PRAGMA run once via db.Exec on the pool, which only ever lands on
+// whichever single connection happens to run it. foreign_keys in
+// particular must be set this way: the ON DELETE CASCADE on
+// overlay_element_versions/transition_effect_versions (migration 0007)
+// needs it on *every* connection or it silently doesn't fire on
+// connections opened later by the worker pool or concurrent requests.
+var dsnPragmas = []string{
+	"journal_mode(WAL)",
+	"synchronous(NORMAL)",
+	"busy_timeout(5000)",
+	"foreign_keys(1)",
+}
+
 // Open initialises the SQLite database and ensures the schema exists.
 func Open(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", path)
+	q := url.Values{}
+	for _, p := range dsnPragmas {
+		q.Add("_pragma", p)
+	}
+	dsn := path + "?" + q.Encode()
+
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	// SQLite pragmas for performance
-	pragmas := []string{
-		"PRAGMA journal_mode=WAL",
-		"PRAGMA synchronous=NORMAL",
-		"PRAGMA busy_timeout=5000",
-	}
-	for _, p := range pragmas {
-		if _, err := db.Exec(p); err != nil {
-			slog.Warn("pragma failed", "pragma", p, "error", err)
-		}
+	if err := Migrate(db, Migrations); err != nil {
+		db.Close()
+		return nil, err
 	}
-
-	if err := ensureSchema(db); err != nil {
+	if err := seedDefaults(db); err != nil {
 		db.Close()
 		return nil, err
 	}
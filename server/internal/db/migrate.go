@@ -0,0 +1,296 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// Migrations is the embedded set of numbered schema migration files (see
+// migrations/), rooted so callers see bare filenames like
+// "0001_initial_schema.sql" instead of "migrations/0001_initial_schema.sql".
+var Migrations = func() fs.FS {
+	sub, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}()
+
+// migrationFile is one parsed NNNN_description.sql entry. version is its
+// identity in schema_migrations; checksum is the SHA-256 of its
+// contents, used to detect a migration edited after being applied.
+type migrationFile struct {
+	version  int
+	name     string
+	sql      string
+	checksum string
+}
+
+// loadMigrations reads and parses every NNNN_description.sql file in
+// fsys, sorted ascending by version.
+func loadMigrations(fsys fs.FS) ([]migrationFile, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var files []migrationFile
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		version, err := parseMigrationVersion(e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		contents, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		sum := sha256.Sum256(contents)
+		files = append(files, migrationFile{
+			version:  version,
+			name:     e.Name(),
+			sql:      string(contents),
+			checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].version < files[j].version })
+	return files, nil
+}
+
+// parseMigrationVersion extracts the numeric prefix from a
+// "NNNN_description.sql" filename.
+func parseMigrationVersion(name string) (int, error) {
+	prefix, _, ok := strings.Cut(name, "_")
+	if !ok {
+		return 0, fmt.Errorf("missing version prefix")
+	}
+	version, err := strconv.Atoi(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version prefix %q: %w", prefix, err)
+	}
+	return version, nil
+}
+
+// ensureMigrationsTable creates the bookkeeping table Migrate uses to
+// track which migrations have already run, if it doesn't already exist.
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		checksum   TEXT NOT NULL
+	)`)
+	return err
+}
+
+// Migrate brings db up to date with every migration in fsys, applying
+// unapplied versions in ascending order inside their own transaction. If
+// a migration already recorded in schema_migrations no longer matches
+// the checksum of the file in fsys, Migrate refuses to start — the file
+// was edited after being applied, and silently re-running or skipping it
+// could leave the schema in an inconsistent state.
+func Migrate(db *sql.DB, fsys fs.FS) error {
+	if err := ensureMigrationsTable(db); err != nil {
+		return fmt.Errorf("schema_migrations bootstrap: %w", err)
+	}
+
+	files, err := loadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	applied, err := appliedChecksums(db)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if checksum, ok := applied[f.version]; ok {
+			if checksum != f.checksum {
+				return fmt.Errorf("migration %s: checksum mismatch with already-applied version — file was edited after being applied", f.name)
+			}
+			continue
+		}
+		if legacy, err := legacyAlreadyApplied(db, f.version); err != nil {
+			return fmt.Errorf("migration %s: %w", f.name, err)
+		} else if legacy {
+			if err := recordApplied(db, f); err != nil {
+				return fmt.Errorf("migration %s: %w", f.name, err)
+			}
+			continue
+		}
+		if err := applyMigration(db, f); err != nil {
+			return fmt.Errorf("migration %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// legacyColumns maps a migration version to the video_bpm column(s) it
+// adds, for migrations that predate this versioned system — those
+// columns were previously added by ensureSchema's PRAGMA table_info
+// existence checks, which ran unconditionally on every Open() rather
+// than being tracked in schema_migrations. ALTER TABLE ADD COLUMN has no
+// IF NOT EXISTS guard in SQLite, so replaying one of these against a
+// database that already has the column fails with "duplicate column
+// name" instead of being a no-op — legacyAlreadyApplied below checks for
+// that before Migrate tries to run it.
+var legacyColumns = map[int][]string{
+	2: {"analysis_blob"},
+	3: {"confidence", "first_beat_sec"},
+	4: {"genre"},
+}
+
+// legacyAlreadyApplied reports whether version's effect is already
+// present on db from before this migration system existed. Migrations
+// whose DDL is naturally idempotent (CREATE TABLE/INDEX IF NOT EXISTS)
+// aren't in legacyColumns and are always safe to just run.
+func legacyAlreadyApplied(db *sql.DB, version int) (bool, error) {
+	columns, ok := legacyColumns[version]
+	if !ok {
+		return false, nil
+	}
+	for _, col := range columns {
+		exists, err := columnExists(db, "video_bpm", col)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// columnExists reports whether table has a column named column.
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid int
+		var name, ctype string
+		var notnull, pk int
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// recordApplied marks f as applied without running its SQL — used for
+// a legacy-detected migration whose effect already exists.
+func recordApplied(db *sql.DB, f migrationFile) error {
+	_, err := db.Exec(
+		"INSERT INTO schema_migrations (version, checksum) VALUES (?, ?)",
+		f.version, f.checksum,
+	)
+	return err
+}
+
+// appliedChecksums returns the checksum recorded for every migration
+// version already applied to db.
+func appliedChecksums(db *sql.DB) (map[int]string, error) {
+	rows, err := db.Query("SELECT version, checksum FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = checksum
+	}
+	return applied, rows.Err()
+}
+
+// applyMigration runs one migration file and records it in
+// schema_migrations, both inside the same transaction so a failure
+// partway through a migration's SQL never leaves it recorded as applied.
+func applyMigration(db *sql.DB, f migrationFile) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(f.sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, checksum) VALUES (?, ?)",
+		f.version, f.checksum,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatus describes one migration's position relative to fsys
+// and db, for the /api/admin/migrations endpoint.
+type MigrationStatus struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt string `json:"appliedAt,omitempty"`
+}
+
+// MigrationsStatus reports every migration in fsys alongside whether
+// it's been applied to db, in ascending version order.
+func MigrationsStatus(db *sql.DB, fsys fs.FS) ([]MigrationStatus, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+	files, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	appliedAt := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(files))
+	for i, f := range files {
+		at, ok := appliedAt[f.version]
+		status[i] = MigrationStatus{Version: f.version, Name: f.name, Applied: ok, AppliedAt: at}
+	}
+	return status, nil
+}
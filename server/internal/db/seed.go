@@ -2,124 +2,39 @@ package db
 
 import "database/sql"
 
-// ensureSchema creates the initial database tables and seeds default config.
-func ensureSchema(db *sql.DB) error {
-	const schema = `
-	CREATE TABLE IF NOT EXISTS config (
-		key   TEXT PRIMARY KEY,
-		value TEXT NOT NULL
-	);
-
-	-- Default config values (inserted only if not present)
-	INSERT OR IGNORE INTO config (key, value) VALUES ('videos_dir', './videos');
-	INSERT OR IGNORE INTO config (key, value) VALUES ('transition_videos_dir', './transition-videos');
-	INSERT OR IGNORE INTO config (key, value) VALUES ('transition_duration', '3');
-	INSERT OR IGNORE INTO config (key, value) VALUES ('transition_enabled', '1');
-
-	-- Cached BPM values for video files (avoids re-analysis)
-	CREATE TABLE IF NOT EXISTS video_bpm (
-		path       TEXT PRIMARY KEY,   -- absolute file path
-		bpm        REAL NOT NULL,      -- detected BPM
-		mod_time   INTEGER NOT NULL,   -- file modification time (Unix seconds)
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	-- CSS transition effects for transition videos
-	CREATE TABLE IF NOT EXISTS transition_effects (
-		id         INTEGER PRIMARY KEY AUTOINCREMENT,
-		name       TEXT NOT NULL,                    -- e.g. "Fade In"
-		direction  TEXT NOT NULL CHECK(direction IN ('in', 'out')), -- "in" or "out"
-		css        TEXT NOT NULL,                    -- CSS keyframes / styles
-		enabled    INTEGER NOT NULL DEFAULT 1,       -- 1 = enabled, 0 = disabled
-		is_seed    INTEGER NOT NULL DEFAULT 0,       -- 1 = built-in (cannot be deleted)
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	-- Overlay elements shown on top of the player video
-	CREATE TABLE IF NOT EXISTS overlay_elements (
-		id         INTEGER PRIMARY KEY AUTOINCREMENT,
-		key        TEXT NOT NULL UNIQUE,              -- unique identifier e.g. "progress", "bpm"
-		name       TEXT NOT NULL,                     -- display name
-		enabled    INTEGER NOT NULL DEFAULT 1,        -- 1 = visible, 0 = hidden
-		css        TEXT NOT NULL DEFAULT '',           -- CSS styles
-		html       TEXT NOT NULL DEFAULT '',           -- HTML template
-		js         TEXT NOT NULL DEFAULT '',           -- JavaScript update logic
-		is_seed    INTEGER NOT NULL DEFAULT 0,         -- 1 = built-in
-		data_type  TEXT NOT NULL DEFAULT 'verb',       -- "verb" or "custom"
-		verb       TEXT NOT NULL DEFAULT '',            -- VDJ verb
-		config     TEXT NOT NULL DEFAULT '{}',          -- JSON config
-		show_over_transition INTEGER NOT NULL DEFAULT 1, -- 1 = show above transition videos
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	_, err := db.Exec(schema)
-	if err != nil {
-		return err
-	}
-
-	// Migrate existing tables: add enabled and is_seed columns if missing.
-	migrateColumns := []struct {
-		name, ddl string
-	}{
-		{"enabled", "ALTER TABLE transition_effects ADD COLUMN enabled INTEGER NOT NULL DEFAULT 1"},
-		{"is_seed", "ALTER TABLE transition_effects ADD COLUMN is_seed INTEGER NOT NULL DEFAULT 0"},
-	}
-	for _, mc := range migrateColumns {
-		// Check if column exists by querying pragma.
-		var found bool
-		rows, err := db.Query("PRAGMA table_info(transition_effects)")
-		if err != nil {
-			return err
-		}
-		for rows.Next() {
-			var cid int
-			var cname, ctype string
-			var notnull, pk int
-			var dflt sql.NullString
-			if rows.Scan(&cid, &cname, &ctype, &notnull, &dflt, &pk) == nil && cname == mc.name {
-				found = true
-			}
-		}
-		rows.Close()
-		if !found {
-			if _, err := db.Exec(mc.ddl); err != nil {
-				return err
-			}
-		}
+// seedDefaults inserts default config values and built-in transition
+// effects/overlay elements if they're not already present. Idempotent —
+// every insert uses INSERT OR IGNORE (or, for transition effects, relies
+// on the idx_seed_effects unique index from migration 0005) so it's safe
+// to call on every Open(), which is how new built-in seeds introduced by
+// a later release reach an existing database without a schema change.
+func seedDefaults(db *sql.DB) error {
+	configDefaults := []struct{ key, value string }{
+		{"videos_dir", "./videos"},
+		{"transition_videos_dir", "./transition-videos"},
+		{"transition_duration", "3"},
+		{"transition_enabled", "1"},
+		{"harmonic_mixing_enabled", "0"},
+		{"transition_mode", "eof"},
+		{"transition_phrase_beats", "32"},
+		{"ndi_enabled", "0"},
+		{"ndi_source_name", "vdj-video-sync"},
+		{"discogs_token", ""},
+		{"voting_enabled", "0"},
+		{"voting_quorum", "3"},
+		{"pack_js_max_bytes", "32768"},
+		{"pack_js_denylist", "fetch(,XMLHttpRequest,WebSocket,eval(,Function(,document.cookie,localStorage,sessionStorage,indexedDB,import(,sendBeacon"},
+		{"pack_url_allowlist", ""},
+		{"overlay_history_limit", "50"},
 	}
-
-	// Migrate overlay_elements: add show_over_transition column if missing.
-	{
-		var found bool
-		rows, err := db.Query("PRAGMA table_info(overlay_elements)")
-		if err != nil {
+	for _, c := range configDefaults {
+		if _, err := db.Exec("INSERT OR IGNORE INTO config (key, value) VALUES (?, ?)", c.key, c.value); err != nil {
 			return err
 		}
-		for rows.Next() {
-			var cid int
-			var cname, ctype string
-			var notnull, pk int
-			var dflt sql.NullString
-			if rows.Scan(&cid, &cname, &ctype, &notnull, &dflt, &pk) == nil && cname == "show_over_transition" {
-				found = true
-			}
-		}
-		rows.Close()
-		if !found {
-			if _, err := db.Exec("ALTER TABLE overlay_elements ADD COLUMN show_over_transition INTEGER NOT NULL DEFAULT 1"); err != nil {
-				return err
-			}
-			// Set song_name and artist to NOT show over transition by default
-			db.Exec("UPDATE overlay_elements SET show_over_transition = 0 WHERE key IN ('song_name', 'artist')")
-		}
 	}
 
-	// Seed built-in transition effects (idempotent — uses INSERT OR IGNORE
-	// with a unique constraint on name+direction+is_seed to avoid duplicates).
-	// We create a unique index if it does not exist.
-	_, _ = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_seed_effects ON transition_effects (name, direction, is_seed) WHERE is_seed = 1")
-
+	// Seed built-in transition effects (idempotent via idx_seed_effects,
+	// see migration 0005).
 	seeds := []struct {
 		name, direction, css string
 	}{
@@ -279,6 +194,16 @@ func ensureSchema(db *sql.DB) error {
 			"INSERT OR IGNORE INTO transition_effects (name, direction, css, enabled, is_seed) VALUES (?, ?, ?, 1, 1)",
 			s.name, s.direction, s.css,
 		)
+		var id int
+		if err := db.QueryRow("SELECT id FROM transition_effects WHERE name = ? AND direction = ?", s.name, s.direction).Scan(&id); err == nil {
+			// Revision 0 is this seed's original name/direction/css — see
+			// transitions.Store.Revert, which RestoreDefaults-equivalent
+			// callers use to undo every edit back to it.
+			_, _ = db.Exec(
+				"INSERT OR IGNORE INTO transition_effect_versions (element_id, revision, name, direction, css, note) VALUES (?, 0, ?, ?, ?, 'seed')",
+				id, s.name, s.direction, s.css,
+			)
+		}
 	}
 
 	// ── Overlay element seeds ───────────────────────────────
@@ -626,11 +551,140 @@ func ensureSchema(db *sql.DB) error {
 		},
 	}
 
+	overlaySeeds = append(overlaySeeds, struct {
+		key, name, css, html, js, dataType, verb, config string
+		showOverTransition                               int
+	}{
+		key:                "bpm_verify",
+		name:               "BPM Verify (Mic Tap)",
+		dataType:           "custom",
+		verb:               "",
+		config:             "{}",
+		showOverTransition: 0,
+		css: `.overlay-bpm-verify {
+  position: absolute;
+  bottom: 40px;
+  right: 40px;
+  z-index: 100;
+  pointer-events: auto;
+  display: flex;
+  align-items: center;
+  gap: 10px;
+  background: rgba(0,0,0,0.55);
+  backdrop-filter: blur(12px);
+  padding: 10px 18px;
+  border-radius: 12px;
+  border: 1px solid rgba(255,255,255,0.1);
+  font-family: 'Segoe UI', system-ui, sans-serif;
+}
+.overlay-bpm-verify-btn {
+  background: #6366f1;
+  color: #fff;
+  border: none;
+  border-radius: 8px;
+  padding: 6px 14px;
+  font-size: 14px;
+  font-weight: 600;
+  cursor: pointer;
+}
+.overlay-bpm-verify-btn:hover {
+  background: #818cf8;
+}
+.overlay-bpm-verify-status {
+  color: rgba(255,255,255,0.85);
+  font-size: 13px;
+}`,
+		html: `<div class="overlay-bpm-verify">
+  <button class="overlay-bpm-verify-btn" data-overlay-bpm-verify-btn type="button">Verify BPM</button>
+  <span class="overlay-bpm-verify-status" data-overlay-bpm-verify-status></span>
+</div>`,
+		js: `(function(el, deck) {
+  var btn = el.querySelector('[data-overlay-bpm-verify-btn]');
+  var status = el.querySelector('[data-overlay-bpm-verify-status]');
+  if (!btn || !status || el.__bpmVerifyBound) return;
+  el.__bpmVerifyBound = true;
+
+  btn.addEventListener('click', function() {
+    if (!deck || !deck.filename) {
+      status.textContent = 'no active track';
+      return;
+    }
+    status.textContent = 'listening…';
+    navigator.mediaDevices.getUserMedia({ audio: true }).then(function(stream) {
+      var ctx = new (window.AudioContext || window.webkitAudioContext)();
+      var source = ctx.createMediaStreamSource(stream);
+      var analyser = ctx.createAnalyser();
+      analyser.fftSize = 2048;
+      source.connect(analyser);
+
+      // Sample the onset envelope (RMS per frame) for a few seconds, then
+      // autocorrelate it to find the dominant inter-beat interval.
+      var frameMs = 50;
+      var captureMs = 8000;
+      var envelope = [];
+      var buf = new Float32Array(analyser.fftSize);
+      var timer = setInterval(function() {
+        analyser.getFloatTimeDomainData(buf);
+        var sum = 0;
+        for (var i = 0; i < buf.length; i++) sum += buf[i] * buf[i];
+        envelope.push(Math.sqrt(sum / buf.length));
+      }, frameMs);
+
+      setTimeout(function() {
+        clearInterval(timer);
+        stream.getTracks().forEach(function(t) { t.stop(); });
+        ctx.close();
+
+        var envRate = 1000 / frameMs;
+        var minLag = Math.floor(envRate * 60 / 180); // 180 BPM
+        var maxLag = Math.floor(envRate * 60 / 60);  // 60 BPM
+        var bestLag = -1, bestScore = -Infinity;
+        for (var lag = minLag; lag <= maxLag && lag < envelope.length; lag++) {
+          var score = 0;
+          for (var i = 0; i + lag < envelope.length; i++) {
+            score += envelope[i] * envelope[i + lag];
+          }
+          if (score > bestScore) { bestScore = score; bestLag = lag; }
+        }
+        if (bestLag <= 0) {
+          status.textContent = 'could not detect a tempo';
+          return;
+        }
+        var bpm = Math.round((60 * envRate / bestLag) * 10) / 10;
+        status.textContent = 'detected ' + bpm + ' BPM — saving…';
+
+        fetch('/api/bpm/override', {
+          method: 'PUT',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ path: deck.filename, bpm: bpm, source: 'mic-tap' })
+        }).then(function(res) {
+          status.textContent = res.ok ? ('saved ' + bpm + ' BPM') : 'save failed';
+        }).catch(function() {
+          status.textContent = 'save failed';
+        });
+      }, captureMs);
+    }).catch(function() {
+      status.textContent = 'microphone access denied';
+    });
+  });
+})`,
+	})
+
 	for _, s := range overlaySeeds {
 		_, _ = db.Exec(
 			`INSERT OR IGNORE INTO overlay_elements (key, name, css, html, js, is_seed, data_type, verb, config, enabled, show_over_transition) VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?, 1, ?)`,
 			s.key, s.name, s.css, s.html, s.js, s.dataType, s.verb, s.config, s.showOverTransition,
 		)
+		var id int
+		if err := db.QueryRow("SELECT id FROM overlay_elements WHERE key = ?", s.key).Scan(&id); err == nil {
+			// Revision 0 is this seed's original name/css/html/js/config —
+			// see overlay.Store.Revert, which RestoreDefaults now is a thin
+			// alias for instead of the old hardcoded seedDefaults() lookup.
+			_, _ = db.Exec(
+				`INSERT OR IGNORE INTO overlay_element_versions (element_id, revision, name, css, html, js, config, show_over_transition, note) VALUES (?, 0, ?, ?, ?, ?, ?, ?, 'seed')`,
+				id, s.name, s.css, s.html, s.js, s.config, s.showOverTransition,
+			)
+		}
 	}
 
 	return nil
@@ -1,22 +1,45 @@
 package handlers
 
 import (
+	"context"
+	cryptorand "crypto/rand"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"math/rand/v2"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/jota2rz/vdj-video-sync/server/internal/bpm"
 	"github.com/jota2rz/vdj-video-sync/server/internal/config"
+	"github.com/jota2rz/vdj-video-sync/server/internal/db"
+	"github.com/jota2rz/vdj-video-sync/server/internal/hls"
 	"github.com/jota2rz/vdj-video-sync/server/internal/models"
+	"github.com/jota2rz/vdj-video-sync/server/internal/musickey"
+	"github.com/jota2rz/vdj-video-sync/server/internal/output/ndi"
+	"github.com/jota2rz/vdj-video-sync/server/internal/overlay"
+	"github.com/jota2rz/vdj-video-sync/server/internal/pack"
+	"github.com/jota2rz/vdj-video-sync/server/internal/poll"
+	"github.com/jota2rz/vdj-video-sync/server/internal/queue"
+	"github.com/jota2rz/vdj-video-sync/server/internal/reconcile"
+	"github.com/jota2rz/vdj-video-sync/server/internal/scanner"
+	"github.com/jota2rz/vdj-video-sync/server/internal/scheduler"
 	"github.com/jota2rz/vdj-video-sync/server/internal/sse"
+	"github.com/jota2rz/vdj-video-sync/server/internal/stream"
 	"github.com/jota2rz/vdj-video-sync/server/internal/transitions"
 	"github.com/jota2rz/vdj-video-sync/server/internal/video"
+	"github.com/jota2rz/vdj-video-sync/server/internal/ws"
 	"github.com/jota2rz/vdj-video-sync/server/templates/pages"
 )
 
@@ -28,6 +51,13 @@ type Handlers struct {
 	transitionMatcher *video.Matcher
 	transitions       *transitions.Store
 
+	// Operator-curated playlist, consulted before matcher.Match. Protected
+	// by queueMu since it's touched from both the HTTP queue endpoints and
+	// HandleVideoEnded.
+	queueMu    sync.Mutex
+	queue      *queue.Queue
+	queueCache []byte // cached "queue-updated" SSE event for new-client replay
+
 	// Logging state: track last-logged values and times per deck.
 	// Protected by logMu since HandleDeckUpdate, HandleForceVideo, and
 	// HandleVideoEnded can run concurrently.
@@ -80,6 +110,73 @@ type Handlers struct {
 	// playback position server-side so all clients stay synchronised.
 	videoSyncMu sync.Mutex
 	videoSync   map[int]*deckVideoSync // keyed by deck number
+
+	// Preload scheduling: tracks which (deck, video) pairs have already
+	// had a "video-preload" event issued, so we fire it exactly once per
+	// video per deck instead of on every HandleDeckUpdate tick.
+	preloadMu    sync.Mutex
+	preloadedFor map[int]string // keyed by deck number, value is video path
+
+	// Per-deck HLS output. hlsMgr may be nil if HLS output is disabled.
+	hlsMgr      *hls.Manager
+	hlsMu       sync.Mutex
+	hlsLastPath map[int]string // keyed by deck number, value is served video path
+
+	// Live HTTP-FLV/RTMP output of the active deck's mix. liveMgr may be
+	// nil if live output is disabled.
+	liveMgr *stream.Manager
+
+	// Optional NDI mirror of the active deck's mix. ndiMgr is a no-op
+	// unless this binary was built with the "ndi" tag — see
+	// internal/output/ndi's package doc.
+	ndiMgr *ndi.Manager
+
+	// Beat-grid transition scheduling (transition_mode "phrase"/"bar").
+	// txScheduler arms one timer per deck; scheduledFor tracks which
+	// (deck, video) pairs have already been armed, so a deck's grid is
+	// only (re)computed once per video instead of on every deck-update
+	// tick.
+	txScheduler  *scheduler.Scheduler
+	scheduleMu   sync.Mutex
+	scheduledFor map[int]string // keyed by deck number, value is video path
+
+	// Accepted external-metadata matches (internal/reconcile), keyed by
+	// path+modTime. reconcileStore is nil-safe — requests served before
+	// it's wired in just see no reconciled metadata.
+	reconcileStore     *reconcile.Store
+	reconcileProviders []reconcile.Provider
+
+	// Audience voting (internal/poll): pollStore persists sessions/votes;
+	// pollHub fans out tally updates to connected WebSocket clients.
+	// overlayStore is consulted to resolve the "overlay" subject's option
+	// names and to feature the winning element; it's nil-safe like
+	// reconcileStore if not wired in.
+	pollStore    *poll.Store
+	pollHub      *poll.Hub
+	overlayStore *overlay.Store
+
+	// bpmCache is consulted by HandleBPMOverride to record a manually
+	// verified BPM (see bpm.Cache.SetOverride) alongside the video
+	// matchers' own automatically analysed values.
+	bpmCache *bpm.Cache
+
+	// database is the raw handle db.Open returned. Most handlers go
+	// through cfg or a narrower *Store wrapper instead of touching SQL
+	// directly; database is kept around for the few that can't —
+	// HandleMigrationsStatus, and HandleImportPack's pack.Apply, which
+	// needs a shared transaction spanning both overlayStore's and
+	// transitions' tables.
+	database *sql.DB
+
+	// scanner drives the background video_bpm warm-up pass over the
+	// library directories (see internal/scanner); nil-safe like
+	// reconcileStore/pollStore if not wired in.
+	scanner *scanner.Scanner
+
+	// previewHub fans out unsaved overlay-element edits to subscribed
+	// /ws/overlay-preview clients (see HandleOverlayPreview); nil-safe
+	// like reconcileStore/pollStore if not wired in.
+	previewHub *overlay.Hub
 }
 
 // deckVideoSync tracks video playback position for match levels 2+.
@@ -99,35 +196,64 @@ type activeDeckInfo struct {
 	IsPlaying bool
 	Volume    float64
 	HasVideo  bool
+	Key       string  // matched video's musical key, Camelot notation; "" if unknown
+	BPM       float64 // matched video's BPM; 0 if unknown
 }
 
 // transitionPoolEntry is a single slot in the server's 2-slot transition pool.
 type transitionPoolEntry struct {
-	Video string  `json:"video"`
-	BPM   float64 `json:"bpm,omitempty"`
+	Video  string  `json:"video"`
+	BPM    float64 `json:"bpm,omitempty"`
+	GainDB float64 `json:"gainDb,omitempty"` // ReplayGain-style adjustment for level-matched playback
+	Peak   float64 `json:"peak,omitempty"`   // true peak, dBTP
 }
 
 // New creates a Handlers instance.
-func New(cfg *config.Config, hub *sse.Hub, matcher *video.Matcher, transitionMatcher *video.Matcher, ts *transitions.Store) *Handlers {
+func New(cfg *config.Config, hub *sse.Hub, matcher *video.Matcher, transitionMatcher *video.Matcher, ts *transitions.Store, hlsMgr *hls.Manager, liveMgr *stream.Manager, ndiMgr *ndi.Manager, reconcileStore *reconcile.Store, pollStore *poll.Store, overlayStore *overlay.Store, bpmCache *bpm.Cache, database *sql.DB, scannerInstance *scanner.Scanner, previewHub *overlay.Hub) *Handlers {
 	return &Handlers{
 		cfg:               cfg,
 		hub:               hub,
 		matcher:           matcher,
 		transitionMatcher: transitionMatcher,
 		transitions:       ts,
-		lastLogState:      make(map[int]models.DeckState),
-		lastLogTime:       make(map[int]time.Time),
-		deckCache:         make(map[int][]byte),
-		activeDeckStates:  make(map[int]*activeDeckInfo),
-		deckVisible:       make(map[int]bool),
-		deckHideTimer:     make(map[int]*time.Timer),
-		visCache:          make(map[int][]byte),
-		forcedVideo:       make(map[int]*models.VideoFile),
-		forcedFilename:    make(map[int]string),
-		videoSync:         make(map[int]*deckVideoSync),
+		queue:             queue.New(),
+		hlsMgr:            hlsMgr,
+		hlsLastPath:       make(map[int]string),
+		liveMgr:           liveMgr,
+		ndiMgr:            ndiMgr,
+		txScheduler:       scheduler.New(),
+		scheduledFor:      make(map[int]string),
+		reconcileStore:    reconcileStore,
+		reconcileProviders: []reconcile.Provider{
+			reconcile.NewMusicBrainzProvider(),
+			reconcile.NewAcoustIDProvider(),
+			reconcile.NewDiscogsProvider(cfg.Get("discogs_token", "")),
+		},
+		lastLogState:     make(map[int]models.DeckState),
+		lastLogTime:      make(map[int]time.Time),
+		deckCache:        make(map[int][]byte),
+		activeDeckStates: make(map[int]*activeDeckInfo),
+		deckVisible:      make(map[int]bool),
+		deckHideTimer:    make(map[int]*time.Timer),
+		visCache:         make(map[int][]byte),
+		forcedVideo:      make(map[int]*models.VideoFile),
+		forcedFilename:   make(map[int]string),
+		videoSync:        make(map[int]*deckVideoSync),
+		preloadedFor:     make(map[int]string),
+		pollStore:        pollStore,
+		pollHub:          poll.NewHub(),
+		overlayStore:     overlayStore,
+		bpmCache:         bpmCache,
+		database:         database,
+		scanner:          scannerInstance,
+		previewHub:       previewHub,
 	}
 }
 
+// defaultPreloadLeadSeconds is how far from the end of a video we issue
+// a video-preload event if "preload_lead_seconds" isn't configured.
+const defaultPreloadLeadSeconds = 3.0
+
 // ── Plugin API ──────────────────────────────────────────
 
 // BroadcastLibraryUpdated sends a library-updated SSE event to all clients,
@@ -140,10 +266,39 @@ func (h *Handlers) BroadcastLibraryUpdated(libraryType string) {
 
 	// If the song library changed, verify the loop video still exists.
 	// If the file was deleted, clear the config so clients stop using
-	// a stale path.
+	// a stale path. If the transition library changed, verify the pool
+	// itself doesn't reference a deleted/renamed file.
 	if libraryType == "song" {
 		h.checkLoopVideoExists()
+	} else if libraryType == "transition" {
+		h.checkTransitionPoolVideosExist()
+	}
+}
+
+// checkTransitionPoolVideosExist verifies every filled transition pool
+// slot still points at a file present in the transition library. Any
+// slot whose video was deleted or renamed out from under it is cleared
+// and refilled, so connected clients never try to play a missing asset.
+func (h *Handlers) checkTransitionPoolVideosExist() {
+	h.activeDeckMu.Lock()
+	defer h.activeDeckMu.Unlock()
+
+	var invalidated bool
+	for i, e := range h.transitionPool {
+		if e == nil {
+			continue
+		}
+		if _, ok := h.transitionMatcher.GetByPath(e.Video); !ok {
+			slog.Info("transition pool video no longer exists, invalidating slot", "slot", i, "video", e.Video)
+			h.transitionPool[i] = nil
+			invalidated = true
+		}
 	}
+	if !invalidated {
+		return
+	}
+	h.fillTransitionPool()
+	h.broadcastTransitionPool()
 }
 
 // checkLoopVideoExists verifies that the configured loop_video path still
@@ -192,6 +347,72 @@ func (h *Handlers) checkLoopVideoExists() {
 	}
 }
 
+// SetLoopVideo sets the configured loop_video path and enables
+// loop_video_enabled, broadcasting both config changes via SSE. Returns
+// ErrVideoNotFound if path isn't in the song library.
+func (h *Handlers) SetLoopVideo(path string) error {
+	if _, ok := h.matcher.GetByPath(path); !ok {
+		return ErrVideoNotFound
+	}
+
+	if err := h.cfg.Set("loop_video", path); err != nil {
+		return err
+	}
+	if err := h.cfg.Set("loop_video_enabled", "1"); err != nil {
+		return err
+	}
+
+	for key, value := range map[string]string{"loop_video": path, "loop_video_enabled": "1"} {
+		payload, _ := json.Marshal(map[string]string{"key": key, "value": value})
+		sseMsg := fmt.Appendf(nil, "event: config-updated\ndata: %s\n\n", payload)
+		h.deckCacheMu.Lock()
+		if h.configCache == nil {
+			h.configCache = make(map[string][]byte)
+		}
+		h.configCache[key] = sseMsg
+		h.deckCacheMu.Unlock()
+		h.hub.Broadcast("config-updated", payload)
+	}
+	return nil
+}
+
+// ErrAnalysisInProgress is returned by TriggerAnalysis when a scan is
+// already running.
+var ErrAnalysisInProgress = errors.New("analysis already in progress")
+
+// TriggerAnalysis kicks off an out-of-band library rescan, mirroring the
+// startup scan in main.go. Returns ErrAnalysisInProgress if a scan is
+// already running; otherwise the scan runs asynchronously and callers
+// should watch the analysis-status SSE event for completion.
+func (h *Handlers) TriggerAnalysis() error {
+	h.analysingMu.Lock()
+	busy := h.analysing
+	h.analysingMu.Unlock()
+	if busy {
+		return ErrAnalysisInProgress
+	}
+
+	go func() {
+		h.SetAnalysing(true)
+		h.matcher.Scan()
+		h.transitionMatcher.Scan()
+		h.SetAnalysing(false)
+		h.BroadcastLibraryUpdated("song")
+		h.BroadcastLibraryUpdated("transition")
+	}()
+	return nil
+}
+
+// SetPaused records the operator's desired playback-paused state and
+// broadcasts it via SSE so connected clients (and the VDJ plugin, via
+// its own polling) can react. This is advisory — the server does not
+// control VDJ playback directly — so it's only ever a hint clients
+// choose to honour.
+func (h *Handlers) SetPaused(paused bool) {
+	data, _ := json.Marshal(map[string]bool{"paused": paused})
+	h.hub.Broadcast("playback-paused", data)
+}
+
 // SetAnalysing updates the analysis flag and broadcasts the status via SSE.
 func (h *Handlers) SetAnalysing(v bool) {
 	h.analysingMu.Lock()
@@ -216,6 +437,13 @@ func (h *Handlers) SetAnalysing(v bool) {
 // maxDecks is the maximum number of decks this application supports.
 const maxDecks = 4
 
+// deckTopic is the SSE topic a "deck-update" event for the given deck is
+// published under, letting a client subscribe to just one deck (see
+// HandleSSE's "topics" query param) instead of every deck's updates.
+func deckTopic(deck int) string {
+	return fmt.Sprintf("deck-%d", deck)
+}
+
 // HandleDeckUpdate receives deck state from the VDJ plugin.
 func (h *Handlers) HandleDeckUpdate(w http.ResponseWriter, r *http.Request) {
 	// Ignore VDJ updates while BPM analysis is running
@@ -263,7 +491,7 @@ func (h *Handlers) HandleDeckUpdate(w http.ResponseWriter, r *http.Request) {
 	h.forcedMu.Unlock()
 
 	if matched == nil {
-		if v, ok := h.matcher.Match(state.Filename, state.BPM); ok {
+		if v, ok := h.matcher.Match(state.Filename, state.BPM, state.Key); ok {
 			matched = &v
 		}
 	}
@@ -314,17 +542,40 @@ func (h *Handlers) HandleDeckUpdate(w http.ResponseWriter, r *http.Request) {
 		h.videoSyncMu.Unlock()
 	}
 
+	hlsPlaylist := h.updateHLSSession(state.Deck, matched, videoElapsedMs)
+	h.updateLiveStreamSource(state.Deck, matched)
+	h.updateNDISource(state.Deck, matched)
+
+	// Authoritative external metadata, if the operator has accepted a
+	// reconciliation match for this video (internal/reconcile). Overrides
+	// VDJ's own get_title/get_artist verbs, which are often missing or
+	// just the filename.
+	var reconciled *reconcile.Metadata
+	if h.reconcileStore != nil && matched != nil {
+		if absPath, modTime, ok := h.reconcileModTime(matched.Path); ok {
+			if m, ok := h.reconcileStore.Get(absPath, modTime); ok {
+				state.Title = m.Name
+				state.Artist = m.Artist
+				reconciled = &m
+			}
+		}
+	}
+
 	// Build the event payload
 	event := struct {
 		models.DeckState
-		Timestamp      time.Time         `json:"timestamp"`
-		Video          *models.VideoFile `json:"video,omitempty"`
-		VideoElapsedMs *float64          `json:"videoElapsedMs,omitempty"`
+		Timestamp      time.Time           `json:"timestamp"`
+		Video          *models.VideoFile   `json:"video,omitempty"`
+		VideoElapsedMs *float64            `json:"videoElapsedMs,omitempty"`
+		HLSPlaylist    string              `json:"hlsPlaylist,omitempty"`
+		Metadata       *reconcile.Metadata `json:"metadata,omitempty"`
 	}{
 		DeckState:      state,
 		Timestamp:      time.Now(),
 		Video:          matched,
 		VideoElapsedMs: videoElapsedMs,
+		HLSPlaylist:    hlsPlaylist,
+		Metadata:       reconciled,
 	}
 
 	data, _ := json.Marshal(event)
@@ -336,13 +587,29 @@ func (h *Handlers) HandleDeckUpdate(w http.ResponseWriter, r *http.Request) {
 	// hasn't been preloaded yet → direct swap with no transition video.
 	h.checkActiveDeckChange(state, matched)
 
-	// Cache the latest event per deck (for new-client replay) and
-	// broadcast immediately to all connected SSE clients.
+	// Cache the latest event per deck (for new-client replay). The cache
+	// always holds the full-quality Video.Path; live clients get their
+	// own bandwidth-appropriate variant substituted below.
 	sseMsg := fmt.Appendf(nil, "event: deck-update\ndata: %s\n\n", data)
 	h.deckCacheMu.Lock()
 	h.deckCache[state.Deck] = sseMsg
 	h.deckCacheMu.Unlock()
-	h.hub.Broadcast("deck-update", data)
+
+	h.hub.BroadcastPerClient(deckTopic(state.Deck), "deck-update", func(c *sse.Client) []byte {
+		if matched == nil || len(matched.Variants) == 0 || c.Bandwidth == nil {
+			return data
+		}
+		path := pickVariantPath(matched, c.Bandwidth.EstimatedBps())
+		if path == matched.Path {
+			return data
+		}
+		clientEvent := event
+		v := *matched
+		v.Path = path
+		clientEvent.Video = &v
+		clientData, _ := json.Marshal(clientEvent)
+		return clientData
+	})
 
 	// ── Deck 3/4 visibility ──
 	if state.Deck > 2 && state.Deck <= maxDecks {
@@ -369,34 +636,183 @@ func (h *Handlers) HandleDeckUpdate(w http.ResponseWriter, r *http.Request) {
 
 	h.lastLogState[state.Deck] = state
 	h.logMu.Unlock()
+
+	h.checkPreloadWindow(state, matched)
+	h.checkTransitionSchedule(state, matched)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// HandleForceVideo forces a specific video to be used for the current active
-// deck. Triggers a transition and immediately broadcasts the updated deck
-// state with the forced video. The override persists until the deck's song
-// (filename) changes.
-func (h *Handlers) HandleForceVideo(w http.ResponseWriter, r *http.Request) {
-	defer r.Body.Close()
-	body, err := io.ReadAll(io.LimitReader(r.Body, 4096))
-	if err != nil {
-		http.Error(w, "bad request", http.StatusBadRequest)
+// checkPreloadWindow broadcasts a "video-preload" SSE event once a deck's
+// matched video (match level 2+, where the server owns video switching)
+// is within preload_lead_seconds of ending, so clients can fetch and
+// decode the next candidate before the current video finishes — the
+// same idea as librespot's track preloading, applied to video handoff.
+// Fires at most once per (deck, video) pair via the preloadedFor guard.
+func (h *Handlers) checkPreloadWindow(state models.DeckState, matched *models.VideoFile) {
+	if matched == nil || matched.MatchLevel < 2 || matched.Duration <= 0 {
 		return
 	}
 
-	var req struct {
+	lead := defaultPreloadLeadSeconds
+	if v, err := strconv.ParseFloat(h.cfg.Get("preload_lead_seconds", ""), 64); err == nil && v > 0 {
+		lead = v
+	}
+
+	h.videoSyncMu.Lock()
+	vs := h.videoSync[state.Deck]
+	var remainingSeconds float64 = -1
+	var playing bool
+	if vs != nil && vs.videoPath == matched.Path {
+		rate := vs.lastRate
+		if rate <= 0 {
+			rate = 1.0
+		}
+		remainingSeconds = (matched.Duration*1000 - vs.accumulatedMs) / 1000 / rate
+		playing = vs.playing
+	}
+	h.videoSyncMu.Unlock()
+
+	if !playing || remainingSeconds < 0 || remainingSeconds > lead {
+		return
+	}
+
+	h.preloadMu.Lock()
+	if h.preloadedFor[state.Deck] == matched.Path {
+		h.preloadMu.Unlock()
+		return
+	}
+	h.preloadedFor[state.Deck] = matched.Path
+	h.preloadMu.Unlock()
+
+	next := h.peekNextVideo(matched.Path, state.BPM)
+	if next == nil {
+		return
+	}
+
+	payload := struct {
+		Deck int    `json:"deck"`
 		Path string `json:"path"`
+	}{Deck: state.Deck, Path: next.Path}
+	data, _ := json.Marshal(payload)
+	h.hub.Broadcast("video-preload", data)
+	slog.Info("video preload", "deck", state.Deck, "current", matched.Path, "next", next.Path, "remaining", remainingSeconds)
+}
+
+// defaultPhraseBeats is how many beats make up a "phrase" boundary when
+// transition_mode is "phrase" and transition_phrase_beats isn't set.
+const defaultPhraseBeats = 32
+
+// barBeats is how many beats make up a "bar" boundary when transition_mode
+// is "bar" (standard 4/4 time).
+const barBeats = 4
+
+// checkTransitionSchedule (re)arms the beat-grid scheduler for a deck when
+// transition_mode is "phrase" or "bar", so the active deck's transitions
+// land on a downbeat instead of at end-of-file. A no-op in the default
+// "eof" mode. Arms at most once per (deck, video) pair — see
+// armTransitionBoundary for how it keeps re-arming after that.
+func (h *Handlers) checkTransitionSchedule(state models.DeckState, matched *models.VideoFile) {
+	mode := h.cfg.Get("transition_mode", "eof")
+	if mode == "eof" || matched == nil || matched.BPM <= 0 || !state.IsPlaying {
+		h.txScheduler.Cancel(state.Deck)
+		h.scheduleMu.Lock()
+		delete(h.scheduledFor, state.Deck)
+		h.scheduleMu.Unlock()
+		return
 	}
-	if err := json.Unmarshal(body, &req); err != nil || req.Path == "" {
-		http.Error(w, "invalid json: path required", http.StatusBadRequest)
+
+	h.scheduleMu.Lock()
+	if h.scheduledFor[state.Deck] == matched.Path {
+		h.scheduleMu.Unlock()
 		return
 	}
+	h.scheduledFor[state.Deck] = matched.Path
+	h.scheduleMu.Unlock()
 
-	// Find the video in the matcher
-	vf, ok := h.matcher.GetByPath(req.Path)
+	phraseBeats := barBeats
+	if mode == "phrase" {
+		phraseBeats = defaultPhraseBeats
+		if v, err := strconv.Atoi(h.cfg.Get("transition_phrase_beats", "")); err == nil && v > 0 {
+			phraseBeats = v
+		}
+	}
+
+	startedAt := time.Now().Add(-time.Duration(state.ElapsedMs) * time.Millisecond)
+	h.armTransitionBoundary(state.Deck, startedAt, state.BPM, phraseBeats)
+}
+
+// armTransitionBoundary schedules the next beat-grid boundary for deck,
+// broadcasting it immediately as "transition-scheduled" so clients can
+// pre-roll. Once the boundary fires, it plays a transition if deck is
+// still the active deck, then re-arms the following boundary so
+// transitions keep landing on the grid for as long as the deck plays the
+// same video.
+func (h *Handlers) armTransitionBoundary(deck int, startedAt time.Time, bpm float64, phraseBeats int) {
+	boundary, ok := h.txScheduler.Schedule(deck, startedAt, bpm, phraseBeats, func(d int, _ time.Time) {
+		h.activeDeckMu.Lock()
+		if d == h.activeDeck {
+			h.playAndRefillTransition()
+		}
+		h.activeDeckMu.Unlock()
+
+		h.armTransitionBoundary(d, startedAt, bpm, phraseBeats)
+	})
+	if ok {
+		h.broadcastTransitionScheduled(deck, boundary)
+	}
+}
+
+// broadcastTransitionScheduled tells clients when the next beat-grid
+// transition boundary for deck will fire, so they can pre-roll.
+func (h *Handlers) broadcastTransitionScheduled(deck int, boundary time.Time) {
+	payload := struct {
+		Deck int       `json:"deck"`
+		At   time.Time `json:"at"`
+	}{Deck: deck, At: boundary}
+	data, _ := json.Marshal(payload)
+	h.hub.Broadcast("transition-scheduled", data)
+	slog.Info("transition scheduled", "deck", deck, "at", boundary)
+}
+
+// peekNextVideo returns the best guess at the next video for a deck
+// without consuming it: the head of the queue's Ahead list if present,
+// otherwise a random pick excluding the currently playing video. The
+// queue is only peeked, not advanced — HandleVideoEnded still owns
+// actually dequeuing when the switch happens.
+func (h *Handlers) peekNextVideo(currentPath string, bpm float64) *models.VideoFile {
+	h.queueMu.Lock()
+	if len(h.queue.Ahead) > 0 {
+		v := h.queue.Ahead[0]
+		h.queueMu.Unlock()
+		return &v
+	}
+	h.queueMu.Unlock()
+
+	if v, ok := h.matcher.RandomExcluding(currentPath, bpm); ok {
+		return &v
+	}
+	return nil
+}
+
+// Errors returned by ForceVideo, describing why a force could not be
+// applied. HandleForceVideo maps these to HTTP status codes; other
+// callers (e.g. ctlsocket) can switch on them directly.
+var (
+	ErrVideoNotFound = errors.New("video not found")
+	ErrNoActiveDeck  = errors.New("no active deck")
+	ErrNoDeckState   = errors.New("no deck state available")
+)
+
+// ForceVideo forces a specific video (by served path) to be used for the
+// current active deck. Triggers a transition and immediately broadcasts
+// the updated deck state with the forced video. The override persists
+// until the deck's song (filename) changes. Returns the forced video on
+// success.
+func (h *Handlers) ForceVideo(path string) (models.VideoFile, error) {
+	vf, ok := h.matcher.GetByPath(path)
 	if !ok {
-		http.Error(w, "video not found", http.StatusNotFound)
-		return
+		return models.VideoFile{}, ErrVideoNotFound
 	}
 
 	// Determine the active deck
@@ -405,8 +821,7 @@ func (h *Handlers) HandleForceVideo(w http.ResponseWriter, r *http.Request) {
 	h.activeDeckMu.Unlock()
 
 	if deck == 0 {
-		http.Error(w, "no active deck", http.StatusConflict)
-		return
+		return models.VideoFile{}, ErrNoActiveDeck
 	}
 
 	// Get the current deck state (for filename tracking)
@@ -414,8 +829,7 @@ func (h *Handlers) HandleForceVideo(w http.ResponseWriter, r *http.Request) {
 	lastState, exists := h.lastLogState[deck]
 	h.logMu.Unlock()
 	if !exists {
-		http.Error(w, "no deck state available", http.StatusConflict)
-		return
+		return models.VideoFile{}, ErrNoDeckState
 	}
 
 	// Mark as forced match — use MatchBPM level so the client applies
@@ -464,9 +878,45 @@ func (h *Handlers) HandleForceVideo(w http.ResponseWriter, r *http.Request) {
 	h.deckCacheMu.Lock()
 	h.deckCache[deck] = sseMsg
 	h.deckCacheMu.Unlock()
-	h.hub.Broadcast("deck-update", data)
+	h.hub.BroadcastTopic(deckTopic(deck), "deck-update", data)
 
 	slog.Info("video forced", "deck", deck, "video", vf.Name)
+	return vf, nil
+}
+
+// HandleForceVideo forces a specific video to be used for the current active
+// deck. Triggers a transition and immediately broadcasts the updated deck
+// state with the forced video. The override persists until the deck's song
+// (filename) changes.
+func (h *Handlers) HandleForceVideo(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4096))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.Path == "" {
+		http.Error(w, "invalid json: path required", http.StatusBadRequest)
+		return
+	}
+
+	vf, err := h.ForceVideo(req.Path)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrVideoNotFound):
+			http.Error(w, "video not found", http.StatusNotFound)
+		case errors.Is(err, ErrNoActiveDeck), errors.Is(err, ErrNoDeckState):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "video": vf.Name})
@@ -562,7 +1012,7 @@ func (h *Handlers) HandleForceDeckVideo(w http.ResponseWriter, r *http.Request)
 	h.deckCacheMu.Lock()
 	h.deckCache[deck] = sseMsg
 	h.deckCacheMu.Unlock()
-	h.hub.Broadcast("deck-update", data)
+	h.hub.BroadcastTopic(deckTopic(deck), "deck-update", data)
 
 	slog.Info("video forced on deck", "deck", deck, "video", vf.Name)
 	w.Header().Set("Content-Type", "application/json")
@@ -621,7 +1071,18 @@ func (h *Handlers) HandleVideoEnded(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vf, ok := h.matcher.RandomExcluding(req.CurrentVideo, lastState.BPM)
+	// A curated queue entry takes priority over a random pick.
+	var vf models.VideoFile
+	var ok bool
+	h.queueMu.Lock()
+	if qvf, qok := h.queue.Next(); qok {
+		vf, ok = qvf, true
+		h.broadcastQueueUpdated()
+	}
+	h.queueMu.Unlock()
+	if !ok {
+		vf, ok = h.matcher.RandomExcluding(req.CurrentVideo, lastState.BPM)
+	}
 	if !ok {
 		http.Error(w, "no videos available", http.StatusNotFound)
 		return
@@ -671,7 +1132,7 @@ func (h *Handlers) HandleVideoEnded(w http.ResponseWriter, r *http.Request) {
 	h.deckCacheMu.Lock()
 	h.deckCache[req.Deck] = sseMsg
 	h.deckCacheMu.Unlock()
-	h.hub.Broadcast("deck-update", data)
+	h.hub.BroadcastTopic(deckTopic(req.Deck), "deck-update", data)
 
 	// Refresh the transition pool for the next video-end or deck switch.
 	h.refillAndBroadcastPool()
@@ -697,6 +1158,13 @@ func (h *Handlers) checkActiveDeckChange(state models.DeckState, matched *models
 	info.IsPlaying = state.IsPlaying
 	info.Volume = state.Volume
 	info.HasVideo = matched != nil
+	if matched != nil {
+		info.Key = matched.Key
+		info.BPM = matched.BPM
+	} else {
+		info.Key = ""
+		info.BPM = 0
+	}
 
 	// Determine the best deck (same logic as client-side updatePriority)
 	bestDeck := 0
@@ -737,10 +1205,26 @@ func (h *Handlers) checkActiveDeckChange(state models.DeckState, matched *models
 	}
 }
 
-// pickRandomTransition picks a random transition video, excluding the given
-// paths to avoid putting duplicate videos in the pool.
+// currentDeckKeyBPM returns the musical key and BPM of the currently active
+// deck's matched video, or ("", 0) if there is no active deck or it hasn't
+// matched a video yet. Must be called with activeDeckMu held.
+func (h *Handlers) currentDeckKeyBPM() (key string, bpm float64) {
+	info := h.activeDeckStates[h.activeDeck]
+	if info == nil {
+		return "", 0
+	}
+	return info.Key, info.BPM
+}
+
+// pickRandomTransition picks a transition video, excluding the given paths
+// to avoid putting duplicate videos in the pool. When harmonic mixing is
+// enabled and currentKey/currentBPM are known, it prefers videos that are
+// harmonically compatible (per musickey.Distance) and within the
+// bpm_match_window_pct tolerance, falling back in stages — key-compatible
+// only, then BPM-window only, then the full candidate list — whenever a
+// stage's filter would leave nothing to pick from.
 // Must be called with activeDeckMu held.
-func (h *Handlers) pickRandomTransition(excludePaths []string) *transitionPoolEntry {
+func (h *Handlers) pickRandomTransition(excludePaths []string, currentKey string, currentBPM float64) *transitionPoolEntry {
 	if dir := h.cfg.Get("transition_videos_dir", ""); dir != "" && dir != h.transitionMatcher.Dir() {
 		h.transitionMatcher.SetDir(dir)
 	}
@@ -749,6 +1233,21 @@ func (h *Handlers) pickRandomTransition(excludePaths []string) *transitionPoolEn
 		return nil
 	}
 
+	// Never serve a track whose loudness analysis hasn't completed yet —
+	// GainDB/Peak are both the zero value until analysis finishes, so a
+	// pending track would otherwise play without level matching. Fall
+	// back to the unfiltered list if nothing in the library is ready yet
+	// (e.g. right after a rescan starts).
+	ready := make([]models.VideoFile, 0, len(videos))
+	for _, v := range videos {
+		if v.GainDB != 0 || v.Peak != 0 {
+			ready = append(ready, v)
+		}
+	}
+	if len(ready) > 0 {
+		videos = ready
+	}
+
 	if len(excludePaths) > 0 && len(videos) > 1 {
 		excludeSet := make(map[string]bool, len(excludePaths))
 		for _, p := range excludePaths {
@@ -765,13 +1264,72 @@ func (h *Handlers) pickRandomTransition(excludePaths []string) *transitionPoolEn
 		}
 	}
 
+	videos = h.filterHarmonic(videos, currentKey, currentBPM)
+
 	chosen := videos[rand.IntN(len(videos))]
-	return &transitionPoolEntry{Video: chosen.Path, BPM: chosen.BPM}
+	return &transitionPoolEntry{Video: chosen.Path, BPM: chosen.BPM, GainDB: chosen.GainDB, Peak: chosen.Peak}
+}
+
+// filterHarmonic narrows candidates to those that mix well with
+// currentKey/currentBPM, in stages: key-and-BPM match, then key-only, then
+// BPM-only, falling back to the unfiltered candidates if a stage would
+// otherwise leave nothing to choose from. No-op (returns candidates as-is)
+// if harmonic mixing is disabled or currentKey/currentBPM are unknown.
+func (h *Handlers) filterHarmonic(candidates []models.VideoFile, currentKey string, currentBPM float64) []models.VideoFile {
+	if h.cfg.Get("harmonic_mixing_enabled", "0") != "1" || currentKey == "" {
+		return candidates
+	}
+	curCamelot, ok := musickey.Parse(currentKey)
+	if !ok {
+		return candidates
+	}
+
+	windowPct, err := strconv.ParseFloat(h.cfg.Get("bpm_match_window_pct", "8"), 64)
+	if err != nil || windowPct <= 0 {
+		windowPct = 8
+	}
+	withinBPM := func(v models.VideoFile) bool {
+		if currentBPM <= 0 || v.BPM <= 0 {
+			return true
+		}
+		return math.Abs(v.BPM-currentBPM) <= currentBPM*windowPct/100
+	}
+	compatible := func(v models.VideoFile) bool {
+		vCamelot, ok := musickey.Parse(v.Key)
+		return ok && musickey.Distance(curCamelot, vCamelot) <= 1
+	}
+
+	var keyAndBPM, keyOnly, bpmOnly []models.VideoFile
+	for _, v := range candidates {
+		key := compatible(v)
+		bpm := withinBPM(v)
+		if key && bpm {
+			keyAndBPM = append(keyAndBPM, v)
+		}
+		if key {
+			keyOnly = append(keyOnly, v)
+		}
+		if bpm {
+			bpmOnly = append(bpmOnly, v)
+		}
+	}
+
+	switch {
+	case len(keyAndBPM) > 0:
+		return keyAndBPM
+	case len(keyOnly) > 0:
+		return keyOnly
+	case len(bpmOnly) > 0:
+		return bpmOnly
+	default:
+		return candidates
+	}
 }
 
 // fillTransitionPool fills any empty slots in the transition pool.
 // Must be called with activeDeckMu held.
 func (h *Handlers) fillTransitionPool() {
+	currentKey, currentBPM := h.currentDeckKeyBPM()
 	for i := range h.transitionPool {
 		if h.transitionPool[i] == nil {
 			// Collect paths from already-filled slots to avoid duplicates
@@ -781,7 +1339,7 @@ func (h *Handlers) fillTransitionPool() {
 					exclude = append(exclude, e.Video)
 				}
 			}
-			h.transitionPool[i] = h.pickRandomTransition(exclude)
+			h.transitionPool[i] = h.pickRandomTransition(exclude, currentKey, currentBPM)
 		}
 	}
 }
@@ -813,14 +1371,16 @@ func (h *Handlers) broadcastTransitionPool() {
 func (h *Handlers) playAndRefillTransition() {
 	slot := h.transitionNextSlot
 
-	// Pick random enabled "in" and "out" effects
+	// Pick the "in" and "out" effects — audience vote winner when voting
+	// is enabled and turnout met quorum, random enabled effect otherwise.
 	var inCSS, outCSS string
-	if fx, err := h.transitions.RandomEnabled("in"); err == nil && fx != nil {
+	if fx := h.pickTransitionEffect(poll.SubjectTransitionIn, "in"); fx != nil {
 		inCSS = fx.CSS
 	}
-	if fx, err := h.transitions.RandomEnabled("out"); err == nil && fx != nil {
+	if fx := h.pickTransitionEffect(poll.SubjectTransitionOut, "out"); fx != nil {
 		outCSS = fx.CSS
 	}
+	h.resolveOverlayPoll()
 
 	// Broadcast play command with CSS effects
 	playPayload := struct {
@@ -831,6 +1391,10 @@ func (h *Handlers) playAndRefillTransition() {
 	playData, _ := json.Marshal(playPayload)
 	h.hub.Broadcast("transition-play", playData)
 
+	if h.ndiMgr != nil {
+		h.ndiMgr.SendMetadata(slot, inCSS, outCSS)
+	}
+
 	entry := h.transitionPool[slot]
 	var playedVideo string
 	if entry != nil {
@@ -848,7 +1412,8 @@ func (h *Handlers) playAndRefillTransition() {
 			exclude = append(exclude, e.Video)
 		}
 	}
-	h.transitionPool[slot] = h.pickRandomTransition(exclude)
+	currentKey, currentBPM := h.currentDeckKeyBPM()
+	h.transitionPool[slot] = h.pickRandomTransition(exclude, currentKey, currentBPM)
 
 	// Broadcast the updated pool so clients preload the new video
 	h.broadcastTransitionPool()
@@ -869,116 +1434,579 @@ func (h *Handlers) refillAndBroadcastPool() {
 			exclude = append(exclude, e.Video)
 		}
 	}
-	h.transitionPool[slot] = h.pickRandomTransition(exclude)
+	currentKey, currentBPM := h.currentDeckKeyBPM()
+	h.transitionPool[slot] = h.pickRandomTransition(exclude, currentKey, currentBPM)
 	h.transitionNextSlot = (slot + 1) % 3
 	h.broadcastTransitionPool()
 }
 
-// ── Deck 3/4 Visibility ────────────────────────────────
-
-// deckHideDelay is how long a paused deck 3/4 waits before being hidden.
-const deckHideDelay = 60 * time.Second
+// ── Audience Voting ──────────────────────────────────────
+//
+// Viewers vote through HandlePollVote, guarded to one vote per viewer by
+// the vdj_viewer cookie (see viewerID). pickTransitionEffect resolves the
+// winner on each track change and is the only caller of resolvePollWinner;
+// tallies are pushed to WebSocket clients via pollHub as votes come in and
+// whenever a session opens or closes.
+
+// defaultPollQuorum is used if "voting_quorum" isn't configured or parses
+// to something nonsensical.
+const defaultPollQuorum = 3
+
+// pickTransitionEffect resolves which CSS effect plays for direction ("in"
+// or "out") on this track change. When voting is enabled it closes out
+// subject's poll session, honoring a weighted-random winner (see
+// poll.WeightedWinner) if turnout reached quorum, and opens the next
+// round. It falls back to transitions.RandomEnabled — the original
+// behavior — when voting is disabled, turnout didn't reach quorum, or
+// there was no open session to resolve.
+func (h *Handlers) pickTransitionEffect(subject, direction string) *models.TransitionEffect {
+	if h.pollStore != nil && h.cfg.Get("voting_enabled", "0") == "1" {
+		if fx := h.resolveTransitionPoll(subject, direction); fx != nil {
+			return fx
+		}
+	}
+	fx, err := h.transitions.RandomEnabled(direction)
+	if err != nil {
+		slog.Error("random transition effect", "direction", direction, "error", err)
+		return nil
+	}
+	return fx
+}
 
-// updateDeckVisibility manages server-side timers for deck 3/4 auto-hide.
-// When a deck starts playing it is made visible immediately.
-// When it stops playing a 60-second timer starts; on expiry the deck is hidden.
-func (h *Handlers) updateDeckVisibility(deck int, isPlaying bool) {
-	h.deckVisMu.Lock()
-	defer h.deckVisMu.Unlock()
+// resolveTransitionPoll closes the active poll session for subject (if
+// any), honoring a weighted-random winner when turnout met quorum, then
+// opens the next round. Returns nil — telling the caller to fall back to
+// RandomEnabled — if there was no open session, turnout didn't reach
+// quorum, or the winning ID no longer resolves to an enabled effect.
+func (h *Handlers) resolveTransitionPoll(subject, direction string) *models.TransitionEffect {
+	session, err := h.pollStore.Active(subject)
+	if err != nil {
+		slog.Error("poll active session", "subject", subject, "error", err)
+		return nil
+	}
 
-	if isPlaying {
-		// Cancel any pending hide timer
-		if t, ok := h.deckHideTimer[deck]; ok {
-			t.Stop()
-			delete(h.deckHideTimer, deck)
+	var winner *models.TransitionEffect
+	if session != nil {
+		if tallies, total, err := h.pollStore.Tallies(session.ID); err != nil {
+			slog.Error("poll tallies", "subject", subject, "error", err)
+		} else if total >= session.Quorum {
+			if id, ok := poll.WeightedWinner(tallies, h.transitions.VoteWeight); ok {
+				if fx, err := h.transitions.Get(id); err == nil && fx.Enabled {
+					winner = fx
+				}
+			}
 		}
-		// Show if not already visible
-		if !h.deckVisible[deck] {
-			h.deckVisible[deck] = true
-			h.broadcastDeckVisibility(deck, true)
+		winnerID := 0
+		if winner != nil {
+			winnerID = winner.ID
 		}
-	} else {
-		// Already hidden or timer already running — nothing to do
-		if !h.deckVisible[deck] || h.deckHideTimer[deck] != nil {
-			return
+		if err := h.pollStore.Close(session.ID, winnerID); err != nil {
+			slog.Error("poll close session", "subject", subject, "error", err)
 		}
-		// Start hide timer
-		h.deckHideTimer[deck] = time.AfterFunc(deckHideDelay, func() {
-			h.deckVisMu.Lock()
-			defer h.deckVisMu.Unlock()
-			h.deckVisible[deck] = false
-			delete(h.deckHideTimer, deck)
-			h.broadcastDeckVisibility(deck, false)
-		})
 	}
-}
-
-// broadcastDeckVisibility sends a deck-visibility SSE event and caches it.
-// Must be called with deckVisMu held.
-func (h *Handlers) broadcastDeckVisibility(deck int, visible bool) {
-	payload := struct {
-		Deck    int  `json:"deck"`
-		Visible bool `json:"visible"`
-	}{Deck: deck, Visible: visible}
-	data, _ := json.Marshal(payload)
 
-	sseMsg := fmt.Appendf(nil, "event: deck-visibility\ndata: %s\n\n", data)
-
-	h.deckCacheMu.Lock()
-	h.visCache[deck] = sseMsg
-	h.deckCacheMu.Unlock()
-
-	h.hub.Broadcast("deck-visibility", data)
-	slog.Info("deck visibility", "deck", deck, "visible", visible)
+	h.openTransitionPollSession(subject, direction)
+	return winner
 }
 
-// ── SSE ─────────────────────────────────────────────────
-
-// HandleSSE streams server-sent events to browser clients.
-func (h *Handlers) HandleSSE(w http.ResponseWriter, r *http.Request) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+// openTransitionPollSession starts the next voting round for subject over
+// the currently enabled effects for direction, and pushes its (all-zero)
+// tallies to connected clients so the poll UI resets immediately.
+func (h *Handlers) openTransitionPollSession(subject, direction string) {
+	session, err := h.pollStore.Open(subject, h.pollQuorum())
+	if err != nil {
+		slog.Error("poll open session", "subject", subject, "error", err)
 		return
 	}
-
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-
-	client := &sse.Client{
-		ID:     fmt.Sprintf("%d", time.Now().UnixNano()),
-		Events: make(chan []byte, 256),
+	effects, err := h.transitions.List(direction)
+	if err != nil {
+		slog.Error("list transitions for poll", "direction", direction, "error", err)
+		return
 	}
+	options := make([]models.PollTally, 0, len(effects))
+	for _, fx := range effects {
+		if fx.Enabled {
+			options = append(options, models.PollTally{OptionID: fx.ID, Name: fx.Name})
+		}
+	}
+	h.broadcastPollTally(session, options)
+}
 
-	h.hub.Register(client)
-	defer h.hub.Unregister(client)
-
-	// Send initial keepalive
-	fmt.Fprintf(w, ": connected\n\n")
-	flusher.Flush()
+// resolveOverlayPoll closes the active "overlay" poll session, if any,
+// and — when turnout met quorum — enables the winning overlay element so
+// it's featured going into the next track. Unlike transition effects,
+// overlay elements aren't mutually exclusive, so a winner is additive
+// (SetEnabled(true)) rather than swapped in; below quorum the existing
+// enabled set is left untouched. No-op if overlayStore isn't wired in.
+func (h *Handlers) resolveOverlayPoll() {
+	if h.pollStore == nil || h.overlayStore == nil || h.cfg.Get("voting_enabled", "0") != "1" {
+		return
+	}
+	session, err := h.pollStore.Active(poll.SubjectOverlay)
+	if err != nil {
+		slog.Error("poll active session", "subject", poll.SubjectOverlay, "error", err)
+		return
+	}
+	if session != nil {
+		winnerID := 0
+		if tallies, total, err := h.pollStore.Tallies(session.ID); err != nil {
+			slog.Error("poll tallies", "subject", poll.SubjectOverlay, "error", err)
+		} else if total >= session.Quorum {
+			if id, ok := poll.WeightedWinner(tallies, func(int) float64 { return 1 }); ok {
+				if err := h.overlayStore.SetEnabled(id, true); err == nil {
+					winnerID = id
+				} else {
+					slog.Error("enable poll-winning overlay", "id", id, "error", err)
+				}
+			}
+		}
+		if err := h.pollStore.Close(session.ID, winnerID); err != nil {
+			slog.Error("poll close session", "subject", poll.SubjectOverlay, "error", err)
+		}
+	}
 
-	// Replay cached states so new clients get synced immediately
-	h.deckCacheMu.RLock()
-	if h.analysisCache != nil {
-		w.Write(h.analysisCache)
+	elements, err := h.overlayStore.List()
+	if err != nil {
+		slog.Error("list overlays for poll", "error", err)
+		return
+	}
+	newSession, err := h.pollStore.Open(poll.SubjectOverlay, h.pollQuorum())
+	if err != nil {
+		slog.Error("poll open session", "subject", poll.SubjectOverlay, "error", err)
+		return
+	}
+	options := make([]models.PollTally, 0, len(elements))
+	for _, el := range elements {
+		options = append(options, models.PollTally{OptionID: el.ID, Name: el.Name})
+	}
+	h.broadcastPollTally(newSession, options)
+}
+
+// pollQuorum reads the "voting_quorum" config value, falling back to
+// defaultPollQuorum if it's missing or not a positive integer.
+func (h *Handlers) pollQuorum() int {
+	quorum, err := strconv.Atoi(h.cfg.Get("voting_quorum", strconv.Itoa(defaultPollQuorum)))
+	if err != nil || quorum < 1 {
+		return defaultPollQuorum
+	}
+	return quorum
+}
+
+// broadcastPollTally pushes session's live vote counts to connected poll
+// WebSocket clients. options carries each candidate's display name since
+// poll_votes only stores option_id; counts not present there default to 0.
+func (h *Handlers) broadcastPollTally(session *models.PollSession, options []models.PollTally) {
+	counts, total, err := h.pollStore.Tallies(session.ID)
+	if err != nil {
+		slog.Error("poll tallies", "session", session.ID, "error", err)
+		return
+	}
+	for i := range options {
+		options[i].Votes = counts[options[i].OptionID]
+	}
+	payload := struct {
+		SessionID int                `json:"sessionId"`
+		Subject   string             `json:"subject"`
+		Quorum    int                `json:"quorum"`
+		Total     int                `json:"total"`
+		Options   []models.PollTally `json:"options"`
+	}{session.ID, session.Subject, session.Quorum, total, options}
+	data, _ := json.Marshal(payload)
+	h.pollHub.Broadcast(data)
+}
+
+// viewerID returns the caller's voting identity, read from the vdj_viewer
+// cookie if present, or issued and set as a session cookie otherwise. It's
+// the one-vote-per-viewer guard's identity source — see poll_votes'
+// UNIQUE(session_id, viewer_id) constraint.
+func (h *Handlers) viewerID(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie("vdj_viewer"); err == nil && c.Value != "" {
+		return c.Value
+	}
+	buf := make([]byte, 16)
+	cryptorand.Read(buf)
+	id := hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     "vdj_viewer",
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int((30 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+// HandlePollWS upgrades to a WebSocket connection that receives a
+// "poll-tally" JSON message (see broadcastPollTally's payload) every time
+// a vote is cast or a session opens/closes. It has no inbound protocol of
+// its own — ReadMessage is only called to detect the client going away.
+func (h *Handlers) HandlePollWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := ws.Accept(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.pollHub.Register(conn)
+	defer func() {
+		h.pollHub.Unregister(conn)
+		conn.Close()
+	}()
+
+	for {
+		if _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// HandlePollVote records one viewer's vote in the currently open poll
+// session for the given subject ("transition_in", "transition_out", or
+// "overlay"), guarded to one vote per viewer via the vdj_viewer cookie.
+func (h *Handlers) HandlePollVote(w http.ResponseWriter, r *http.Request) {
+	if h.pollStore == nil || h.cfg.Get("voting_enabled", "0") != "1" {
+		http.Error(w, "voting is disabled", http.StatusForbidden)
+		return
+	}
+	var req struct {
+		Subject  string `json:"subject"`
+		OptionID int    `json:"optionId"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 256)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.pollStore.Active(req.Subject)
+	if err != nil {
+		slog.Error("poll active session", "subject", req.Subject, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		http.Error(w, "no open poll for this subject", http.StatusNotFound)
+		return
+	}
+
+	viewer := h.viewerID(w, r)
+	if err := h.pollStore.Vote(session.ID, req.OptionID, viewer); err != nil {
+		if errors.Is(err, poll.ErrAlreadyVoted) {
+			http.Error(w, "already voted", http.StatusConflict)
+			return
+		}
+		slog.Error("poll vote", "session", session.ID, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	options, err := h.pollOptions(req.Subject)
+	if err != nil {
+		slog.Error("poll options", "subject", req.Subject, "error", err)
+	} else {
+		h.broadcastPollTally(session, options)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pollOptions lists the current candidate names for subject, for
+// broadcastPollTally's payload.
+func (h *Handlers) pollOptions(subject string) ([]models.PollTally, error) {
+	switch subject {
+	case poll.SubjectTransitionIn, poll.SubjectTransitionOut:
+		direction := "in"
+		if subject == poll.SubjectTransitionOut {
+			direction = "out"
+		}
+		effects, err := h.transitions.List(direction)
+		if err != nil {
+			return nil, err
+		}
+		options := make([]models.PollTally, 0, len(effects))
+		for _, fx := range effects {
+			if fx.Enabled {
+				options = append(options, models.PollTally{OptionID: fx.ID, Name: fx.Name})
+			}
+		}
+		return options, nil
+	case poll.SubjectOverlay:
+		if h.overlayStore == nil {
+			return nil, nil
+		}
+		elements, err := h.overlayStore.List()
+		if err != nil {
+			return nil, err
+		}
+		options := make([]models.PollTally, 0, len(elements))
+		for _, el := range elements {
+			options = append(options, models.PollTally{OptionID: el.ID, Name: el.Name})
+		}
+		return options, nil
+	default:
+		return nil, nil
+	}
+}
+
+// HandlePollState returns the currently open poll session for
+// ?subject=, including live tallies, for clients bootstrapping state
+// before (or without) connecting to HandlePollWS.
+func (h *Handlers) HandlePollState(w http.ResponseWriter, r *http.Request) {
+	if h.pollStore == nil {
+		http.Error(w, "voting not available", http.StatusNotFound)
+		return
+	}
+	subject := r.URL.Query().Get("subject")
+	session, err := h.pollStore.Active(subject)
+	if err != nil {
+		slog.Error("poll active session", "subject", subject, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if session == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"subject": subject, "open": false})
+		return
+	}
+	options, err := h.pollOptions(subject)
+	if err != nil {
+		slog.Error("poll options", "subject", subject, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	counts, total, err := h.pollStore.Tallies(session.ID)
+	if err != nil {
+		slog.Error("poll tallies", "session", session.ID, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
 	}
-	for _, msg := range h.visCache {
-		w.Write(msg)
+	for i := range options {
+		options[i].Votes = counts[options[i].OptionID]
 	}
-	for _, msg := range h.deckCache {
-		w.Write(msg)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		SessionID int                `json:"sessionId"`
+		Subject   string             `json:"subject"`
+		Open      bool               `json:"open"`
+		Quorum    int                `json:"quorum"`
+		Total     int                `json:"total"`
+		Options   []models.PollTally `json:"options"`
+	}{session.ID, session.Subject, true, session.Quorum, total, options})
+}
+
+// HandleSetVoteWeight adjusts a transition effect's audience-vote bias
+// (transition_effects.vote_weight) so the DJ can make it more or less
+// likely to win a poll without needing more voters.
+func (h *Handlers) HandleSetVoteWeight(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
 	}
-	if h.transitionPoolCache != nil {
-		w.Write(h.transitionPoolCache)
+	var body struct {
+		Weight float64 `json:"weight"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 256)).Decode(&body); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if body.Weight < 0 {
+		http.Error(w, "weight must be >= 0", http.StatusBadRequest)
+		return
 	}
-	for _, msg := range h.configCache {
-		w.Write(msg)
+	if err := h.transitions.SetVoteWeight(id, body.Weight); err != nil {
+		slog.Error("set vote weight", "id", id, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
 	}
-	h.deckCacheMu.RUnlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ── Deck 3/4 Visibility ────────────────────────────────
+
+// deckHideDelay is how long a paused deck 3/4 waits before being hidden.
+const deckHideDelay = 60 * time.Second
+
+// updateDeckVisibility manages server-side timers for deck 3/4 auto-hide.
+// When a deck starts playing it is made visible immediately.
+// When it stops playing a 60-second timer starts; on expiry the deck is hidden.
+func (h *Handlers) updateDeckVisibility(deck int, isPlaying bool) {
+	h.deckVisMu.Lock()
+	defer h.deckVisMu.Unlock()
+
+	if isPlaying {
+		// Cancel any pending hide timer
+		if t, ok := h.deckHideTimer[deck]; ok {
+			t.Stop()
+			delete(h.deckHideTimer, deck)
+		}
+		// Show if not already visible
+		if !h.deckVisible[deck] {
+			h.deckVisible[deck] = true
+			h.broadcastDeckVisibility(deck, true)
+		}
+	} else {
+		// Already hidden or timer already running — nothing to do
+		if !h.deckVisible[deck] || h.deckHideTimer[deck] != nil {
+			return
+		}
+		// Start hide timer
+		h.deckHideTimer[deck] = time.AfterFunc(deckHideDelay, func() {
+			h.deckVisMu.Lock()
+			defer h.deckVisMu.Unlock()
+			h.deckVisible[deck] = false
+			delete(h.deckHideTimer, deck)
+			h.broadcastDeckVisibility(deck, false)
+		})
+	}
+}
+
+// broadcastDeckVisibility sends a deck-visibility SSE event and caches it.
+// Must be called with deckVisMu held.
+func (h *Handlers) broadcastDeckVisibility(deck int, visible bool) {
+	payload := struct {
+		Deck    int  `json:"deck"`
+		Visible bool `json:"visible"`
+	}{Deck: deck, Visible: visible}
+	data, _ := json.Marshal(payload)
+
+	sseMsg := fmt.Appendf(nil, "event: deck-visibility\ndata: %s\n\n", data)
+
+	h.deckCacheMu.Lock()
+	h.visCache[deck] = sseMsg
+	h.deckCacheMu.Unlock()
+
+	h.hub.Broadcast("deck-visibility", data)
+	slog.Info("deck visibility", "deck", deck, "visible", visible)
+}
+
+// ── SSE ─────────────────────────────────────────────────
+
+// bandwidthPingInterval is how often HandleSSE sends a bandwidth ping.
+const bandwidthPingInterval = 5 * time.Second
+
+// heartbeatInterval is how often HandleSSE sends a bare comment line to
+// keep intermediaries from closing an idle connection.
+const heartbeatInterval = 15 * time.Second
+
+// bandwidthPingPayloadSize is the nominal size (bytes) of a ping's
+// padding, used to compute throughput from the client's ack round trip.
+const bandwidthPingPayloadSize = 4096
+
+var bandwidthPingPadding = strings.Repeat("0", bandwidthPingPayloadSize)
+
+// HandlePingAck receives a client's acknowledgement of a bandwidth ping
+// (see HandleSSE) and folds the round trip into that client's
+// sse.BandwidthEstimator.
+func (h *Handlers) HandlePingAck(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ClientID string `json:"clientId"`
+		PingID   string `json:"pingId"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&req); err != nil || req.ClientID == "" || req.PingID == "" {
+		http.Error(w, "invalid json: clientId and pingId required", http.StatusBadRequest)
+		return
+	}
+
+	client, ok := h.hub.ClientByID(req.ClientID)
+	if !ok {
+		http.Error(w, "unknown client", http.StatusNotFound)
+		return
+	}
+	client.Bandwidth.RecordAck(req.PingID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSSE streams server-sent events to browser clients.
+func (h *Handlers) HandleSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	client := &sse.Client{
+		ID:        fmt.Sprintf("%d", time.Now().UnixNano()),
+		Events:    make(chan []byte, 256),
+		Bandwidth: sse.NewBandwidthEstimator(),
+	}
+	// ?topics=deck-1,config-updated restricts this connection to a
+	// subset of broadcasts (e.g. a single-deck dashboard); omitted or
+	// empty means "every topic", same as before this param existed.
+	if topics := r.URL.Query().Get("topics"); topics != "" {
+		client.Topics = make(map[string]bool)
+		for _, t := range strings.Split(topics, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				client.Topics[t] = true
+			}
+		}
+	}
+
+	// If the client reconnected with Last-Event-ID, Register replays just
+	// the events it missed from the hub's ring buffer, captured
+	// atomically as part of joining the broadcast set. Fall back to the
+	// full snapshot-cache replay below if the header is absent or too
+	// old for the ring to cover.
+	var lastID uint64
+	if hdr := r.Header.Get("Last-Event-ID"); hdr != "" {
+		lastID, _ = strconv.ParseUint(hdr, 10, 64)
+	}
+	replay, replayedFromRing := h.hub.Register(client, lastID)
+	defer h.hub.Unregister(client)
+
+	// Send initial keepalive
+	fmt.Fprintf(w, ": connected\n\n")
 	flusher.Flush()
 
+	if replayedFromRing {
+		for _, msg := range replay {
+			w.Write(msg)
+		}
+		flusher.Flush()
+	}
+
+	// Replay cached states so new clients get synced immediately
+	if !replayedFromRing {
+		h.deckCacheMu.RLock()
+		if h.analysisCache != nil {
+			w.Write(h.analysisCache)
+		}
+		for _, msg := range h.visCache {
+			w.Write(msg)
+		}
+		for deckNum, msg := range h.deckCache {
+			if client.Wants(deckTopic(deckNum)) {
+				w.Write(msg)
+			}
+		}
+		if h.transitionPoolCache != nil {
+			w.Write(h.transitionPoolCache)
+		}
+		if h.queueCache != nil {
+			w.Write(h.queueCache)
+		}
+		for _, msg := range h.configCache {
+			w.Write(msg)
+		}
+		h.deckCacheMu.RUnlock()
+		flusher.Flush()
+	}
+
+	// Bandwidth ping: a small, fixed-size payload sent periodically so the
+	// client's ack round trip gives us an RTT/throughput sample. See
+	// sse.BandwidthEstimator and HandlePingAck.
+	pingTicker := time.NewTicker(bandwidthPingInterval)
+	defer pingTicker.Stop()
+
+	// Heartbeat: a bare comment line so intermediaries (proxies, load
+	// balancers) don't time out an otherwise-idle connection.
+	heartbeatTicker := time.NewTicker(heartbeatInterval)
+	defer heartbeatTicker.Stop()
+
 	for {
 		select {
 		case msg, ok := <-client.Events:
@@ -1002,6 +2030,18 @@ func (h *Handlers) HandleSSE(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 			flusher.Flush()
+		case <-pingTicker.C:
+			pingID := fmt.Sprintf("%s-%d", client.ID, time.Now().UnixNano())
+			client.Bandwidth.RecordPingSent(pingID, bandwidthPingPayloadSize)
+			payload, _ := json.Marshal(struct {
+				ID  string `json:"id"`
+				Pad string `json:"pad"`
+			}{ID: pingID, Pad: bandwidthPingPadding})
+			fmt.Fprintf(w, "event: bandwidth-ping\ndata: %s\n\n", payload)
+			flusher.Flush()
+		case <-heartbeatTicker.C:
+			fmt.Fprintf(w, ": heartbeat\n\n")
+			flusher.Flush()
 		case <-r.Context().Done():
 			return
 		}
@@ -1055,6 +2095,19 @@ func (h *Handlers) HandleGetConfig(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(h.cfg.All())
 }
 
+// HandleMigrationsStatus reports the current schema version and every
+// known migration's applied state, so an operator can confirm a
+// deployment came up on the schema it expected.
+func (h *Handlers) HandleMigrationsStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := db.MigrationsStatus(h.database, db.Migrations)
+	if err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
 // HandleSetConfig saves a config key-value pair.
 func (h *Handlers) HandleSetConfig(w http.ResponseWriter, r *http.Request) {
 	var entry models.ConfigEntry
@@ -1062,46 +2115,612 @@ func (h *Handlers) HandleSetConfig(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
-	if err := h.cfg.Set(entry.Key, entry.Value); err != nil {
-		http.Error(w, "db error", http.StatusInternalServerError)
+	if err := h.cfg.Set(entry.Key, entry.Value); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	// Broadcast config change to all clients via SSE
+	payload := map[string]string{"key": entry.Key, "value": entry.Value}
+	data, _ := json.Marshal(payload)
+	sseMsg := fmt.Appendf(nil, "event: config-updated\ndata: %s\n\n", data)
+	h.deckCacheMu.Lock()
+	if h.configCache == nil {
+		h.configCache = make(map[string][]byte)
+	}
+	h.configCache[entry.Key] = sseMsg
+	h.deckCacheMu.Unlock()
+	h.hub.Broadcast("config-updated", data)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListVideos returns the list of available video files.
+// Use ?type=transition to list transition videos instead of song videos.
+func (h *Handlers) HandleListVideos(w http.ResponseWriter, r *http.Request) {
+	isTransition := r.URL.Query().Get("type") == "transition"
+
+	var m *video.Matcher
+	var configKey, kind string
+	if isTransition {
+		m = h.transitionMatcher
+		configKey = "transition_videos_dir"
+		kind = "transition-videos"
+	} else {
+		m = h.matcher
+		configKey = "videos_dir"
+		kind = "videos"
+	}
+
+	if dir := h.cfg.Get(configKey, ""); dir != "" && dir != m.Dir() {
+		m.SetDir(dir)
+	}
+
+	list := m.ListAll()
+	// ListAll already returns natural-filename order, but re-sort
+	// explicitly so the JSON order can't drift from the matcher's even
+	// if ListAll's contract ever changes.
+	sort.Slice(list, func(i, j int) bool { return video.NaturalLess(list[i].Name, list[j].Name) })
+	if h.hlsMgr != nil {
+		for i := range list {
+			if strings.ToLower(filepath.Ext(list[i].Name)) == ".mp4" {
+				list[i].HLSURL = h.hlsMgr.LibraryPlaylistURL(kind, list[i].Name)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// HandleSimilarVideos lists videos whose perceptual-hash fingerprint is
+// close to the given one, for a "find near-duplicates" UI. Query
+// params: path (required, a served video path as returned by
+// /api/videos), type ("transition" to search the transition library
+// instead of the main one), and maxDist (optional Hamming distance
+// cutoff, default defaultSimilarMaxHamming).
+func (h *Handlers) HandleSimilarVideos(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path required", http.StatusBadRequest)
+		return
+	}
+
+	m := h.matcher
+	if r.URL.Query().Get("type") == "transition" {
+		m = h.transitionMatcher
+	}
+
+	maxDist := video.VisualMaxHamming
+	if raw := r.URL.Query().Get("maxDist"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil && v >= 0 {
+			maxDist = v
+		}
+	}
+
+	similar := m.FindSimilar(path, maxDist)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(similar)
+}
+
+// ── Metadata reconciliation ─────────────────────────────
+
+// reconcileModTime stats the on-disk file for a served video path (as
+// found in matcher.ListAll()/Match()) and returns its absolute path and
+// modification time, the same key reconcile.Store uses.
+func (h *Handlers) reconcileModTime(servedPath string) (absPath string, modTime int64, ok bool) {
+	absPath = filepath.Join(h.matcher.Dir(), filepath.Base(servedPath))
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", 0, false
+	}
+	return absPath, info.ModTime().Unix(), true
+}
+
+// HandleReconcileSearch looks up external-metadata candidates for a video.
+// Query params: path (required, a served video path as returned by
+// /api/videos), title and artist (optional, used instead of the filename
+// if the caller already has better text to search with).
+func (h *Handlers) HandleReconcileSearch(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path required", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query().Get("title")
+	if artist := r.URL.Query().Get("artist"); artist != "" {
+		if query != "" {
+			query = artist + " - " + query
+		} else {
+			query = artist
+		}
+	}
+	if query == "" {
+		query = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	candidates := reconcile.Search(r.Context(), h.reconcileProviders, query)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(candidates)
+}
+
+// HandleBPMOverride records a manually-verified BPM for a video, captured
+// client-side via a WebAudio microphone tap (autocorrelation on the
+// onset envelope runs in the browser; the server just persists the
+// result). This is the escape hatch for half/double-time errors from the
+// offline analyser — it doesn't touch the automatically analysed value,
+// only shadows it (see bpm.Cache.SetOverride).
+func (h *Handlers) HandleBPMOverride(w http.ResponseWriter, r *http.Request) {
+	if h.bpmCache == nil {
+		http.Error(w, "bpm override not available", http.StatusNotFound)
+		return
+	}
+	defer r.Body.Close()
+	var req struct {
+		Path   string  `json:"path"`
+		BPM    float64 `json:"bpm"`
+		Source string  `json:"source"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" || req.BPM <= 0 {
+		http.Error(w, "path and a positive bpm are required", http.StatusBadRequest)
+		return
+	}
+	if req.Source == "" {
+		req.Source = "mic-tap"
+	}
+	absPath, modTime, ok := h.reconcileModTime(req.Path)
+	if !ok {
+		http.Error(w, "video file not found", http.StatusNotFound)
+		return
+	}
+	if err := h.bpmCache.SetOverride(absPath, modTime, req.BPM, req.Source); err != nil {
+		slog.Error("set bpm override", "path", absPath, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	h.BroadcastLibraryUpdated("song")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleReconcileAccept locks in a chosen candidate as the authoritative
+// metadata for a video file, persisted so HandleDeckUpdate can feed it
+// into the overlay seeds (song_name, artist) and the new album/year/key
+// fields instead of relying on VDJ's own get_title/get_artist verbs.
+func (h *Handlers) HandleReconcileAccept(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4096))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Path      string              `json:"path"`
+		Candidate reconcile.Candidate `json:"candidate"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.Path == "" {
+		http.Error(w, "invalid json: path required", http.StatusBadRequest)
+		return
+	}
+
+	absPath, modTime, ok := h.reconcileModTime(req.Path)
+	if !ok {
+		http.Error(w, "video not found", http.StatusNotFound)
+		return
+	}
+
+	m := reconcile.Metadata{
+		Name:   req.Candidate.Name,
+		Artist: req.Candidate.Artist,
+		Album:  req.Candidate.Album,
+		Year:   req.Candidate.Year,
+		Key:    req.Candidate.Key,
+		Score:  req.Candidate.Score,
+		URI:    req.Candidate.URI,
+	}
+	if err := h.reconcileStore.Accept(absPath, modTime, m); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("reconciliation accepted", "video", req.Path, "name", m.Name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pickVariantPath chooses the served path a client should use for vf,
+// given its estimated bandwidth in bits per second. It picks the
+// highest-bitrate variant that still fits under estimatedBps, falling
+// back to the lowest-bitrate variant if even that doesn't fit (better a
+// choppy stream than one that can't keep up), and to the original
+// full-quality path if bandwidth is unknown (0) or there are no variants.
+func pickVariantPath(vf *models.VideoFile, estimatedBps float64) string {
+	if estimatedBps <= 0 || len(vf.Variants) == 0 {
+		return vf.Path
+	}
+	// Variants are pre-sorted highest bitrate first (see video.scanVariants).
+	for _, v := range vf.Variants {
+		if float64(v.BitrateKbps)*1000 <= estimatedBps {
+			return v.Path
+		}
+	}
+	return vf.Variants[len(vf.Variants)-1].Path
+}
+
+// ── HLS ─────────────────────────────────────────────────
+
+// updateHLSSession starts, restarts, or stops a deck's HLS session to
+// track its matched video, and returns the playlist URL to include in
+// the deck-update event (empty if HLS is disabled or there's no video).
+// The segmenter is seeked to the deck's accumulated video position so a
+// client joining via HLS lands in sync with direct-file clients.
+func (h *Handlers) updateHLSSession(deck int, matched *models.VideoFile, videoElapsedMs *float64) string {
+	if h.hlsMgr == nil {
+		return ""
+	}
+
+	h.hlsMu.Lock()
+	defer h.hlsMu.Unlock()
+
+	if matched == nil {
+		if _, had := h.hlsLastPath[deck]; had {
+			h.hlsMgr.Stop(deck)
+			delete(h.hlsLastPath, deck)
+		}
+		return ""
+	}
+
+	if h.hlsLastPath[deck] == matched.Path {
+		// Same video — just push back the idle-stop deadline.
+		h.hlsMgr.ScheduleIdleStop(deck, deckHideDelay)
+		return h.hlsMgr.PlaylistURL(deck)
+	}
+
+	seekSeconds := 0.0
+	if videoElapsedMs != nil {
+		seekSeconds = *videoElapsedMs / 1000
+	}
+
+	sourceDir := h.matcher.Dir()
+	sourcePath := filepath.Join(sourceDir, filepath.Base(matched.Path))
+	if err := h.hlsMgr.Start(deck, sourcePath, seekSeconds); err != nil {
+		slog.Warn("hls session start failed", "deck", deck, "video", matched.Path, "error", err)
+		return ""
+	}
+	h.hlsLastPath[deck] = matched.Path
+	h.hlsMgr.ScheduleIdleStop(deck, deckHideDelay)
+	return h.hlsMgr.PlaylistURL(deck)
+}
+
+// ── Live output ─────────────────────────────────────────
+
+// updateLiveStreamSource points the live FLV/RTMP output at the active
+// deck's matched video whenever it changes. Only the active deck feeds
+// the live mix, mirroring what a viewer watching the dashboard would
+// see — non-active decks don't interrupt it.
+func (h *Handlers) updateLiveStreamSource(deck int, matched *models.VideoFile) {
+	if h.liveMgr == nil {
+		return
+	}
+
+	h.activeDeckMu.Lock()
+	active := h.activeDeck
+	h.activeDeckMu.Unlock()
+	if deck != active || matched == nil {
+		return
+	}
+
+	sourcePath := filepath.Join(h.matcher.Dir(), filepath.Base(matched.Path))
+	rtmpPushURL := h.cfg.Get("rtmp_push_url", "")
+	if err := h.liveMgr.SetSource(sourcePath, rtmpPushURL); err != nil {
+		slog.Warn("live stream source update failed", "video", matched.Path, "error", err)
+	}
+}
+
+// updateNDISource points the optional NDI output at the active deck's
+// matched video whenever it changes, following the same active-deck-only
+// policy as updateLiveStreamSource. A no-op unless this binary was built
+// with the "ndi" tag and "ndi_enabled" is set to "1".
+func (h *Handlers) updateNDISource(deck int, matched *models.VideoFile) {
+	if h.ndiMgr == nil || h.cfg.Get("ndi_enabled", "0") != "1" {
+		return
+	}
+
+	h.activeDeckMu.Lock()
+	active := h.activeDeck
+	h.activeDeckMu.Unlock()
+	if deck != active || matched == nil {
+		return
+	}
+
+	sourcePath := filepath.Join(h.matcher.Dir(), filepath.Base(matched.Path))
+	sourceName := h.cfg.Get("ndi_source_name", "vdj-video-sync")
+	if err := h.ndiMgr.SetSource(sourcePath, sourceName); err != nil {
+		slog.Warn("ndi source update failed", "video", matched.Path, "error", err)
+	}
+}
+
+// HandleFLV streams the live mix as HTTP-FLV to a connected client
+// (OBS, VLC, a browser <video> tag, ...) for as long as it stays open.
+func (h *Handlers) HandleFLV(w http.ResponseWriter, r *http.Request) {
+	if h.liveMgr == nil {
+		http.Error(w, "live output disabled", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/x-flv")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	id := fmt.Sprintf("%d", time.Now().UnixNano())
+	reader := h.liveMgr.AddReader(id)
+	defer h.liveMgr.RemoveReader(id)
+
+	for {
+		select {
+		case chunk, ok := <-reader.C():
+			if !ok {
+				return
+			}
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// HandleRTMPPublish configures RTMP egress: the live mix is pushed to
+// the given RTMP URL (e.g. a restream.io or YouTube ingest endpoint) in
+// addition to being served over HTTP-FLV. RTMP *ingest* (accepting a
+// publish from VDJ's broadcaster, OBS, etc.) is a separate listener —
+// see internal/rtmp and NewRTMPHandler — so this endpoint only covers
+// the push-out direction.
+func (h *Handlers) HandleRTMPPublish(w http.ResponseWriter, r *http.Request) {
+	if h.liveMgr == nil {
+		http.Error(w, "live output disabled", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1024)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if err := h.cfg.Set("rtmp_push_url", req.URL); err != nil {
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+
+	// Restart the session against the same source so the new output is
+	// included immediately, rather than waiting for the next deck-update.
+	if source := h.liveMgr.Source(); source != "" {
+		if err := h.liveMgr.SetSource("", ""); err == nil {
+			h.liveMgr.SetSource(source, req.URL)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleLiveStreams reports the live output's current publisher and
+// reader count as JSON.
+func (h *Handlers) HandleLiveStreams(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.liveMgr == nil {
+		json.NewEncoder(w).Encode(map[string]any{"enabled": false})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"enabled":    true,
+		"publishing": h.liveMgr.Publishing(),
+		"source":     h.liveMgr.Source(),
+		"readers":    h.liveMgr.ReaderCount(),
+	})
+}
+
+// ── Queue ───────────────────────────────────────────────
+
+// queueSnapshot is the JSON shape broadcast and returned by GET /queue.
+type queueSnapshot struct {
+	Done     []models.VideoFile `json:"done"`
+	Playing  *models.VideoFile  `json:"playing"`
+	Ahead    []models.VideoFile `json:"ahead"`
+	Shuffled bool               `json:"shuffled"`
+	Loop     bool               `json:"loop"`
+}
+
+// snapshotQueue builds a queueSnapshot from the current queue state.
+// Must be called with queueMu held.
+func (h *Handlers) snapshotQueue() queueSnapshot {
+	return queueSnapshot{
+		Done:     h.queue.Done,
+		Playing:  h.queue.Playing,
+		Ahead:    h.queue.Ahead,
+		Shuffled: h.queue.Shuffled,
+		Loop:     h.queue.Loop,
+	}
+}
+
+// broadcastQueueUpdated sends the current queue snapshot as a
+// "queue-updated" SSE event and caches it for new-client replay.
+// Must be called with queueMu held.
+func (h *Handlers) broadcastQueueUpdated() {
+	data, _ := json.Marshal(h.snapshotQueue())
+	sseMsg := fmt.Appendf(nil, "event: queue-updated\ndata: %s\n\n", data)
+
+	h.deckCacheMu.Lock()
+	h.queueCache = sseMsg
+	h.deckCacheMu.Unlock()
+
+	h.hub.Broadcast("queue-updated", data)
+}
+
+// HandleQueueGet returns the current queue snapshot as JSON.
+func (h *Handlers) HandleQueueGet(w http.ResponseWriter, r *http.Request) {
+	h.queueMu.Lock()
+	snap := h.snapshotQueue()
+	h.queueMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snap)
+}
+
+// QueueAddPath appends a video (by served path) to the end of the queue.
+func (h *Handlers) QueueAddPath(path string) error {
+	vf, ok := h.matcher.GetByPath(path)
+	if !ok {
+		return ErrVideoNotFound
+	}
+
+	h.queueMu.Lock()
+	h.queue.Add(vf)
+	h.broadcastQueueUpdated()
+	h.queueMu.Unlock()
+	return nil
+}
+
+// HandleQueueAdd appends a video (by served path) to the end of the queue.
+func (h *Handlers) HandleQueueAdd(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path string `json:"path"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil || req.Path == "" {
+		http.Error(w, "invalid json: path required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.QueueAddPath(req.Path); err != nil {
+		http.Error(w, "video not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// QueueNext advances the queue to its next entry, mirroring the
+// consultation HandleVideoEnded already does. Returns the new Playing
+// entry, or false if the queue has nothing left to play.
+func (h *Handlers) QueueNext() (models.VideoFile, bool) {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	next, ok := h.queue.Next()
+	if ok {
+		h.broadcastQueueUpdated()
+	}
+	return next, ok
+}
+
+// QueuePrev rewinds the queue to the previously played entry. Returns
+// false if there's nothing in Done to rewind to.
+func (h *Handlers) QueuePrev() bool {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	ok := h.queue.Jump(-1)
+	if ok {
+		h.broadcastQueueUpdated()
+	}
+	return ok
+}
+
+// HandleQueueRemove removes the queue entry at the given index within Ahead.
+func (h *Handlers) HandleQueueRemove(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Index int `json:"index"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 256)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	h.queueMu.Lock()
+	ok := h.queue.Remove(req.Index)
+	if ok {
+		h.broadcastQueueUpdated()
+	}
+	h.queueMu.Unlock()
+
+	if !ok {
+		http.Error(w, "index out of range", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleQueueJump jumps playback to the given combined-timeline index
+// (negative reaches into Done, non-negative selects from Ahead).
+func (h *Handlers) HandleQueueJump(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Index int `json:"index"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 256)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	h.queueMu.Lock()
+	ok := h.queue.Jump(req.Index)
+	if ok {
+		h.broadcastQueueUpdated()
+	}
+	h.queueMu.Unlock()
+
+	if !ok {
+		http.Error(w, "index out of range", http.StatusBadRequest)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	// Broadcast config change to all clients via SSE
-	payload := map[string]string{"key": entry.Key, "value": entry.Value}
-	data, _ := json.Marshal(payload)
-	sseMsg := fmt.Appendf(nil, "event: config-updated\ndata: %s\n\n", data)
-	h.deckCacheMu.Lock()
-	if h.configCache == nil {
-		h.configCache = make(map[string][]byte)
+// HandleQueueShuffle toggles shuffle mode for the upcoming (Ahead) entries.
+func (h *Handlers) HandleQueueShuffle(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Shuffle bool `json:"shuffle"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 256)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
 	}
-	h.configCache[entry.Key] = sseMsg
-	h.deckCacheMu.Unlock()
-	h.hub.Broadcast("config-updated", data)
+
+	h.queueMu.Lock()
+	h.queue.SetShuffle(req.Shuffle, rand.IntN)
+	h.broadcastQueueUpdated()
+	h.queueMu.Unlock()
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// HandleListVideos returns the list of available video files.
-// Use ?type=transition to list transition videos instead of song videos.
-func (h *Handlers) HandleListVideos(w http.ResponseWriter, r *http.Request) {
-	isTransition := r.URL.Query().Get("type") == "transition"
-
-	var m *video.Matcher
-	var configKey string
-	if isTransition {
-		m = h.transitionMatcher
-		configKey = "transition_videos_dir"
-	} else {
-		m = h.matcher
-		configKey = "videos_dir"
+// HandleQueueLoop toggles loop mode: when Ahead is exhausted, Done is
+// recycled back into Ahead instead of falling through to the matcher.
+func (h *Handlers) HandleQueueLoop(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Loop bool `json:"loop"`
 	}
-
-	if dir := h.cfg.Get(configKey, ""); dir != "" && dir != m.Dir() {
-		m.SetDir(dir)
+	if err := json.NewDecoder(io.LimitReader(r.Body, 256)).Decode(&req); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(m.ListAll())
+
+	h.queueMu.Lock()
+	h.queue.SetLoop(req.Loop)
+	h.broadcastQueueUpdated()
+	h.queueMu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // ── Transitions Page ────────────────────────────────────
@@ -1269,3 +2888,460 @@ func (h *Handlers) HandleRandomPreviewVideos(w http.ResponseWriter, r *http.Requ
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(result)
 }
+
+// ── Overlay/Transition Packs ─────────────────────────────
+//
+// A pack (internal/pack) bundles a set of overlay elements and transition
+// effects into a single .vdjpack file a DJ can hand to someone else.
+// Imported rows are tagged with a generated pack_id so HandleUninstallPack
+// can remove the whole set in one call (see overlay.Store.DeleteByPackID
+// and transitions.Store.DeleteByPackID).
+
+// maxPackBytes caps an uploaded .vdjpack — it only ever holds CSS/HTML/JS
+// text and small JSON, never media, so this is generous headroom rather
+// than a tuned limit.
+const maxPackBytes = 2 << 20
+
+// HandleExportPack bundles the overlay elements and transition effects
+// named by ?overlayIds= and ?transitionIds= (comma-separated numeric IDs,
+// either may be omitted) into a .vdjpack archive and streams it back.
+func (h *Handlers) HandleExportPack(w http.ResponseWriter, r *http.Request) {
+	if h.overlayStore == nil {
+		http.Error(w, "packs not available", http.StatusNotFound)
+		return
+	}
+	overlayIDs, err := parseIDList(r.URL.Query().Get("overlayIds"))
+	if err != nil {
+		http.Error(w, "invalid overlayIds", http.StatusBadRequest)
+		return
+	}
+	transitionIDs, err := parseIDList(r.URL.Query().Get("transitionIds"))
+	if err != nil {
+		http.Error(w, "invalid transitionIds", http.StatusBadRequest)
+		return
+	}
+	if len(overlayIDs) == 0 && len(transitionIDs) == 0 {
+		http.Error(w, "overlayIds or transitionIds required", http.StatusBadRequest)
+		return
+	}
+
+	var overlayRows []pack.OverlayRow
+	for _, id := range overlayIDs {
+		el, err := h.overlayStore.Get(id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("overlay %d not found", id), http.StatusNotFound)
+			return
+		}
+		overlayRows = append(overlayRows, pack.OverlayRow{
+			Key: el.Key, Name: el.Name, CSS: el.CSS, HTML: el.HTML, JS: el.JS,
+			DataType: el.DataType, Verb: el.Verb, Config: el.Config, ShowOverTransition: el.ShowOverTransition,
+		})
+	}
+	var transitionRows []pack.TransitionRow
+	for _, id := range transitionIDs {
+		fx, err := h.transitions.Get(id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("transition %d not found", id), http.StatusNotFound)
+			return
+		}
+		transitionRows = append(transitionRows, pack.TransitionRow{Name: fx.Name, Direction: fx.Direction, CSS: fx.CSS})
+	}
+
+	manifest := pack.Manifest{
+		Name:    r.URL.Query().Get("name"),
+		Author:  r.URL.Query().Get("author"),
+		Version: r.URL.Query().Get("version"),
+	}
+	if manifest.Name == "" {
+		manifest.Name = "Untitled Pack"
+	}
+	if manifest.Version == "" {
+		manifest.Version = "1.0.0"
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+manifest.Name+`.vdjpack"`)
+	if err := pack.Build(w, manifest, overlayRows, transitionRows); err != nil {
+		slog.Error("build pack", "error", err)
+	}
+}
+
+// parseIDList splits a comma-separated list of integers, returning nil
+// for an empty string.
+func parseIDList(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	ids := make([]int, 0, len(parts))
+	for _, p := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// contentValidationConfig reads the pack_js_max_bytes/pack_js_denylist/
+// pack_url_allowlist config keys (falling back to pack's own defaults)
+// for pack.ValidateContent, shared by HandleImportPack and
+// HandleOverlayPreview since both validate untrusted CSS/HTML/JS before
+// it ever reaches a player.
+func (h *Handlers) contentValidationConfig() (maxJSBytes int, jsDenylist, urlAllowlist []string) {
+	maxJSBytes, err := strconv.Atoi(h.cfg.Get("pack_js_max_bytes", strconv.Itoa(pack.DefaultMaxJSBytes)))
+	if err != nil || maxJSBytes <= 0 {
+		maxJSBytes = pack.DefaultMaxJSBytes
+	}
+	jsDenylist = pack.DefaultJSDenylist
+	if raw := h.cfg.Get("pack_js_denylist", ""); raw != "" {
+		jsDenylist = strings.Split(raw, ",")
+	}
+	if raw := h.cfg.Get("pack_url_allowlist", ""); raw != "" {
+		urlAllowlist = strings.Split(raw, ",")
+	}
+	return maxJSBytes, jsDenylist, urlAllowlist
+}
+
+// HandleImportPack reads a .vdjpack archive from the request body,
+// verifies its checksums, runs every overlay/transition's CSS/HTML/JS
+// through pack.ValidateContent (size cap, JS denylist, and external-URL
+// allowlist, all config-driven), then resolves and applies the import
+// via pack.Apply inside a single transaction — either every row lands
+// or none do. Two query parameters control how collisions with an
+// existing overlay key or transition name+direction are handled:
+//
+//	mode=rename|overwrite|skip  (default rename — see pack.ImportMode)
+//	previewOnly=1               report the diff without writing anything
+func (h *Handlers) HandleImportPack(w http.ResponseWriter, r *http.Request) {
+	if h.overlayStore == nil {
+		http.Error(w, "packs not available", http.StatusNotFound)
+		return
+	}
+	mode := pack.ImportMode(r.URL.Query().Get("mode"))
+	switch mode {
+	case "", pack.ImportRename, pack.ImportOverwrite, pack.ImportSkip:
+	default:
+		http.Error(w, "invalid mode", http.StatusBadRequest)
+		return
+	}
+	previewOnly := r.URL.Query().Get("previewOnly") == "1"
+
+	defer r.Body.Close()
+	bundle, err := pack.Read(io.LimitReader(r.Body, maxPackBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	maxJSBytes, jsDenylist, urlAllowlist := h.contentValidationConfig()
+	for _, o := range bundle.Overlays {
+		if err := pack.ValidateContent(o.HTML, o.CSS, o.JS, maxJSBytes, jsDenylist, urlAllowlist); err != nil {
+			http.Error(w, fmt.Sprintf("overlay %q: %s", o.Key, err), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, t := range bundle.Transitions {
+		if err := pack.ValidateContent("", t.CSS, "", maxJSBytes, jsDenylist, urlAllowlist); err != nil {
+			http.Error(w, fmt.Sprintf("transition %q: %s", t.Name, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var packID string
+	if !previewOnly {
+		packID = pack.NewID()
+	}
+	result, err := pack.Apply(h.database, h.overlayStore, h.transitions, bundle, pack.ImportOptions{Mode: mode, PreviewOnly: previewOnly}, packID)
+	if err != nil {
+		slog.Error("import pack", "error", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if !previewOnly {
+		h.broadcastTransitionsUpdated()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// HandleUninstallPack removes every overlay element and transition effect
+// tagged with {packId}, reversing HandleImportPack.
+func (h *Handlers) HandleUninstallPack(w http.ResponseWriter, r *http.Request) {
+	if h.overlayStore == nil {
+		http.Error(w, "packs not available", http.StatusNotFound)
+		return
+	}
+	packID := r.PathValue("packId")
+	if packID == "" {
+		http.Error(w, "packId required", http.StatusBadRequest)
+		return
+	}
+	if err := h.overlayStore.DeleteByPackID(packID); err != nil {
+		slog.Error("uninstall pack overlays", "packId", packID, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.transitions.DeleteByPackID(packID); err != nil {
+		slog.Error("uninstall pack transitions", "packId", packID, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	h.broadcastTransitionsUpdated()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ── Video Library Scan ─────────────────────────────
+
+// HandleScanStatus reports the background scanner's progress (files
+// seen/analyzed/cached/errored, ETA) — see internal/scanner.
+func (h *Handlers) HandleScanStatus(w http.ResponseWriter, r *http.Request) {
+	if h.scanner == nil {
+		http.Error(w, "scanner not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.scanner.Status())
+}
+
+// HandleScanRescan forces the background scanner to re-walk the library
+// and re-analyze every file, ignoring the video_bpm cache. It returns
+// immediately; poll HandleScanStatus for progress.
+func (h *Handlers) HandleScanRescan(w http.ResponseWriter, r *http.Request) {
+	if h.scanner == nil {
+		http.Error(w, "scanner not available", http.StatusNotFound)
+		return
+	}
+	h.scanner.Rescan()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ── Overlay Live Preview ─────────────────────────────
+
+// HandleOverlayPreview validates a candidate {css, html, js, config} for
+// element id through the same pack.ValidateContent boundary as pack
+// import, then pushes it to every /ws/overlay-preview client currently
+// subscribed to that ID — without touching Store.Update, so authors can
+// iterate without persisting (or reloading) until they're happy.
+func (h *Handlers) HandleOverlayPreview(w http.ResponseWriter, r *http.Request) {
+	if h.overlayStore == nil || h.previewHub == nil {
+		http.Error(w, "overlay preview not available", http.StatusNotFound)
+		return
+	}
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.overlayStore.Get(id); err != nil {
+		http.Error(w, "overlay element not found", http.StatusNotFound)
+		return
+	}
+
+	var candidate overlay.Candidate
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&candidate); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	maxJSBytes, jsDenylist, urlAllowlist := h.contentValidationConfig()
+	if err := pack.ValidateContent(candidate.HTML, candidate.CSS, candidate.JS, maxJSBytes, jsDenylist, urlAllowlist); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.previewHub.Push(id, candidate)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleOverlayPreviewWS serves /ws/overlay-preview?id=<elementID>. The
+// connection receives "candidate" messages pushed by HandleOverlayPreview
+// for that element ID. Passing sim=1 also starts a synthetic DeckState
+// stream (overlay.Simulator) at ~30Hz so an author can iterate on
+// BPM-reactive animations offline; the client can retune it mid-stream by
+// sending a {"bpm","title","artist"} JSON control message.
+func (h *Handlers) HandleOverlayPreviewWS(w http.ResponseWriter, r *http.Request) {
+	if h.overlayStore == nil || h.previewHub == nil {
+		http.Error(w, "overlay preview not available", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.overlayStore.Get(id); err != nil {
+		http.Error(w, "overlay element not found", http.StatusNotFound)
+		return
+	}
+
+	conn, err := ws.Accept(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.previewHub.Register(conn, id)
+	defer func() {
+		h.previewHub.Unregister(conn)
+		conn.Close()
+	}()
+
+	if r.URL.Query().Get("sim") == "1" {
+		bpm := 120.0
+		if v, err := strconv.ParseFloat(r.URL.Query().Get("bpm"), 64); err == nil && v > 0 {
+			bpm = v
+		}
+		sim := overlay.NewSimulator(overlay.SimConfig{
+			BPM:    bpm,
+			Title:  r.URL.Query().Get("title"),
+			Artist: r.URL.Query().Get("artist"),
+		})
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go sim.Run(ctx, func(deck models.DeckState) {
+			if err := overlay.SendDeckState(conn, deck); err != nil {
+				cancel()
+			}
+		})
+
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var cfgMsg struct {
+				BPM    float64 `json:"bpm"`
+				Title  string  `json:"title"`
+				Artist string  `json:"artist"`
+			}
+			if err := json.Unmarshal(msg, &cfgMsg); err == nil {
+				sim.Configure(overlay.SimConfig{BPM: cfgMsg.BPM, Title: cfgMsg.Title, Artist: cfgMsg.Artist})
+			}
+		}
+	}
+
+	for {
+		if _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// ── Overlay Revision History ─────────────────────────────
+
+// HandleOverlayHistory lists element {id}'s saved revisions, most recent
+// first, for a "restore any previous version" panel — see
+// overlay.Store.History. Revision 0 is always the element's seed values.
+func (h *Handlers) HandleOverlayHistory(w http.ResponseWriter, r *http.Request) {
+	if h.overlayStore == nil {
+		http.Error(w, "overlay not available", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.overlayStore.Get(id); err != nil {
+		http.Error(w, "overlay element not found", http.StatusNotFound)
+		return
+	}
+
+	history, err := h.overlayStore.History(id)
+	if err != nil {
+		slog.Error("overlay history", "id", id, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// HandleOverlayRevert reverts element {id} to revision {rev}, snapshotting
+// its current state first so the revert itself can be undone — see
+// overlay.Store.Revert. Reverting to revision 0 is what the built-in
+// "restore default" action does.
+func (h *Handlers) HandleOverlayRevert(w http.ResponseWriter, r *http.Request) {
+	if h.overlayStore == nil {
+		http.Error(w, "overlay not available", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil {
+		http.Error(w, "invalid rev", http.StatusBadRequest)
+		return
+	}
+
+	el, err := h.overlayStore.Revert(id, rev)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("overlay revert", "id", id, "revision", rev, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(el)
+}
+
+// HandleTransitionHistory lists effect {id}'s saved revisions, most
+// recent first — the transitions.Store mirror of HandleOverlayHistory.
+func (h *Handlers) HandleTransitionHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.transitions.Get(id); err != nil {
+		http.Error(w, "transition effect not found", http.StatusNotFound)
+		return
+	}
+
+	history, err := h.transitions.History(id)
+	if err != nil {
+		slog.Error("transition history", "id", id, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// HandleTransitionRevert reverts effect {id} to revision {rev} — the
+// transitions.Store mirror of HandleOverlayRevert.
+func (h *Handlers) HandleTransitionRevert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil {
+		http.Error(w, "invalid rev", http.StatusBadRequest)
+		return
+	}
+
+	fx, err := h.transitions.Revert(id, rev)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "revision not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("transition revert", "id", id, "revision", rev, "error", err)
+		http.Error(w, "db error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fx)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jota2rz/vdj-video-sync/server/internal/bpm"
+	"github.com/jota2rz/vdj-video-sync/server/internal/models"
+	"github.com/jota2rz/vdj-video-sync/server/internal/rtmp"
+)
+
+// rtmpDeck is the virtual deck number an RTMP publish is broadcast as —
+// a live-mixed source VDJ never assigns a real deck number to, so it
+// sits past maxDecks (VDJ's own decks 1-4) rather than risking collision
+// with one. It's excluded from the maxDecks checks in HandleDeckUpdate
+// and friends since no VDJ plugin ever sends updates for it.
+const rtmpDeck = 5
+
+// rtmpBroadcastInterval throttles how often a live BPM re-estimate is
+// broadcast, matching the cadence VDJ's own polling updates arrive at
+// rather than firing once per incoming audio tag (every ~20-60ms).
+const rtmpBroadcastInterval = time.Second
+
+// rtmpState holds the per-publish state an RTMP ingest session needs:
+// the pipe feeding ffmpeg's HLS remux, and the audio decode/BPM pipeline
+// reading the same tags as they arrive. Guarded by rtmpMu since
+// OnAudioTag/OnVideoTag run on the rtmp.Server's connection goroutine,
+// concurrently with any HTTP handler.
+type rtmpState struct {
+	pipeWriter *io.PipeWriter
+	muxer      *rtmp.FLVMuxer
+	decoder    *bpm.LiveDecoder
+	detector   *bpm.StreamDetector
+	sampleRate int
+	pending    []float32 // decoded PCM awaiting a full ~1s window, see OnAudioTag
+	lastBPM    time.Time
+}
+
+// NewRTMPHandler returns an rtmp.Handler backed by h, wiring publish
+// events into the same hub.Broadcast("deck-update", ...) path
+// HandleDeckUpdate uses, video tags into the HLS subsystem (repackaged
+// to fMP4 via hlsMgr.StartLive), and audio tags into a streaming BPM
+// estimate (internal/bpm's LiveDecoder + StreamDetector).
+func (h *Handlers) NewRTMPHandler() rtmp.Handler {
+	return &rtmpHandler{h: h}
+}
+
+type rtmpHandler struct {
+	h *Handlers
+
+	mu    sync.Mutex
+	state *rtmpState
+}
+
+func (rh *rtmpHandler) OnPublishStart() {
+	h := rh.h
+	pr, pw := io.Pipe()
+
+	rh.mu.Lock()
+	rh.state = &rtmpState{
+		pipeWriter: pw,
+		muxer:      rtmp.NewFLVMuxer(pw, true, true),
+		decoder:    bpm.NewLiveDecoder(),
+	}
+	rh.mu.Unlock()
+
+	if h.hlsMgr != nil {
+		sourceKey := fmt.Sprintf("rtmp-%d", time.Now().UnixNano())
+		if err := h.hlsMgr.StartLive(rtmpDeck, sourceKey, pr); err != nil {
+			slog.Warn("rtmp: hls live session failed", "error", err)
+		}
+	}
+
+	slog.Info("rtmp publish started", "deck", rtmpDeck)
+	h.broadcastRTMPDeckState(true, 0)
+}
+
+func (rh *rtmpHandler) OnPublishStop() {
+	h := rh.h
+	rh.mu.Lock()
+	state := rh.state
+	rh.state = nil
+	rh.mu.Unlock()
+
+	if state != nil {
+		state.pipeWriter.Close()
+	}
+	if h.hlsMgr != nil {
+		h.hlsMgr.Stop(rtmpDeck)
+	}
+
+	slog.Info("rtmp publish stopped", "deck", rtmpDeck)
+	h.broadcastRTMPDeckState(false, 0)
+}
+
+func (rh *rtmpHandler) OnVideoTag(timestampMs uint32, payload []byte) {
+	rh.mu.Lock()
+	state := rh.state
+	rh.mu.Unlock()
+	if state == nil {
+		return
+	}
+	if err := state.muxer.WriteTag(9, timestampMs, payload); err != nil {
+		slog.Debug("rtmp: video tag write failed", "error", err)
+	}
+}
+
+func (rh *rtmpHandler) OnAudioTag(timestampMs uint32, payload []byte) {
+	rh.mu.Lock()
+	state := rh.state
+	rh.mu.Unlock()
+	if state == nil {
+		return
+	}
+	if err := state.muxer.WriteTag(8, timestampMs, payload); err != nil {
+		slog.Debug("rtmp: audio tag write failed", "error", err)
+	}
+
+	pcm, sampleRate, ok, err := state.decoder.DecodeAudioTag(payload)
+	if err != nil {
+		slog.Debug("rtmp: audio decode failed", "error", err)
+		return
+	}
+	if !ok || len(pcm) == 0 {
+		return
+	}
+
+	if state.detector == nil || state.sampleRate != sampleRate {
+		state.detector = bpm.NewStreamDetector(sampleRate)
+		state.sampleRate = sampleRate
+		state.pending = nil
+	}
+
+	// Accumulate decoded PCM into ~1-second windows before handing them
+	// to the tempogram — AAC/G.711/LPCM tags arrive every 20-40ms, and
+	// StreamDetector.Push re-runs the full STFT/autocorrelation pass over
+	// its rolling window each call, so doing that per tag would burn
+	// 25-50x the CPU the 1-second broadcast throttle below ever uses.
+	state.pending = append(state.pending, pcm...)
+	if len(state.pending) < sampleRate {
+		return
+	}
+	window := state.pending
+	state.pending = nil
+
+	result, ok := state.detector.Push(window)
+	if !ok || time.Since(state.lastBPM) < rtmpBroadcastInterval {
+		return
+	}
+	state.lastBPM = time.Now()
+	rh.h.broadcastRTMPDeckState(true, result.BPM)
+}
+
+// broadcastRTMPDeckState publishes the RTMP ingest's current state as a
+// "deck-update" SSE event, same shape and same cache-then-broadcast
+// pattern HandleDeckUpdate uses for VDJ's own decks, so existing clients
+// (the dashboard, the player) render it without any RTMP-specific logic.
+func (h *Handlers) broadcastRTMPDeckState(playing bool, bpmVal float64) {
+	event := struct {
+		models.DeckState
+		Timestamp   time.Time `json:"timestamp"`
+		HLSPlaylist string    `json:"hlsPlaylist,omitempty"`
+	}{
+		DeckState: models.DeckState{
+			Deck:      rtmpDeck,
+			IsAudible: playing,
+			IsPlaying: playing,
+			BPM:       bpmVal,
+			Filename:  "rtmp:publish",
+		},
+		Timestamp: time.Now(),
+	}
+	if playing && h.hlsMgr != nil {
+		event.HLSPlaylist = h.hlsMgr.PlaylistURL(rtmpDeck)
+	}
+
+	data, _ := json.Marshal(event)
+	sseMsg := fmt.Appendf(nil, "event: deck-update\ndata: %s\n\n", data)
+
+	h.deckCacheMu.Lock()
+	if playing {
+		h.deckCache[rtmpDeck] = sseMsg
+	} else {
+		delete(h.deckCache, rtmpDeck)
+	}
+	h.deckCacheMu.Unlock()
+
+	h.hub.BroadcastTopic(deckTopic(rtmpDeck), "deck-update", data)
+}
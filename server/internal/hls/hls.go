@@ -0,0 +1,233 @@
+// Package hls manages HLS (fMP4) output sessions so that
+// bandwidth-constrained or codec-picky clients (iOS/Safari in
+// particular) can stream video instead of downloading the whole file.
+// Two kinds of session share the same Manager: per-deck sessions (this
+// file) mirror whatever is currently playing and get torn down on idle;
+// library sessions (library.go) segment an on-demand library file once
+// and keep the result around for the player to seek and buffer within.
+//
+// Segmenting is delegated to ffmpeg rather than reimplemented in Go —
+// unlike the bpm package's audio analysis, HLS fMP4 muxing needs a
+// mature, well-tested implementation and ffmpeg is assumed to be on
+// $PATH (same assumption the rest of the deployment already makes for
+// video playback in the browser). For library files this is a remux
+// ("-c copy"), not a re-encode, so it costs startup latency rather than
+// quality or CPU.
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Manager owns one ffmpeg segmenting session per deck and the temp
+// directories their segments live in, plus on-demand segmenting sessions
+// for library files (see library.go).
+type Manager struct {
+	baseDir string // root temp directory; one subdirectory per deck
+
+	mu              sync.Mutex
+	sessions        map[int]*session
+	librarySessions map[string]*librarySession
+}
+
+type session struct {
+	sourcePath string
+	dir        string
+	cancel     context.CancelFunc
+	idleTimer  *time.Timer
+}
+
+// NewManager creates a Manager that writes segments under baseDir
+// (e.g. filepath.Join(os.TempDir(), "vdj-video-sync-hls")).
+func NewManager(baseDir string) *Manager {
+	return &Manager{
+		baseDir:         baseDir,
+		sessions:        make(map[int]*session),
+		librarySessions: make(map[string]*librarySession),
+	}
+}
+
+// BaseDir returns the root directory segments are written under, so
+// callers can serve them with their own file server without duplicating
+// this package's "deckN" naming scheme.
+func (m *Manager) BaseDir() string {
+	return m.baseDir
+}
+
+// PlaylistPath returns the on-disk path of a deck's playlist.
+func (m *Manager) PlaylistPath(deck int) string {
+	return filepath.Join(m.deckDir(deck), "index.m3u8")
+}
+
+// PlaylistURL returns the path clients should request, e.g.
+// "/hls/deck1/index.m3u8".
+func (m *Manager) PlaylistURL(deck int) string {
+	return fmt.Sprintf("/hls/deck%d/index.m3u8", deck)
+}
+
+func (m *Manager) deckDir(deck int) string {
+	return filepath.Join(m.baseDir, fmt.Sprintf("deck%d", deck))
+}
+
+// Start (re)segments sourcePath into an HLS fMP4 playlist for deck,
+// seeking to seekSeconds so the stream picks up at the same position
+// the currently-playing video is already at. If a session for this deck
+// and source is already running, Start is a no-op.
+func (m *Manager) Start(deck int, sourcePath string, seekSeconds float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[deck]; ok {
+		if s.sourcePath == sourcePath {
+			return nil // already streaming this video
+		}
+		m.stopLocked(deck)
+	}
+
+	dir := m.deckDir(deck)
+	if err := os.RemoveAll(dir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("hls: clear deck dir: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("hls: create deck dir: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	args := []string{
+		"-y",
+		"-ss", strconv.FormatFloat(seekSeconds, 'f', 3, 64),
+		"-i", sourcePath,
+		"-c", "copy",
+		"-f", "hls",
+		"-hls_time", "4",
+		"-hls_flags", "delete_segments+independent_segments",
+		"-hls_segment_type", "fmp4",
+		"-hls_playlist_type", "event",
+		filepath.Join(dir, "index.m3u8"),
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("hls: start ffmpeg: %w", err)
+	}
+
+	go func() {
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			slog.Warn("hls: ffmpeg session exited", "deck", deck, "error", err)
+		}
+	}()
+
+	m.sessions[deck] = &session{sourcePath: sourcePath, dir: dir, cancel: cancel}
+	slog.Info("hls session started", "deck", deck, "source", sourcePath, "seek", seekSeconds)
+	return nil
+}
+
+// StartLive (re)segments a live FLV byte stream (internal/rtmp's
+// FLVMuxer output, fed from an RTMP publish) into an HLS fMP4 playlist
+// for deck, the same way Start does for an on-disk file — just reading
+// from r via ffmpeg's stdin ("-i pipe:0") instead of a source path,
+// since there's no seekable file behind a live ingest. sourceKey
+// identifies the current publish (e.g. a per-connection ID) so a second
+// call for the *same* publish is a no-op, matching Start's
+// already-streaming check.
+func (m *Manager) StartLive(deck int, sourceKey string, r io.Reader) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[deck]; ok {
+		if s.sourcePath == sourceKey {
+			return nil // already streaming this publish
+		}
+		m.stopLocked(deck)
+	}
+
+	dir := m.deckDir(deck)
+	if err := os.RemoveAll(dir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("hls: clear deck dir: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("hls: create deck dir: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	args := []string{
+		"-y",
+		"-f", "flv",
+		"-i", "pipe:0",
+		"-c", "copy",
+		"-f", "hls",
+		"-hls_time", "2",
+		"-hls_flags", "delete_segments+independent_segments",
+		"-hls_segment_type", "fmp4",
+		"-hls_playlist_type", "event",
+		filepath.Join(dir, "index.m3u8"),
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = r
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("hls: start live ffmpeg: %w", err)
+	}
+
+	go func() {
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			slog.Warn("hls: live ffmpeg session exited", "deck", deck, "error", err)
+		}
+	}()
+
+	m.sessions[deck] = &session{sourcePath: sourceKey, dir: dir, cancel: cancel}
+	slog.Info("hls live session started", "deck", deck, "source", sourceKey)
+	return nil
+}
+
+// Stop tears down deck's session and removes its segment directory.
+func (m *Manager) Stop(deck int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopLocked(deck)
+}
+
+func (m *Manager) stopLocked(deck int) {
+	s, ok := m.sessions[deck]
+	if !ok {
+		return
+	}
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+	}
+	s.cancel()
+	delete(m.sessions, deck)
+	if err := os.RemoveAll(s.dir); err != nil {
+		slog.Warn("hls: cleanup segments failed", "deck", deck, "error", err)
+	}
+	slog.Info("hls session stopped", "deck", deck)
+}
+
+// ScheduleIdleStop arms a timer that stops deck's session after delay of
+// inactivity. Call it every time the deck reports activity (e.g. still
+// playing) to push the deadline back; the previous pending timer, if
+// any, is cancelled first. Mirrors the deck 3/4 auto-hide timer pattern.
+func (m *Manager) ScheduleIdleStop(deck int, delay time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[deck]
+	if !ok {
+		return
+	}
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+	}
+	s.idleTimer = time.AfterFunc(delay, func() {
+		m.Stop(deck)
+	})
+}
@@ -0,0 +1,115 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// librarySession is a long-lived, on-demand HLS remux of one library
+// file, as opposed to a deck session (see hls.go) which mirrors whatever
+// is currently playing and gets torn down on idle. A library file's
+// content doesn't change out from under us once matched, so its
+// playlist is cut once per (kind, id) and reused by every later request
+// instead of being re-segmented each time.
+type librarySession struct {
+	sourcePath string
+	dir        string
+	cancel     context.CancelFunc
+}
+
+// StartLibrary (re)segments a library video on disk at sourcePath into
+// an HLS fMP4 VOD playlist, identified by kind ("videos" or
+// "transition-videos", matching video.Matcher's URL prefixes) and id
+// (its filename). Like the deck sessions in hls.go, this shells out to
+// ffmpeg with "-c copy" — a remux, not a re-encode — so quality and file
+// size are unchanged; it just lets the player seek and buffer in
+// segments instead of fetching the whole file up front. A session
+// already running for the same source is reused, so StartLibrary is
+// cheap to call on every segment/playlist request.
+func (m *Manager) StartLibrary(kind, id, sourcePath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := libraryKey(kind, id)
+	if s, ok := m.librarySessions[key]; ok {
+		if s.sourcePath == sourcePath {
+			return nil // already segmented
+		}
+		m.stopLibraryLocked(key)
+	}
+
+	dir := m.libraryDir(kind, id)
+	if err := os.RemoveAll(dir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("hls: clear library dir: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("hls: create library dir: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	args := []string{
+		"-y",
+		"-i", sourcePath,
+		"-c", "copy",
+		"-f", "hls",
+		"-hls_time", "4",
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_type", "fmp4",
+		"-hls_fmp4_init_filename", "init.mp4",
+		"-hls_segment_filename", filepath.Join(dir, "seg%d.m4s"),
+		filepath.Join(dir, "master.m3u8"),
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("hls: start ffmpeg: %w", err)
+	}
+
+	go func() {
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			slog.Warn("hls: library ffmpeg session exited", "kind", kind, "id", id, "error", err)
+		}
+	}()
+
+	m.librarySessions[key] = &librarySession{sourcePath: sourcePath, dir: dir, cancel: cancel}
+	slog.Info("hls library session started", "kind", kind, "id", id, "source", sourcePath)
+	return nil
+}
+
+// LibraryDir returns the directory a library file's segments are (or
+// will be) written under, so callers can serve them with their own file
+// server.
+func (m *Manager) LibraryDir(kind, id string) string {
+	return m.libraryDir(kind, id)
+}
+
+// LibraryPlaylistURL returns the path clients should request, e.g.
+// "/hls/videos/track.mp4/master.m3u8".
+func (m *Manager) LibraryPlaylistURL(kind, id string) string {
+	return fmt.Sprintf("/hls/%s/%s/master.m3u8", kind, id)
+}
+
+func (m *Manager) libraryDir(kind, id string) string {
+	return filepath.Join(m.baseDir, "library", kind, id)
+}
+
+func libraryKey(kind, id string) string {
+	return kind + "/" + id
+}
+
+func (m *Manager) stopLibraryLocked(key string) {
+	s, ok := m.librarySessions[key]
+	if !ok {
+		return
+	}
+	s.cancel()
+	delete(m.librarySessions, key)
+	if err := os.RemoveAll(s.dir); err != nil {
+		slog.Warn("hls: library cleanup failed", "key", key, "error", err)
+	}
+}
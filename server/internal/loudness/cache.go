@@ -0,0 +1,75 @@
+package loudness
+
+import (
+	"database/sql"
+	"log/slog"
+	"os"
+)
+
+// Cache stores and retrieves analysed loudness values from SQLite.
+type Cache struct {
+	db *sql.DB
+}
+
+// NewCache creates a loudness cache backed by the given database.
+func NewCache(db *sql.DB) *Cache {
+	return &Cache{db: db}
+}
+
+// Get retrieves a cached gain/peak pair for the given file path and
+// modification time. Returns ok=false if not cached or if the file has
+// been modified since.
+func (c *Cache) Get(path string, modTime int64) (gainDB, peak float64, ok bool) {
+	err := c.db.QueryRow(
+		`SELECT gain_db, peak FROM video_loudness WHERE path = ? AND mod_time = ?`,
+		path, modTime,
+	).Scan(&gainDB, &peak)
+	if err != nil {
+		return 0, 0, false
+	}
+	return gainDB, peak, true
+}
+
+// Set stores a gain/peak pair for the given file path and modification time.
+func (c *Cache) Set(path string, modTime int64, gainDB, peak float64) error {
+	_, err := c.db.Exec(
+		`INSERT INTO video_loudness (path, gain_db, peak, mod_time) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET gain_db = excluded.gain_db, peak = excluded.peak, mod_time = excluded.mod_time`,
+		path, gainDB, peak, modTime,
+	)
+	return err
+}
+
+// Cleanup removes orphaned cache entries whose files no longer exist on disk.
+func (c *Cache) Cleanup() {
+	rows, err := c.db.Query(`SELECT path FROM video_loudness`)
+	if err != nil {
+		slog.Warn("loudness cache cleanup: query failed", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	var toDelete []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			toDelete = append(toDelete, path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.Warn("loudness cache cleanup: rows iteration error", "error", err)
+	}
+
+	for _, path := range toDelete {
+		if _, err := c.db.Exec(`DELETE FROM video_loudness WHERE path = ?`, path); err != nil {
+			slog.Warn("loudness cache cleanup: delete failed", "path", path, "error", err)
+		}
+	}
+
+	if len(toDelete) > 0 {
+		slog.Info("loudness cache cleanup", "removed", len(toDelete))
+	}
+}
@@ -0,0 +1,74 @@
+// Package loudness computes EBU R128 / ReplayGain-2.0-style integrated
+// loudness and true peak for video files, so decks and transition clips
+// can be played back at matched perceived loudness.
+//
+// This delegates to ffmpeg's ebur128 filter for the authoritative value:
+// EBU R128 is a precisely specified algorithm (ITU-R BS.1770 K-weighting
+// + gating), and ffmpeg already implements it correctly. The bpm package
+// additionally computes an approximate IntegratedLUFS/TruePeakDB as a
+// side effect of decoding PCM for BPM detection (see bpm.AnalyseResult);
+// Matcher falls back to that estimate only when ffmpeg isn't available
+// or hasn't analysed a file yet, and always prefers this package's value
+// once it exists.
+package loudness
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// TargetLUFS is the reference integrated loudness (matching common
+// streaming platforms) that gainDB normalises a track toward. Exported
+// so callers reusing bpm.AnalyseResult's IntegratedLUFS can compute the
+// same gain offset without duplicating the reference value.
+const TargetLUFS = -14.0
+
+var (
+	integratedRe = regexp.MustCompile(`^\s*I:\s*(-?[\d.]+) LUFS`)
+	truePeakRe   = regexp.MustCompile(`^\s*Peak:\s*(-?[\d.]+) dBFS`)
+)
+
+// AnalyseFile runs ffmpeg's ebur128 filter over path's audio track and
+// returns the ReplayGain-style adjustment (in dB, relative to
+// TargetLUFS) and the true peak level (in dBTP, 0 = full scale).
+// Returns an error if ffmpeg fails or the file has no parseable audio.
+func AnalyseFile(path string) (gainDB, peak float64, err error) {
+	cmd := exec.Command("ffmpeg", "-i", path, "-af", "ebur128=peak=true", "-f", "null", "-")
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return 0, 0, fmt.Errorf("loudness: stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, 0, fmt.Errorf("loudness: start ffmpeg: %w", err)
+	}
+
+	var integrated, truePeak float64
+	var haveIntegrated, havePeak bool
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case integratedRe.MatchString(line):
+			if v, perr := strconv.ParseFloat(integratedRe.FindStringSubmatch(line)[1], 64); perr == nil {
+				integrated, haveIntegrated = v, true
+			}
+		case truePeakRe.MatchString(line):
+			if v, perr := strconv.ParseFloat(truePeakRe.FindStringSubmatch(line)[1], 64); perr == nil {
+				truePeak, havePeak = v, true
+			}
+		}
+	}
+	waitErr := cmd.Wait()
+
+	if !haveIntegrated || !havePeak {
+		if waitErr != nil {
+			return 0, 0, fmt.Errorf("loudness: ffmpeg failed for %s: %w", path, waitErr)
+		}
+		return 0, 0, fmt.Errorf("loudness: no ebur128 summary for %s", path)
+	}
+
+	return TargetLUFS - integrated, truePeak, nil
+}
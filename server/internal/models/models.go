@@ -4,26 +4,46 @@ package models
 // received from the C++ plugin via HTTP POST.
 type DeckState struct {
 	Deck        int     `json:"deck"`
-	IsAudible   bool    `json:"isAudible"`   // is_audible: audible at all
-	IsPlaying   bool    `json:"isPlaying"`   // play: deck is currently playing
-	Volume      float64 `json:"volume"`      // get_volume: fader volume 0.0-1.0
-	ElapsedMs   int     `json:"elapsedMs"`   // get_time elapsed absolute (ms)
-	BPM         float64 `json:"bpm"`         // get_bpm
-	Filename    string  `json:"filename"`    // get_filename (no path)
-	Pitch       float64 `json:"pitch"`       // get_pitch_value, centered on 100%, used for video playbackRate
-	TotalTimeMs int     `json:"totalTimeMs"` // get_totaltime_ms: total song length in ms
-	Title       string  `json:"title"`       // get_title: song title metadata
-	Artist      string  `json:"artist"`      // get_artist: song artist metadata
+	IsAudible   bool    `json:"isAudible"`     // is_audible: audible at all
+	IsPlaying   bool    `json:"isPlaying"`     // play: deck is currently playing
+	Volume      float64 `json:"volume"`        // get_volume: fader volume 0.0-1.0
+	ElapsedMs   int     `json:"elapsedMs"`     // get_time elapsed absolute (ms)
+	BPM         float64 `json:"bpm"`           // get_bpm
+	Filename    string  `json:"filename"`      // get_filename (no path)
+	Pitch       float64 `json:"pitch"`         // get_pitch_value, centered on 100%, used for video playbackRate
+	TotalTimeMs int     `json:"totalTimeMs"`   // get_totaltime_ms: total song length in ms
+	Title       string  `json:"title"`         // get_title: song title metadata
+	Artist      string  `json:"artist"`        // get_artist: song artist metadata
+	Key         string  `json:"key,omitempty"` // get_key: detected musical key, Camelot wheel notation, if the plugin reports one
 }
 
 // VideoFile represents a video available for playback.
 type VideoFile struct {
-	Name       string  `json:"name"`
-	Path       string  `json:"path"`
-	BPM        float64 `json:"bpm,omitempty"`
-	MatchType  string  `json:"matchType,omitempty"`  // legacy compat
-	MatchLevel int     `json:"matchLevel"`           // 0-5 tiered match
-	Similarity float64 `json:"similarity,omitempty"` // 0-1 filename similarity
+	Name         string    `json:"name"`
+	Path         string    `json:"path"`
+	BPM          float64   `json:"bpm,omitempty"`
+	Duration     float64   `json:"duration,omitempty"`     // seconds; 0 if unknown
+	MatchType    string    `json:"matchType,omitempty"`    // legacy compat
+	MatchLevel   int       `json:"matchLevel"`             // 0-6 tiered match
+	Similarity   float64   `json:"similarity,omitempty"`   // 0-1 filename similarity
+	Variants     []Variant `json:"variants,omitempty"`     // lower-resolution siblings, sorted highest bitrate first
+	GainDB       float64   `json:"gainDb,omitempty"`       // ReplayGain-style adjustment, dB relative to target LUFS; 0 if not yet analysed
+	Peak         float64   `json:"peak,omitempty"`         // true peak, dBTP; 0 if not yet analysed
+	Key          string    `json:"key,omitempty"`          // detected musical key, Camelot wheel notation (e.g. "8A")
+	Genre        string    `json:"genre,omitempty"`        // detected genre tag (MP4 atom, ID3v2 TCON, etc.)
+	HLSURL       string    `json:"hlsUrl,omitempty"`       // on-demand HLS playlist, for players that want seek/buffer over Path's raw download
+	FirstBeatSec float64   `json:"firstBeatSec,omitempty"` // phase offset of the first detected beat, seconds; lets the player schedule cues on beat boundaries
+}
+
+// Variant is a lower-resolution encode of a VideoFile, produced by an
+// offline transcode step and discovered alongside the original file.
+// Clients on constrained connections are served a variant's Path instead
+// of VideoFile.Path; the sync/elapsed math is bitrate-independent so it
+// doesn't need to know which variant a client is watching.
+type Variant struct {
+	Label       string `json:"label"`       // e.g. "720p", "480p"
+	Path        string `json:"path"`        // served path
+	BitrateKbps int    `json:"bitrateKbps"` // approximate encode bitrate
 }
 
 // ConfigEntry is a key-value pair stored in the database.
@@ -34,12 +54,31 @@ type ConfigEntry struct {
 
 // TransitionEffect represents a CSS transition effect stored in the database.
 type TransitionEffect struct {
-	ID        int    `json:"id"`
-	Name      string `json:"name"`
-	Direction string `json:"direction"` // "in" or "out"
-	CSS       string `json:"css"`
-	Enabled   bool   `json:"enabled"`
-	IsSeed    bool   `json:"isSeed"`
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	Direction  string  `json:"direction"` // "in" or "out"
+	CSS        string  `json:"css"`
+	Enabled    bool    `json:"enabled"`
+	IsSeed     bool    `json:"isSeed"`
+	VoteWeight float64 `json:"voteWeight"`       // audience-vote bias, see poll.WeightedWinner
+	PackID     string  `json:"packId,omitempty"` // set when imported via internal/pack
+}
+
+// PollSession is one round of audience voting (internal/poll) on what
+// plays next: a transition "in"/"out" effect or an overlay theme.
+type PollSession struct {
+	ID       int    `json:"id"`
+	Subject  string `json:"subject"` // "transition_in", "transition_out", or "overlay"
+	Status   string `json:"status"`  // "open" or "closed"
+	Quorum   int    `json:"quorum"`  // minimum total votes before a winner is honored
+	WinnerID int    `json:"winnerId,omitempty"`
+}
+
+// PollTally is the live vote count for one option of an open PollSession.
+type PollTally struct {
+	OptionID int    `json:"optionId"`
+	Name     string `json:"name"`
+	Votes    int    `json:"votes"`
 }
 
 // OverlayElement represents a configurable on-screen overlay element.
@@ -56,4 +95,45 @@ type OverlayElement struct {
 	Verb               string `json:"verb"`               // VDJ verb used to obtain data (empty for custom)
 	Config             string `json:"config"`             // JSON config (e.g. custom text value)
 	ShowOverTransition bool   `json:"showOverTransition"` // show above transition videos
+	PackID             string `json:"packId,omitempty"`   // set when imported via internal/pack
+}
+
+// OverlayRevision is one saved snapshot from overlay.Store's version
+// history (see Store.History/Revert/Diff). Revision 0 always holds the
+// element's seed or pack-import values, so RestoreDefaults is just a
+// revert to revision 0.
+type OverlayRevision struct {
+	Revision           int    `json:"revision"`
+	Name               string `json:"name"`
+	CSS                string `json:"css"`
+	HTML               string `json:"html"`
+	JS                 string `json:"js"`
+	Config             string `json:"config"`
+	ShowOverTransition bool   `json:"showOverTransition"`
+	CreatedAt          string `json:"createdAt,omitempty"` // empty for the live, not-yet-snapshotted revision
+	Note               string `json:"note,omitempty"`
+}
+
+// OverlayDiff is a unified-diff comparison of two OverlayRevisions, one
+// diff per field that can change.
+type OverlayDiff struct {
+	CSS  string `json:"css"`
+	HTML string `json:"html"`
+	JS   string `json:"js"`
+}
+
+// TransitionRevision is transitions.Store's version-history counterpart
+// to OverlayRevision.
+type TransitionRevision struct {
+	Revision  int    `json:"revision"`
+	Name      string `json:"name"`
+	Direction string `json:"direction"`
+	CSS       string `json:"css"`
+	CreatedAt string `json:"createdAt,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+// TransitionDiff is a unified-diff comparison of two TransitionRevisions.
+type TransitionDiff struct {
+	CSS string `json:"css"`
 }
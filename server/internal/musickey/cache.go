@@ -0,0 +1,75 @@
+package musickey
+
+import (
+	"database/sql"
+	"log/slog"
+	"os"
+)
+
+// Cache stores and retrieves analysed musical keys from SQLite.
+type Cache struct {
+	db *sql.DB
+}
+
+// NewCache creates a key cache backed by the given database.
+func NewCache(db *sql.DB) *Cache {
+	return &Cache{db: db}
+}
+
+// Get retrieves a cached Camelot key for the given file path and
+// modification time. Returns ok=false if not cached or if the file has
+// been modified since.
+func (c *Cache) Get(path string, modTime int64) (key string, ok bool) {
+	err := c.db.QueryRow(
+		`SELECT camelot_key FROM video_key WHERE path = ? AND mod_time = ?`,
+		path, modTime,
+	).Scan(&key)
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// Set stores a Camelot key for the given file path and modification time.
+func (c *Cache) Set(path string, modTime int64, key string) error {
+	_, err := c.db.Exec(
+		`INSERT INTO video_key (path, camelot_key, mod_time) VALUES (?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET camelot_key = excluded.camelot_key, mod_time = excluded.mod_time`,
+		path, key, modTime,
+	)
+	return err
+}
+
+// Cleanup removes orphaned cache entries whose files no longer exist on disk.
+func (c *Cache) Cleanup() {
+	rows, err := c.db.Query(`SELECT path FROM video_key`)
+	if err != nil {
+		slog.Warn("key cache cleanup: query failed", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	var toDelete []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			toDelete = append(toDelete, path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.Warn("key cache cleanup: rows iteration error", "error", err)
+	}
+
+	for _, path := range toDelete {
+		if _, err := c.db.Exec(`DELETE FROM video_key WHERE path = ?`, path); err != nil {
+			slog.Warn("key cache cleanup: delete failed", "path", path, "error", err)
+		}
+	}
+
+	if len(toDelete) > 0 {
+		slog.Info("key cache cleanup", "removed", len(toDelete))
+	}
+}
@@ -0,0 +1,94 @@
+// Package musickey detects musical key and scores harmonic compatibility
+// using Camelot wheel notation, so the transition pool can favor clips
+// that mix cleanly with whatever the active deck is currently playing.
+//
+// Detection is delegated to keyfinder-cli (a thin CLI over libkeyfinder's
+// chroma-based key estimator) rather than reimplemented — a reliable key
+// estimator needs a full chromagram plus a Krumhansl-Schmuckler-style
+// profile match, and libkeyfinder already does that well.
+package musickey
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Camelot is a parsed Camelot wheel position, e.g. "8A" → {8, "A"}.
+// Letter is "A" for minor keys, "B" for major.
+type Camelot struct {
+	Number int
+	Letter string
+}
+
+// String returns the Camelot notation, e.g. "8A".
+func (c Camelot) String() string {
+	return fmt.Sprintf("%d%s", c.Number, c.Letter)
+}
+
+// Parse parses a Camelot code such as "8A" or "12B". Matching is
+// case-insensitive; ok is false if code isn't a valid 1-12 + A/B code.
+func Parse(code string) (c Camelot, ok bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < 2 {
+		return Camelot{}, false
+	}
+	letter := code[len(code)-1:]
+	if letter != "A" && letter != "B" {
+		return Camelot{}, false
+	}
+	n, err := strconv.Atoi(code[:len(code)-1])
+	if err != nil || n < 1 || n > 12 {
+		return Camelot{}, false
+	}
+	return Camelot{Number: n, Letter: letter}, true
+}
+
+// Distance scores harmonic compatibility between two Camelot keys:
+//
+//	0 — identical key
+//	1 — compatible: adjacent on the wheel (±1, same letter) or the
+//	    relative major/minor (same number, opposite letter)
+//	2 — incompatible
+func Distance(a, b Camelot) int {
+	if a == b {
+		return 0
+	}
+	if a.Letter == b.Letter && wheelDelta(a.Number, b.Number) == 1 {
+		return 1
+	}
+	if a.Letter != b.Letter && a.Number == b.Number {
+		return 1
+	}
+	return 2
+}
+
+// wheelDelta returns the shortest distance between two wheel positions
+// (1-12), wrapping around the 12→1 boundary.
+func wheelDelta(a, b int) int {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	if d > 6 {
+		d = 12 - d
+	}
+	return d
+}
+
+// AnalyseFile runs keyfinder-cli against path's audio track and returns
+// its detected key in Camelot notation (e.g. "8A"). Returns an error if
+// the tool isn't available, fails, or its output doesn't parse as a
+// Camelot code.
+func AnalyseFile(path string) (string, error) {
+	out, err := exec.Command("keyfinder-cli", "-n", "camelot", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("musickey: keyfinder-cli: %w", err)
+	}
+	code := strings.TrimSpace(string(out))
+	if _, ok := Parse(code); !ok {
+		return "", fmt.Errorf("musickey: unparseable key output %q", code)
+	}
+	return code, nil
+}
@@ -0,0 +1,22 @@
+// Package ndi optionally mirrors the active deck's mix to the network as
+// an NDI video source, so VJ software like Resolume or Millumin can pull
+// it in on the LAN, the same way internal/stream mirrors it as HTTP-FLV.
+//
+// This module is gated behind the "ndi" build tag. Like internal/hls and
+// internal/stream, it delegates muxing to ffmpeg rather than binding the
+// proprietary NDI SDK directly — but unlike those two, the ffmpeg build
+// required (libndi_newtek output support) isn't something most
+// deployments have, so it isn't compiled in by default. Building with
+// `-tags ndi` swaps in the real ffmpeg-backed Manager (ndi_enabled.go);
+// without the tag, Manager's methods are no-ops (ndi_disabled.go) so
+// internal/handlers can use it unconditionally without its own
+// build-tag plumbing.
+//
+// The ffmpeg libndi_newtek muxer only carries the video signal — it has
+// no hook for custom metadata frames — so transition sync (the inCSS/
+// outCSS strings and slot index) can't ride inline in the NDI stream.
+// Downstream tools that want it should subscribe to the existing SSE
+// "transition-play" event alongside the NDI source, the same way
+// internal/stream already recommends for FLV/RTMP consumers lacking
+// inline transition signaling.
+package ndi
@@ -0,0 +1,22 @@
+//go:build !ndi
+
+package ndi
+
+// Manager is a no-op stand-in used when the binary is built without the
+// "ndi" tag. See the package doc.
+type Manager struct{}
+
+// NewManager returns a no-op Manager.
+func NewManager() *Manager { return &Manager{} }
+
+// SetSource does nothing; NDI output isn't compiled into this binary.
+func (m *Manager) SetSource(sourcePath, sourceName string) error { return nil }
+
+// Stop does nothing.
+func (m *Manager) Stop() {}
+
+// Publishing always reports false.
+func (m *Manager) Publishing() bool { return false }
+
+// SendMetadata does nothing.
+func (m *Manager) SendMetadata(slot int, inCSS, outCSS string) {}
@@ -0,0 +1,100 @@
+//go:build ndi
+
+package ndi
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"sync"
+)
+
+// Manager owns the ffmpeg process that mirrors a source video to an NDI
+// sender named sourceName.
+type Manager struct {
+	mu         sync.Mutex
+	sourcePath string
+	sourceName string
+	cmd        *exec.Cmd
+	cancel     func()
+}
+
+// NewManager creates an idle Manager; call SetSource to start sending.
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// SetSource (re)starts the ffmpeg session to mirror sourcePath to an NDI
+// sender named sourceName, doing nothing if it's already sending that
+// exact path under that name. Either argument empty stops the session.
+func (m *Manager) SetSource(sourcePath, sourceName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sourcePath == sourcePath && m.sourceName == sourceName && m.cmd != nil {
+		return nil
+	}
+	m.stopLocked()
+	if sourcePath == "" || sourceName == "" {
+		return nil
+	}
+
+	cmd := exec.Command("ffmpeg", "-y", "-re", "-i", sourcePath, "-f", "libndi_newtek", sourceName)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("ndi: start ffmpeg: %w", err)
+	}
+
+	m.sourcePath = sourcePath
+	m.sourceName = sourceName
+	m.cmd = cmd
+	m.cancel = func() { cmd.Process.Kill() }
+
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			slog.Warn("ndi output session exited", "error", err)
+		}
+		m.mu.Lock()
+		if m.cmd == cmd {
+			m.cmd = nil
+			m.sourcePath = ""
+			m.sourceName = ""
+			m.cancel = nil
+		}
+		m.mu.Unlock()
+	}()
+
+	slog.Info("ndi output session started", "source", sourcePath, "name", sourceName)
+	return nil
+}
+
+// Stop tears down the running ffmpeg session, if any.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopLocked()
+}
+
+func (m *Manager) stopLocked() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.cmd = nil
+	m.sourcePath = ""
+	m.sourceName = ""
+	m.cancel = nil
+}
+
+// Publishing reports whether an ffmpeg session is currently running.
+func (m *Manager) Publishing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cmd != nil
+}
+
+// SendMetadata logs the transition metadata that would accompany this
+// slot change. The libndi_newtek muxer has no hook for custom metadata
+// frames, so this doesn't reach the NDI stream itself — see the package
+// doc for how downstream tools should sync instead.
+func (m *Manager) SendMetadata(slot int, inCSS, outCSS string) {
+	slog.Debug("ndi transition metadata", "slot", slot, "inCSS", inCSS, "outCSS", outCSS)
+}
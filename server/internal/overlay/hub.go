@@ -0,0 +1,112 @@
+package overlay
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/jota2rz/vdj-video-sync/server/internal/models"
+	"github.com/jota2rz/vdj-video-sync/server/internal/ws"
+)
+
+// Candidate is an element's unsaved CSS/HTML/JS/config, as submitted to
+// HandleOverlayPreview. It mirrors the fields Store.Update persists, but
+// Hub.Push only ever forwards it to subscribed previewers — nothing here
+// touches the database.
+type Candidate struct {
+	CSS    string `json:"css"`
+	HTML   string `json:"html"`
+	JS     string `json:"js"`
+	Config string `json:"config"`
+}
+
+// previewMessage is the envelope sent over /ws/overlay-preview. Type is
+// "candidate" for a live-edit push (see Push) or "deck-state" for a
+// simulator tick (see Simulator) — a preview client dispatches on Type
+// rather than needing two separate sockets.
+type previewMessage struct {
+	Type      string            `json:"type"`
+	ElementID int               `json:"elementId,omitempty"`
+	Candidate *Candidate        `json:"candidate,omitempty"`
+	Deck      *models.DeckState `json:"deck,omitempty"`
+}
+
+// Hub fans out live-edit candidates to WebSocket clients subscribed to a
+// specific overlay element ID. It mirrors poll.Hub's register/unregister
+// shape over the same raw ws.Conn, but keyed by element ID rather than
+// broadcasting to every client — a preview iframe only ever cares about
+// the one element it's editing.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*ws.Conn]int // conn -> subscribed element ID
+}
+
+// NewHub creates an empty Hub.
+func NewHub() *Hub {
+	return &Hub{clients: make(map[*ws.Conn]int)}
+}
+
+// Register subscribes c to candidate pushes for elementID.
+func (h *Hub) Register(c *ws.Conn, elementID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = elementID
+}
+
+// Unregister removes a client connection from the hub.
+func (h *Hub) Unregister(c *ws.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+// Push sends candidate to every client currently subscribed to
+// elementID. A client whose write fails is evicted rather than letting
+// it block delivery to the rest.
+func (h *Hub) Push(elementID int, candidate Candidate) {
+	data, err := json.Marshal(previewMessage{Type: "candidate", ElementID: elementID, Candidate: &candidate})
+	if err != nil {
+		slog.Warn("overlay preview: marshal candidate failed", "error", err)
+		return
+	}
+
+	h.mu.RLock()
+	dead := make([]*ws.Conn, 0)
+	for c, id := range h.clients {
+		if id != elementID {
+			continue
+		}
+		if err := c.WriteMessage(data); err != nil {
+			slog.Warn("overlay preview ws client write failed", "error", err)
+			dead = append(dead, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(dead) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, c := range dead {
+		delete(h.clients, c)
+	}
+	h.mu.Unlock()
+}
+
+// SendDeckState writes a single simulator tick directly to c, bypassing
+// the elementID subscriber fan-out — the simulator stream is specific to
+// one preview connection, not broadcast to every editor of that element.
+func SendDeckState(c *ws.Conn, deck models.DeckState) error {
+	data, err := json.Marshal(previewMessage{Type: "deck-state", Deck: &deck})
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(data)
+}
+
+// Count returns the number of connected preview clients.
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
@@ -0,0 +1,95 @@
+package overlay
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jota2rz/vdj-video-sync/server/internal/models"
+)
+
+// simTickRate is how often the simulator emits a DeckState — "~30 Hz" per
+// the preview iframe's animation needs.
+const simTickRate = 33 * time.Millisecond
+
+// defaultSimTotalTimeMs is the synthetic track length used to wrap
+// ElapsedMs back to 0, standing in for a real deck's get_totaltime_ms.
+const defaultSimTotalTimeMs = 3*60*1000 + 30*1000 // 3:30, a plausible track length
+
+// SimConfig is the synthetic deck an author edits against when no VDJ
+// instance is running. BPM, Title, and Artist feed straight into the
+// emitted DeckState; ElapsedMs ramps continuously on its own and isn't
+// configurable beyond the total track length it wraps at.
+type SimConfig struct {
+	BPM         float64
+	Title       string
+	Artist      string
+	TotalTimeMs int
+}
+
+// Simulator generates a synthetic DeckState stream for offline overlay
+// editing (see HandleOverlayPreviewWS's sim=1 mode). It always reports
+// IsPlaying/IsAudible true and Volume 1 — a simulated deck exists only to
+// drive BPM-reactive animation, not to model pause/mute states.
+type Simulator struct {
+	mu  sync.Mutex
+	cfg SimConfig
+}
+
+// NewSimulator creates a Simulator with the given starting config.
+// BPM <= 0 and TotalTimeMs <= 0 fall back to reasonable defaults.
+func NewSimulator(cfg SimConfig) *Simulator {
+	s := &Simulator{}
+	s.Configure(cfg)
+	return s
+}
+
+// Configure updates the simulator's BPM/title/artist/track length,
+// taking effect on the next tick. Safe to call concurrently with Run —
+// intended as the target of a preview client's "configure" control
+// message arriving mid-stream.
+func (s *Simulator) Configure(cfg SimConfig) {
+	if cfg.BPM <= 0 {
+		cfg.BPM = 120
+	}
+	if cfg.TotalTimeMs <= 0 {
+		cfg.TotalTimeMs = defaultSimTotalTimeMs
+	}
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+}
+
+// Run ticks at simTickRate, computing ElapsedMs as a steady ramp from
+// start that wraps at the configured track length, and calls send with
+// the resulting DeckState. It blocks until ctx is cancelled.
+func (s *Simulator) Run(ctx context.Context, send func(models.DeckState)) {
+	start := time.Now()
+	ticker := time.NewTicker(simTickRate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			cfg := s.cfg
+			s.mu.Unlock()
+
+			elapsed := int(time.Since(start).Milliseconds()) % cfg.TotalTimeMs
+			send(models.DeckState{
+				Deck:        1,
+				IsAudible:   true,
+				IsPlaying:   true,
+				Volume:      1,
+				ElapsedMs:   elapsed,
+				BPM:         cfg.BPM,
+				Pitch:       100,
+				TotalTimeMs: cfg.TotalTimeMs,
+				Title:       cfg.Title,
+				Artist:      cfg.Artist,
+			})
+		}
+	}
+}
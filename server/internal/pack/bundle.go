@@ -0,0 +1,159 @@
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const manifestPath = "manifest.json"
+
+// Build writes a .vdjpack archive to w: a gzip-compressed tar containing
+// manifest.json plus one JSON file per overlay/transition row. Manifest's
+// Checksums field is computed here and overwrites whatever the caller
+// passed in.
+func Build(w io.Writer, manifest Manifest, overlays []OverlayRow, transitions []TransitionRow) error {
+	entries := make(map[string][]byte, len(overlays)+len(transitions))
+	for i, o := range overlays {
+		b, err := json.MarshalIndent(o, "", "  ")
+		if err != nil {
+			return fmt.Errorf("pack: marshal overlay %d: %w", i, err)
+		}
+		entries[fmt.Sprintf("overlays/%d.json", i)] = b
+	}
+	for i, t := range transitions {
+		b, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return fmt.Errorf("pack: marshal transition %d: %w", i, err)
+		}
+		entries[fmt.Sprintf("transitions/%d.json", i)] = b
+	}
+
+	manifest.Checksums = make(map[string]string, len(entries))
+	for path, b := range entries {
+		sum := sha256.Sum256(b)
+		manifest.Checksums[path] = hex.EncodeToString(sum[:])
+	}
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("pack: marshal manifest: %w", err)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	if err := writeEntry(tw, manifestPath, manifestBytes); err != nil {
+		return err
+	}
+	// Deterministic order so two builds of the same content produce the
+	// same archive layout.
+	paths := make([]string, 0, len(entries))
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := writeEntry(tw, path, entries[path]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, content []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0o644,
+		Size: int64(len(content)),
+	}); err != nil {
+		return fmt.Errorf("pack: write header %s: %w", name, err)
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// Read parses and checksum-verifies a .vdjpack archive. It returns an
+// error if manifest.json is missing, an entry listed in its Checksums is
+// missing or doesn't match, or any overlay/transition JSON fails to
+// decode.
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("pack: not a gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	entries := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pack: reading tar entry: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("pack: reading %s: %w", hdr.Name, err)
+		}
+		entries[hdr.Name] = b
+	}
+
+	manifestBytes, ok := entries[manifestPath]
+	if !ok {
+		return nil, fmt.Errorf("pack: missing %s", manifestPath)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("pack: decode manifest: %w", err)
+	}
+
+	for path, want := range manifest.Checksums {
+		content, ok := entries[path]
+		if !ok {
+			return nil, fmt.Errorf("pack: manifest references missing entry %s", path)
+		}
+		sum := sha256.Sum256(content)
+		if got := hex.EncodeToString(sum[:]); got != want {
+			return nil, fmt.Errorf("pack: checksum mismatch for %s", path)
+		}
+	}
+
+	bundle := &Bundle{Manifest: manifest}
+	paths := make([]string, 0, len(manifest.Checksums))
+	for path := range manifest.Checksums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		switch {
+		case strings.HasPrefix(path, "overlays/"):
+			var o OverlayRow
+			if err := json.Unmarshal(entries[path], &o); err != nil {
+				return nil, fmt.Errorf("pack: decode %s: %w", path, err)
+			}
+			bundle.Overlays = append(bundle.Overlays, o)
+		case strings.HasPrefix(path, "transitions/"):
+			var t TransitionRow
+			if err := json.Unmarshal(entries[path], &t); err != nil {
+				return nil, fmt.Errorf("pack: decode %s: %w", path, err)
+			}
+			bundle.Transitions = append(bundle.Transitions, t)
+		}
+	}
+	return bundle, nil
+}
@@ -0,0 +1,18 @@
+package pack
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewID generates a random identifier for a freshly-imported pack, used
+// to tag its rows' pack_id column so they can later be removed together.
+func NewID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand failing is effectively unrecoverable; fall back to
+		// a fixed-but-unique-enough value rather than panicking.
+		return "pack-fallback"
+	}
+	return "pack-" + hex.EncodeToString(buf)
+}
@@ -0,0 +1,294 @@
+package pack
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jota2rz/vdj-video-sync/server/internal/overlay"
+	"github.com/jota2rz/vdj-video-sync/server/internal/transitions"
+)
+
+// ImportMode controls how Plan/Apply handle a collision between an
+// incoming row and an existing row with the same stable key (an
+// overlay's key, or a transition's name+direction pair).
+type ImportMode string
+
+const (
+	// ImportRename is the default: a colliding row is created under a
+	// renamed key/name ("<key>-2", "<key>-3", ...) instead of touching
+	// the existing row.
+	ImportRename ImportMode = "rename"
+	// ImportOverwrite replaces the existing row's content in place and
+	// tags it with the importing pack's ID.
+	ImportOverwrite ImportMode = "overwrite"
+	// ImportSkip leaves the existing row untouched.
+	ImportSkip ImportMode = "skip"
+)
+
+// ImportOptions configures Plan and Apply.
+type ImportOptions struct {
+	Mode ImportMode
+	// PreviewOnly makes Apply compute and return the plan without
+	// writing anything, so a caller can show "this will create 3,
+	// update 1, skip 2" before the user commits to it.
+	PreviewOnly bool
+}
+
+// ImportAction describes what Apply did (or, under PreviewOnly, would
+// do) with one incoming row.
+type ImportAction string
+
+const (
+	ActionCreate ImportAction = "create"
+	ActionUpdate ImportAction = "update"
+	ActionSkip   ImportAction = "skip"
+)
+
+// OverlayPlan is one incoming overlay row's resolved action. Row.Key
+// reflects the key actually used — renamed under ImportRename — so the
+// caller never needs to recompute it.
+type OverlayPlan struct {
+	Row        OverlayRow   `json:"row"`
+	Action     ImportAction `json:"action"`
+	ExistingID int          `json:"existingId,omitempty"`
+}
+
+// TransitionPlan is one incoming transition row's resolved action.
+type TransitionPlan struct {
+	Row        TransitionRow `json:"row"`
+	Action     ImportAction  `json:"action"`
+	ExistingID int           `json:"existingId,omitempty"`
+}
+
+// ImportResult summarizes a Plan or Apply run — the diff a UI shows
+// before committing, or the record of what Apply actually did.
+type ImportResult struct {
+	PackID      string           `json:"packId,omitempty"`
+	Overlays    []OverlayPlan    `json:"overlays"`
+	Transitions []TransitionPlan `json:"transitions"`
+	Created     int              `json:"created"`
+	Updated     int              `json:"updated"`
+	Skipped     int              `json:"skipped"`
+}
+
+func (r *ImportResult) tally(a ImportAction) {
+	switch a {
+	case ActionCreate:
+		r.Created++
+	case ActionUpdate:
+		r.Updated++
+	case ActionSkip:
+		r.Skipped++
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// uniqueOverlayKey returns base if it's free (checked against both
+// overlayStore and keys already claimed earlier in this same import via
+// seen), or base with an incrementing "-N" suffix.
+func uniqueOverlayKey(overlayStore *overlay.Store, base string, seen map[string]bool) (string, error) {
+	key := base
+	for n := 2; ; n++ {
+		if !seen[key] {
+			_, err := overlayStore.FindByKey(key)
+			if err == sql.ErrNoRows {
+				seen[key] = true
+				return key, nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("pack: look up overlay %q: %w", key, err)
+			}
+		}
+		key = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// uniqueTransitionName is uniqueOverlayKey's transitions counterpart,
+// keyed by name+direction rather than a single column.
+func uniqueTransitionName(transitionStore *transitions.Store, base, direction string, seen map[string]bool) (string, error) {
+	name := base
+	for n := 2; ; n++ {
+		seenKey := name + "\x00" + direction
+		if !seen[seenKey] {
+			_, err := transitionStore.FindByNameDirection(name, direction)
+			if err == sql.ErrNoRows {
+				seen[seenKey] = true
+				return name, nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("pack: look up transition %q: %w", name, err)
+			}
+		}
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+func planOverlay(overlayStore *overlay.Store, row OverlayRow, mode ImportMode, seen map[string]bool) (OverlayPlan, error) {
+	existing, err := overlayStore.FindByKey(row.Key)
+	switch {
+	case err == sql.ErrNoRows:
+		key, kerr := uniqueOverlayKey(overlayStore, row.Key, seen)
+		if kerr != nil {
+			return OverlayPlan{}, kerr
+		}
+		row.Key = key
+		return OverlayPlan{Row: row, Action: ActionCreate}, nil
+	case err != nil:
+		return OverlayPlan{}, fmt.Errorf("pack: look up overlay %q: %w", row.Key, err)
+	case existing.IsSeed:
+		// Built-in elements can't be overwritten or reassigned to a
+		// pack (see overlay.ErrSeedProtected) — overwrite silently
+		// degrades to skip rather than mode=rename's create, since the
+		// importer asked to collide with this exact key.
+		return OverlayPlan{Row: row, Action: ActionSkip, ExistingID: existing.ID}, nil
+	case mode == ImportOverwrite:
+		return OverlayPlan{Row: row, Action: ActionUpdate, ExistingID: existing.ID}, nil
+	case mode == ImportSkip:
+		return OverlayPlan{Row: row, Action: ActionSkip, ExistingID: existing.ID}, nil
+	default: // ImportRename
+		key, kerr := uniqueOverlayKey(overlayStore, row.Key, seen)
+		if kerr != nil {
+			return OverlayPlan{}, kerr
+		}
+		row.Key = key
+		return OverlayPlan{Row: row, Action: ActionCreate}, nil
+	}
+}
+
+func planTransition(transitionStore *transitions.Store, row TransitionRow, mode ImportMode, seen map[string]bool) (TransitionPlan, error) {
+	existing, err := transitionStore.FindByNameDirection(row.Name, row.Direction)
+	switch {
+	case err == sql.ErrNoRows:
+		name, kerr := uniqueTransitionName(transitionStore, row.Name, row.Direction, seen)
+		if kerr != nil {
+			return TransitionPlan{}, kerr
+		}
+		row.Name = name
+		return TransitionPlan{Row: row, Action: ActionCreate}, nil
+	case err != nil:
+		return TransitionPlan{}, fmt.Errorf("pack: look up transition %q: %w", row.Name, err)
+	case existing.IsSeed:
+		// Built-in effects can't be overwritten or reassigned to a pack
+		// (see transitions.ErrSeedProtected) — overwrite silently
+		// degrades to skip rather than mode=rename's create, since the
+		// importer asked to collide with this exact name+direction.
+		return TransitionPlan{Row: row, Action: ActionSkip, ExistingID: existing.ID}, nil
+	case mode == ImportOverwrite:
+		return TransitionPlan{Row: row, Action: ActionUpdate, ExistingID: existing.ID}, nil
+	case mode == ImportSkip:
+		return TransitionPlan{Row: row, Action: ActionSkip, ExistingID: existing.ID}, nil
+	default: // ImportRename
+		name, kerr := uniqueTransitionName(transitionStore, row.Name, row.Direction, seen)
+		if kerr != nil {
+			return TransitionPlan{}, kerr
+		}
+		row.Name = name
+		return TransitionPlan{Row: row, Action: ActionCreate}, nil
+	}
+}
+
+// Plan resolves every row in bundle against overlayStore/transitionStore
+// under mode, without writing anything.
+func Plan(overlayStore *overlay.Store, transitionStore *transitions.Store, bundle *Bundle, mode ImportMode) (*ImportResult, error) {
+	if mode == "" {
+		mode = ImportRename
+	}
+	result := &ImportResult{}
+
+	seenKeys := map[string]bool{}
+	for _, row := range bundle.Overlays {
+		p, err := planOverlay(overlayStore, row, mode, seenKeys)
+		if err != nil {
+			return nil, err
+		}
+		result.Overlays = append(result.Overlays, p)
+		result.tally(p.Action)
+	}
+
+	seenNames := map[string]bool{}
+	for _, row := range bundle.Transitions {
+		p, err := planTransition(transitionStore, row, mode, seenNames)
+		if err != nil {
+			return nil, err
+		}
+		result.Transitions = append(result.Transitions, p)
+		result.tally(p.Action)
+	}
+
+	return result, nil
+}
+
+// Apply computes the same plan Plan would and, unless opts.PreviewOnly,
+// applies every create/update inside one transaction — either every row
+// lands or none do. packID tags every created or overwritten row so the
+// whole import can later be removed as a group via DeleteByPackID; pass
+// "" when opts.PreviewOnly since nothing is written. Callers are
+// expected to have already run every row's CSS/HTML/JS through
+// ValidateContent (see HandleImportPack) — Apply itself does not
+// sanitize.
+func Apply(db *sql.DB, overlayStore *overlay.Store, transitionStore *transitions.Store, bundle *Bundle, opts ImportOptions, packID string) (*ImportResult, error) {
+	result, err := Plan(overlayStore, transitionStore, bundle, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PreviewOnly {
+		return result, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, p := range result.Overlays {
+		switch p.Action {
+		case ActionCreate:
+			if _, err := tx.Exec(
+				`INSERT INTO overlay_elements (key, name, css, html, js, is_seed, data_type, verb, config, enabled, show_over_transition, pack_id)
+				 VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?, 1, ?, ?)`,
+				p.Row.Key, p.Row.Name, p.Row.CSS, p.Row.HTML, p.Row.JS, p.Row.DataType, p.Row.Verb, p.Row.Config, boolToInt(p.Row.ShowOverTransition), packID,
+			); err != nil {
+				return nil, fmt.Errorf("pack: create overlay %q: %w", p.Row.Key, err)
+			}
+		case ActionUpdate:
+			if _, err := tx.Exec(
+				`UPDATE overlay_elements SET name = ?, css = ?, html = ?, js = ?, data_type = ?, verb = ?, config = ?, show_over_transition = ?, pack_id = ? WHERE id = ?`,
+				p.Row.Name, p.Row.CSS, p.Row.HTML, p.Row.JS, p.Row.DataType, p.Row.Verb, p.Row.Config, boolToInt(p.Row.ShowOverTransition), packID, p.ExistingID,
+			); err != nil {
+				return nil, fmt.Errorf("pack: update overlay %q: %w", p.Row.Key, err)
+			}
+		}
+	}
+
+	for _, p := range result.Transitions {
+		switch p.Action {
+		case ActionCreate:
+			if _, err := tx.Exec(
+				"INSERT INTO transition_effects (name, direction, css, enabled, is_seed, pack_id) VALUES (?, ?, ?, 1, 0, ?)",
+				p.Row.Name, p.Row.Direction, p.Row.CSS, packID,
+			); err != nil {
+				return nil, fmt.Errorf("pack: create transition %q: %w", p.Row.Name, err)
+			}
+		case ActionUpdate:
+			if _, err := tx.Exec(
+				"UPDATE transition_effects SET css = ?, pack_id = ? WHERE id = ?",
+				p.Row.CSS, packID, p.ExistingID,
+			); err != nil {
+				return nil, fmt.Errorf("pack: update transition %q: %w", p.Row.Name, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.PackID = packID
+	return result, nil
+}
@@ -0,0 +1,55 @@
+// Package pack implements the .vdjpack bundle format: a tar+gzip archive
+// containing a manifest.json (name/author/version/per-entry checksums)
+// plus one JSON file per overlay element or transition effect, so a DJ
+// can share a themed set of overlays/transitions as a single file and
+// install it on another deck without touching the database directly.
+//
+// The manifest's checksums are an integrity check, not an authenticity
+// one: they're computed from the bundle's own content, so they catch
+// truncation or corruption in transit but prove nothing about who built
+// the bundle. Import still runs untrusted JS through the sandbox
+// allowlist in import.go; don't treat "checksum verified" as "safe to
+// trust blindly."
+package pack
+
+// Manifest describes a .vdjpack bundle's contents. It is written to
+// manifest.json at the archive root; Checksums maps every other entry's
+// archive path to the sha256 hex digest of its bytes, so Read can detect
+// a truncated or corrupted bundle before any row is inserted.
+type Manifest struct {
+	Name      string            `json:"name"`
+	Author    string            `json:"author"`
+	Version   string            `json:"version"`
+	Checksums map[string]string `json:"checksums"`
+}
+
+// OverlayRow is the serialized form of one overlay element inside a pack.
+// It mirrors models.OverlayElement minus the fields that are specific to
+// this database (ID, Enabled, IsSeed, PackID).
+type OverlayRow struct {
+	Key                string `json:"key"`
+	Name               string `json:"name"`
+	CSS                string `json:"css"`
+	HTML               string `json:"html"`
+	JS                 string `json:"js"`
+	DataType           string `json:"dataType"`
+	Verb               string `json:"verb"`
+	Config             string `json:"config"`
+	ShowOverTransition bool   `json:"showOverTransition"`
+}
+
+// TransitionRow is the serialized form of one transition effect inside a
+// pack. It mirrors models.TransitionEffect minus the fields that are
+// specific to this database (ID, Enabled, IsSeed, VoteWeight, PackID).
+type TransitionRow struct {
+	Name      string `json:"name"`
+	Direction string `json:"direction"`
+	CSS       string `json:"css"`
+}
+
+// Bundle is a parsed, checksum-verified .vdjpack archive.
+type Bundle struct {
+	Manifest    Manifest
+	Overlays    []OverlayRow
+	Transitions []TransitionRow
+}
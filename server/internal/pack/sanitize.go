@@ -0,0 +1,90 @@
+package pack
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxJSBytes caps a single overlay row's JS payload when the
+// importer doesn't override it via the pack_js_max_bytes config key.
+const DefaultMaxJSBytes = 32 * 1024
+
+// DefaultJSDenylist is the set of substrings ValidateJS rejects by
+// default, overridable via the pack_js_denylist config key (comma-
+// separated). Each entry is a capability no overlay animation needs and
+// that a malicious pack could use to exfiltrate viewer data or escape
+// the player's overlay sandbox. This is a lightweight heuristic, not a
+// real JS sandbox: it catches an unmodified copy-pasted exfiltration
+// snippet, not a determined attacker willing to obfuscate.
+var DefaultJSDenylist = []string{
+	"fetch(", "XMLHttpRequest", "WebSocket", "eval(", "Function(",
+	"document.cookie", "localStorage", "sessionStorage", "indexedDB",
+	"import(", "sendBeacon",
+}
+
+// ValidateJS rejects js if it exceeds maxBytes or contains any token in
+// denylist.
+func ValidateJS(js string, maxBytes int, denylist []string) error {
+	if len(js) > maxBytes {
+		return fmt.Errorf("pack: js exceeds %d byte limit", maxBytes)
+	}
+	for _, token := range denylist {
+		if token == "" {
+			continue
+		}
+		if strings.Contains(js, token) {
+			return fmt.Errorf("pack: js contains disallowed token %q", token)
+		}
+	}
+	return nil
+}
+
+// externalURLPattern matches an http(s) or protocol-relative ("//host/...")
+// URL used as a <script>/<img>/etc. src or href attribute, or a CSS
+// url(...) reference. Protocol-relative URLs resolve to whatever scheme
+// the page was loaded over, so they're just as external as an explicit
+// https:// one and have to be caught the same way.
+var externalURLPattern = regexp.MustCompile(`(?i)(?:src|href)\s*=\s*["']?((?:https?:)?//[^"'\s>)]+)|url\(\s*["']?((?:https?:)?//[^"')]+)`)
+
+// ValidateContent extends ValidateJS to html and css: html may not
+// contain a <script> tag (pack JS runs through the element's own JS
+// field and IIFE hook instead, see overlay.Store), and neither html nor
+// css may reference an external http(s) URL unless its host appears in
+// allowedHosts. An empty allowedHosts rejects every external URL.
+func ValidateContent(html, css, js string, maxJSBytes int, jsDenylist, allowedHosts []string) error {
+	if err := ValidateJS(js, maxJSBytes, jsDenylist); err != nil {
+		return err
+	}
+	if strings.Contains(strings.ToLower(html), "<script") {
+		return fmt.Errorf("pack: html may not contain a <script> tag")
+	}
+	for _, content := range []string{html, css} {
+		for _, m := range externalURLPattern.FindAllStringSubmatch(content, -1) {
+			raw := m[1]
+			if raw == "" {
+				raw = m[2]
+			}
+			if !hostAllowed(raw, allowedHosts) {
+				return fmt.Errorf("pack: external URL %q is not in the allowed host list", raw)
+			}
+		}
+	}
+	return nil
+}
+
+// hostAllowed reports whether rawURL's host matches an entry in
+// allowedHosts (case-insensitive, exact match — no wildcards).
+func hostAllowed(rawURL string, allowedHosts []string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	for _, host := range allowedHosts {
+		if host != "" && strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package phash
+
+import "sort"
+
+// Tree is a BK-tree over 64-bit fingerprints, keyed by Hamming distance.
+// It gives FindWithin/Nearest O(log n) average-case lookups instead of
+// the linear scan a plain slice of fingerprints would need, which
+// matters once a library holds thousands of videos and every Match()
+// call may consult it.
+type Tree struct {
+	root *node
+}
+
+type node struct {
+	fp       uint64
+	value    int
+	children map[int]*node // keyed by Hamming distance from this node's fp
+}
+
+// Match is one BK-tree lookup result.
+type Match struct {
+	Value    int // the value Insert was called with
+	Distance int // Hamming distance from the query fingerprint
+}
+
+// NewTree builds a BK-tree from parallel fps/values slices — values lets
+// the caller reuse its own indexing scheme (e.g. an index into a
+// Matcher's indexed slice) instead of the tree inventing IDs of its own.
+func NewTree(fps []uint64, values []int) *Tree {
+	t := &Tree{}
+	for i, fp := range fps {
+		t.Insert(fp, values[i])
+	}
+	return t
+}
+
+// Insert adds one fingerprint to the tree.
+func (t *Tree) Insert(fp uint64, value int) {
+	n := &node{fp: fp, value: value}
+	if t.root == nil {
+		t.root = n
+		return
+	}
+	cur := t.root
+	for {
+		d := Hamming(cur.fp, fp)
+		if cur.children == nil {
+			cur.children = make(map[int]*node)
+		}
+		child, ok := cur.children[d]
+		if !ok {
+			cur.children[d] = n
+			return
+		}
+		cur = child
+	}
+}
+
+// FindWithin returns every indexed fingerprint within maxDist of query,
+// nearest first.
+func (t *Tree) FindWithin(query uint64, maxDist int) []Match {
+	if t.root == nil {
+		return nil
+	}
+	var out []Match
+	var visit func(n *node)
+	visit = func(n *node) {
+		d := Hamming(n.fp, query)
+		if d <= maxDist {
+			out = append(out, Match{Value: n.value, Distance: d})
+		}
+		// Triangle inequality: any match within maxDist of query can only
+		// live under a child whose edge distance (to n) is within
+		// [d-maxDist, d+maxDist] — every other subtree is provably too far.
+		for dist, child := range n.children {
+			if dist >= d-maxDist && dist <= d+maxDist {
+				visit(child)
+			}
+		}
+	}
+	visit(t.root)
+	sort.Slice(out, func(i, j int) bool { return out[i].Distance < out[j].Distance })
+	return out
+}
+
+// Nearest returns the single closest fingerprint to query, or ok=false
+// if the tree is empty or nothing lies within maxDist.
+func (t *Tree) Nearest(query uint64, maxDist int) (Match, bool) {
+	matches := t.FindWithin(query, maxDist)
+	if len(matches) == 0 {
+		return Match{}, false
+	}
+	return matches[0], true
+}
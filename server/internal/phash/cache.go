@@ -0,0 +1,79 @@
+package phash
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"log/slog"
+	"os"
+)
+
+// Cache stores and retrieves computed fingerprints from SQLite.
+type Cache struct {
+	db *sql.DB
+}
+
+// NewCache creates a fingerprint cache backed by the given database.
+func NewCache(db *sql.DB) *Cache {
+	return &Cache{db: db}
+}
+
+// Get retrieves a cached fingerprint for the given file path and
+// modification time. Returns ok=false if not cached or if the file has
+// been modified since.
+func (c *Cache) Get(path string, modTime int64) (uint64, bool) {
+	var raw []byte
+	err := c.db.QueryRow(
+		`SELECT fingerprint FROM video_phash WHERE path = ? AND mod_time = ?`,
+		path, modTime,
+	).Scan(&raw)
+	if err != nil || len(raw) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(raw), true
+}
+
+// Set stores a fingerprint for the given file path and modification time.
+func (c *Cache) Set(path string, modTime int64, fp uint64) error {
+	raw := make([]byte, 8)
+	binary.BigEndian.PutUint64(raw, fp)
+	_, err := c.db.Exec(
+		`INSERT INTO video_phash (path, fingerprint, mod_time) VALUES (?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET fingerprint = excluded.fingerprint, mod_time = excluded.mod_time`,
+		path, raw, modTime,
+	)
+	return err
+}
+
+// Cleanup removes orphaned cache entries whose files no longer exist on disk.
+func (c *Cache) Cleanup() {
+	rows, err := c.db.Query(`SELECT path FROM video_phash`)
+	if err != nil {
+		slog.Warn("phash cache cleanup: query failed", "error", err)
+		return
+	}
+	defer rows.Close()
+
+	var toDelete []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			toDelete = append(toDelete, path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.Warn("phash cache cleanup: rows iteration error", "error", err)
+	}
+
+	for _, path := range toDelete {
+		if _, err := c.db.Exec(`DELETE FROM video_phash WHERE path = ?`, path); err != nil {
+			slog.Warn("phash cache cleanup: delete failed", "path", path, "error", err)
+		}
+	}
+
+	if len(toDelete) > 0 {
+		slog.Info("phash cache cleanup", "removed", len(toDelete))
+	}
+}
@@ -0,0 +1,226 @@
+// Package phash computes a 64-bit perceptual hash for video files, so
+// video.Matcher can recognize the same clip (or a visually similar one)
+// even when its filename and BPM both fail to connect it to a song.
+//
+// Frame extraction and scaling is delegated to ffmpeg rather than
+// decoded here, the same delegation internal/loudness and
+// internal/musickey use for their own ffmpeg-backed analysis. Hashing
+// itself follows the classic pHash recipe: downscale a sampled frame to
+// hashSize x hashSize greyscale, run a 2D DCT, keep the low-frequency
+// lowFreqSize x lowFreqSize corner, and threshold each coefficient
+// against their block's median to get one bit. A small, uniform re-encode
+// (different bitrate, container, or light color grading) barely moves
+// those low-frequency coefficients, so near-duplicate clips hash to
+// fingerprints a handful of bits apart while unrelated clips land far
+// enough apart in Hamming space to tell them apart.
+package phash
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/bits"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// frameSamples is how many evenly spaced frames are hashed per video and
+// combined into its fingerprint.
+const frameSamples = 10
+
+// hashSize is the greyscale frame each sample is downscaled to before
+// the DCT.
+const hashSize = 32
+
+// lowFreqSize is the top-left low-frequency corner of the hashSize x
+// hashSize DCT kept for hashing, giving exactly lowFreqSize*lowFreqSize
+// = 64 bits per frame.
+const lowFreqSize = 8
+
+var durationRe = regexp.MustCompile(`Duration: (\d+):(\d+):(\d+\.\d+)`)
+
+// Fingerprint computes path's 64-bit visual fingerprint: frameSamples
+// evenly spaced frames are each hashed independently, then combined bit
+// by bit via majority vote (a spatiotemporal average of the per-frame
+// hashes) into a single 64-bit value — keeping the fingerprint the same
+// width the BK-tree indexes by Hamming distance, and tolerating the
+// occasional black or transition frame throwing off one sample.
+// Returns an error if ffmpeg can't be run or no frame could be decoded.
+func Fingerprint(path string) (uint64, error) {
+	dur, err := probeDuration(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var hashes []uint64
+	for i := 0; i < frameSamples; i++ {
+		ts := dur * time.Duration(i+1) / time.Duration(frameSamples+1)
+		pixels, err := extractFrame(path, ts)
+		if err != nil {
+			continue // one bad sample (e.g. a black frame) shouldn't fail the whole fingerprint
+		}
+		hashes = append(hashes, hashFrame(pixels))
+	}
+	if len(hashes) == 0 {
+		return 0, fmt.Errorf("phash: no frames decoded for %s", path)
+	}
+
+	var fp uint64
+	for bit := 0; bit < 64; bit++ {
+		set := 0
+		for _, h := range hashes {
+			if h&(1<<uint(bit)) != 0 {
+				set++
+			}
+		}
+		if set*2 >= len(hashes) {
+			fp |= 1 << uint(bit)
+		}
+	}
+	return fp, nil
+}
+
+// Hamming returns the number of differing bits between two fingerprints.
+func Hamming(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// probeDuration returns a video's duration by parsing ffmpeg's own
+// stderr banner (ffmpeg prints "Duration: HH:MM:SS.ms" for any input it
+// can open), avoiding a second probing tool just to pick sample offsets.
+func probeDuration(path string) (time.Duration, error) {
+	cmd := exec.Command("ffmpeg", "-i", path)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return 0, fmt.Errorf("phash: stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("phash: start ffmpeg: %w", err)
+	}
+
+	var dur time.Duration
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		m := durationRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		h, _ := strconv.Atoi(m[1])
+		mnt, _ := strconv.Atoi(m[2])
+		sec, _ := strconv.ParseFloat(m[3], 64)
+		dur = time.Duration(h)*time.Hour + time.Duration(mnt)*time.Minute + time.Duration(sec*float64(time.Second))
+		break
+	}
+	cmd.Wait() // ffmpeg given no output exits non-zero; we only needed its stderr banner
+
+	if dur <= 0 {
+		return 0, fmt.Errorf("phash: no parseable duration for %s", path)
+	}
+	return dur, nil
+}
+
+// extractFrame decodes the single frame at ts into a hashSize x hashSize
+// greyscale pixel grid (row-major, one byte per pixel).
+func extractFrame(path string, ts time.Duration) ([]byte, error) {
+	args := []string{
+		"-ss", fmt.Sprintf("%.3f", ts.Seconds()),
+		"-i", path,
+		"-vframes", "1",
+		"-vf", fmt.Sprintf("scale=%d:%d:flags=bilinear,format=gray", hashSize, hashSize),
+		"-f", "rawvideo",
+		"-",
+	}
+	out, err := exec.Command("ffmpeg", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("phash: extract frame at %s: %w", ts, err)
+	}
+	want := hashSize * hashSize
+	if len(out) < want {
+		return nil, fmt.Errorf("phash: short frame (%d of %d bytes) at %s", len(out), want, ts)
+	}
+	return out[:want], nil
+}
+
+// hashFrame computes a 64-bit hash from one hashSize x hashSize
+// greyscale frame: a 2D DCT concentrates the frame's structure into its
+// low-frequency top-left corner, and thresholding each of those
+// lowFreqSize*lowFreqSize coefficients against their own median turns
+// them into bits that are robust to the frame's absolute brightness.
+func hashFrame(pixels []byte) uint64 {
+	f := dct2D(pixels, hashSize)
+
+	coeffs := make([]float64, 0, lowFreqSize*lowFreqSize)
+	for i := 0; i < lowFreqSize; i++ {
+		for j := 0; j < lowFreqSize; j++ {
+			coeffs = append(coeffs, f[i][j])
+		}
+	}
+	median := medianOf(coeffs)
+
+	var hash uint64
+	for bit, v := range coeffs {
+		if v > median {
+			hash |= 1 << uint(bit)
+		}
+	}
+	return hash
+}
+
+// dct2D applies a 2D DCT-II to an n x n grid of greyscale pixel values,
+// via the standard separable row-then-column decomposition.
+func dct2D(pixels []byte, n int) [][]float64 {
+	f := make([][]float64, n)
+	for i := range f {
+		f[i] = make([]float64, n)
+		for j := range f[i] {
+			f[i][j] = float64(pixels[i*n+j])
+		}
+	}
+	for i := 0; i < n; i++ {
+		f[i] = dct1D(f[i])
+	}
+	col := make([]float64, n)
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			col[i] = f[i][j]
+		}
+		col = dct1D(col)
+		for i := 0; i < n; i++ {
+			f[i][j] = col[i]
+		}
+	}
+	return f
+}
+
+// dct1D computes the 1D DCT-II of in, orthonormalized (the same
+// normalization JPEG/pHash implementations use).
+func dct1D(in []float64) []float64 {
+	n := len(in)
+	out := make([]float64, n)
+	for k := 0; k < n; k++ {
+		var sum float64
+		for i := 0; i < n; i++ {
+			sum += in[i] * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+		}
+		c := math.Sqrt(2.0 / float64(n))
+		if k == 0 {
+			c = math.Sqrt(1.0 / float64(n))
+		}
+		out[k] = sum * c
+	}
+	return out
+}
+
+// medianOf returns the median of vs without mutating the caller's slice.
+func medianOf(vs []float64) float64 {
+	sorted := append([]float64(nil), vs...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
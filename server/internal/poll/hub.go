@@ -0,0 +1,66 @@
+package poll
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/jota2rz/vdj-video-sync/server/internal/ws"
+)
+
+// Hub fans out poll-tally updates to connected WebSocket clients. It
+// mirrors sse.Hub's register/unregister/broadcast shape, but over the
+// raw ws.Conn returned by internal/ws instead of SSE's chan-based Client.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*ws.Conn]bool
+}
+
+// NewHub creates an empty Hub.
+func NewHub() *Hub {
+	return &Hub{clients: make(map[*ws.Conn]bool)}
+}
+
+// Register adds a client connection to the hub.
+func (h *Hub) Register(c *ws.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = true
+}
+
+// Unregister removes a client connection from the hub.
+func (h *Hub) Unregister(c *ws.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+// Broadcast sends data to every connected client. A client whose write
+// fails (slow consumer, dropped connection) is evicted rather than
+// letting it block the rest of the broadcast.
+func (h *Hub) Broadcast(data []byte) {
+	h.mu.RLock()
+	dead := make([]*ws.Conn, 0)
+	for c := range h.clients {
+		if err := c.WriteMessage(data); err != nil {
+			slog.Warn("poll ws client write failed", "error", err)
+			dead = append(dead, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(dead) == 0 {
+		return
+	}
+	h.mu.Lock()
+	for _, c := range dead {
+		delete(h.clients, c)
+	}
+	h.mu.Unlock()
+}
+
+// Count returns the number of connected clients.
+func (h *Hub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
@@ -0,0 +1,133 @@
+// Package poll implements the "jukebox-style" audience voting subsystem:
+// viewers cast votes through the HTTP API, tallies are pushed to
+// connected WebSocket clients via Hub, and the handlers package resolves
+// a winner on each track change, falling back to the existing random
+// pick when turnout doesn't reach the session's quorum.
+package poll
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jota2rz/vdj-video-sync/server/internal/models"
+)
+
+// Subjects a poll session can be opened for, determining which table
+// poll_votes.option_id refers to.
+const (
+	SubjectTransitionIn  = "transition_in"  // transition_effects.id, direction "in"
+	SubjectTransitionOut = "transition_out" // transition_effects.id, direction "out"
+	SubjectOverlay       = "overlay"        // overlay_elements.id
+)
+
+// ErrAlreadyVoted is returned by Vote when viewerID already has a
+// recorded vote in the session.
+var ErrAlreadyVoted = errors.New("viewer already voted in this session")
+
+// Store provides CRUD for audience voting sessions and votes.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a Store backed by the given database.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Open closes any still-open session for subject, then starts a fresh one
+// with the given quorum (the minimum total votes Tallies must see before
+// a winner is honored; see poll.WeightedWinner).
+func (s *Store) Open(subject string, quorum int) (*models.PollSession, error) {
+	if _, err := s.db.Exec(
+		"UPDATE poll_sessions SET status = 'closed', closed_at = CURRENT_TIMESTAMP WHERE subject = ? AND status = 'open'",
+		subject,
+	); err != nil {
+		return nil, err
+	}
+	res, err := s.db.Exec("INSERT INTO poll_sessions (subject, status, quorum) VALUES (?, 'open', ?)", subject, quorum)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return &models.PollSession{ID: int(id), Subject: subject, Status: "open", Quorum: quorum}, nil
+}
+
+// Active returns the open session for subject, or nil if there isn't one.
+func (s *Store) Active(subject string) (*models.PollSession, error) {
+	var p models.PollSession
+	var winnerID sql.NullInt64
+	err := s.db.QueryRow(
+		"SELECT id, subject, status, quorum, winner_id FROM poll_sessions WHERE subject = ? AND status = 'open' ORDER BY id DESC LIMIT 1",
+		subject,
+	).Scan(&p.ID, &p.Subject, &p.Status, &p.Quorum, &winnerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if winnerID.Valid {
+		p.WinnerID = int(winnerID.Int64)
+	}
+	return &p, nil
+}
+
+// Vote records viewerID's vote for optionID in sessionID. Returns
+// ErrAlreadyVoted if viewerID already has a vote in this session — the
+// one-vote-per-viewer guard, enforced by poll_votes' UNIQUE(session_id,
+// viewer_id) constraint.
+func (s *Store) Vote(sessionID, optionID int, viewerID string) error {
+	res, err := s.db.Exec(
+		"INSERT OR IGNORE INTO poll_votes (session_id, option_id, viewer_id) VALUES (?, ?, ?)",
+		sessionID, optionID, viewerID,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAlreadyVoted
+	}
+	return nil
+}
+
+// Tallies returns the vote count per option for sessionID, and the total
+// number of votes cast.
+func (s *Store) Tallies(sessionID int) (counts map[int]int, total int, err error) {
+	rows, err := s.db.Query(
+		"SELECT option_id, COUNT(*) FROM poll_votes WHERE session_id = ? GROUP BY option_id",
+		sessionID,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	counts = make(map[int]int)
+	for rows.Next() {
+		var optionID, count int
+		if err := rows.Scan(&optionID, &count); err != nil {
+			return nil, 0, err
+		}
+		counts[optionID] = count
+		total += count
+	}
+	return counts, total, rows.Err()
+}
+
+// Close marks sessionID closed, recording winnerID (0 if the random
+// fallback was used instead of an audience-voted winner).
+func (s *Store) Close(sessionID, winnerID int) error {
+	var winner any
+	if winnerID != 0 {
+		winner = winnerID
+	}
+	_, err := s.db.Exec(
+		"UPDATE poll_sessions SET status = 'closed', closed_at = CURRENT_TIMESTAMP, winner_id = ? WHERE id = ?",
+		winner, sessionID,
+	)
+	return err
+}
@@ -0,0 +1,39 @@
+package poll
+
+import "math/rand/v2"
+
+// WeightedWinner picks an option at random from tallies, weighted by each
+// option's vote count times its DJ-assigned bias (weight), so ties favor
+// whichever effect the DJ has turned up without making the outcome
+// deterministic. Returns false if tallies is empty or every option's
+// effective weight is zero or negative.
+func WeightedWinner(tallies map[int]int, weight func(optionID int) float64) (winnerID int, ok bool) {
+	type entry struct {
+		id     int
+		weight float64
+	}
+	entries := make([]entry, 0, len(tallies))
+	total := 0.0
+	for id, votes := range tallies {
+		w := float64(votes) * weight(id)
+		if w <= 0 {
+			continue
+		}
+		entries = append(entries, entry{id: id, weight: w})
+		total += w
+	}
+	if total <= 0 {
+		return 0, false
+	}
+
+	r := rand.Float64() * total
+	for _, e := range entries {
+		r -= e.weight
+		if r <= 0 {
+			return e.id, true
+		}
+	}
+	// Floating-point rounding can leave r slightly positive after the
+	// last entry; fall through to it rather than report no winner.
+	return entries[len(entries)-1].id, true
+}
@@ -0,0 +1,183 @@
+// Package queue maintains an operator-curated playlist of videos that
+// takes priority over the matcher's tiered fallback. Entries move
+// through three sections as playback progresses — Done (already
+// played), Playing (current), and Ahead (upcoming) — mirroring the
+// played/now-playing/upcoming model of a typical streaming player queue.
+package queue
+
+import "github.com/jota2rz/vdj-video-sync/server/internal/models"
+
+// Queue is an ordered playlist with shuffle and loop modes.
+// Zero value is a ready-to-use empty queue.
+type Queue struct {
+	Done    []models.VideoFile // videos already played, oldest first
+	Playing *models.VideoFile  // currently active entry, nil if queue is idle
+	Ahead   []models.VideoFile // upcoming videos in play order
+
+	// AheadUnshuffled preserves the original (pre-shuffle) order of Ahead
+	// so that disabling shuffle restores it exactly, instead of leaving
+	// the list in whatever order the shuffle left it in.
+	AheadUnshuffled []models.VideoFile
+
+	Shuffled bool // whether Ahead is currently a shuffled view of AheadUnshuffled
+	Loop     bool // when Ahead is exhausted, recycle Done back into Ahead
+}
+
+// New creates an empty queue.
+func New() *Queue {
+	return &Queue{}
+}
+
+// Add appends a video to the end of Ahead (and to AheadUnshuffled, so it
+// survives future shuffle/unshuffle toggles at its authored position).
+func (q *Queue) Add(v models.VideoFile) {
+	q.Ahead = append(q.Ahead, v)
+	q.AheadUnshuffled = append(q.AheadUnshuffled, v)
+}
+
+// Remove deletes the entry at the given index within Ahead.
+// Returns false if the index is out of range.
+func (q *Queue) Remove(index int) bool {
+	if index < 0 || index >= len(q.Ahead) {
+		return false
+	}
+	removed := q.Ahead[index]
+	q.Ahead = append(q.Ahead[:index], q.Ahead[index+1:]...)
+	q.removeFromUnshuffled(removed)
+	return true
+}
+
+// removeFromUnshuffled drops the first AheadUnshuffled entry matching v's
+// served path. Videos are matched by path since that's the stable
+// identity used throughout the server.
+func (q *Queue) removeFromUnshuffled(v models.VideoFile) {
+	for i, u := range q.AheadUnshuffled {
+		if u.Path == v.Path {
+			q.AheadUnshuffled = append(q.AheadUnshuffled[:i], q.AheadUnshuffled[i+1:]...)
+			return
+		}
+	}
+}
+
+// Jump moves playback to the entry at the given index, where index is
+// relative to a single combined timeline: negative indices reach
+// backward into Done (-1 is the most recently played entry), and
+// non-negative indices select from Ahead (0 is the next entry).
+// The entries skipped over are moved into Done or Ahead as appropriate.
+// Returns false if index is out of range of the combined timeline.
+func (q *Queue) Jump(index int) bool {
+	if index < 0 {
+		// Negative index: count back into Done from its end.
+		pos := len(q.Done) + index // e.g. index=-1 -> len(Done)-1
+		if pos < 0 || pos >= len(q.Done) {
+			return false
+		}
+		target := q.Done[pos]
+
+		// Everything after target in Done, plus the current Playing
+		// entry, moves to the front of Ahead (in original order).
+		tail := append([]models.VideoFile{}, q.Done[pos+1:]...)
+		if q.Playing != nil {
+			tail = append(tail, *q.Playing)
+		}
+		q.Ahead = append(tail, q.Ahead...)
+
+		q.Done = q.Done[:pos]
+		p := target
+		q.Playing = &p
+		return true
+	}
+
+	if index >= len(q.Ahead) {
+		return false
+	}
+
+	// Everything before the target in Ahead, plus the current Playing
+	// entry, moves into Done (in play order).
+	if q.Playing != nil {
+		q.Done = append(q.Done, *q.Playing)
+	}
+	q.Done = append(q.Done, q.Ahead[:index]...)
+
+	target := q.Ahead[index]
+	q.Ahead = q.Ahead[index+1:]
+	p := target
+	q.Playing = &p
+	return true
+}
+
+// Next advances the queue: the current Playing entry (if any) moves to
+// Done, and the head of Ahead becomes the new Playing entry. If Ahead is
+// empty and Loop is enabled, Done is recycled back into Ahead (oldest
+// first) and playback continues from there. Returns the new Playing
+// entry, or false if there is nothing left to play.
+func (q *Queue) Next() (models.VideoFile, bool) {
+	if q.Playing != nil {
+		q.Done = append(q.Done, *q.Playing)
+		q.Playing = nil
+	}
+
+	if len(q.Ahead) == 0 {
+		if !q.Loop || len(q.Done) == 0 {
+			return models.VideoFile{}, false
+		}
+		q.Ahead = q.Done
+		q.Done = nil
+	}
+
+	next := q.Ahead[0]
+	q.Ahead = q.Ahead[1:]
+	q.Playing = &next
+	return next, true
+}
+
+// SetLoop enables or disables loop mode.
+func (q *Queue) SetLoop(loop bool) {
+	q.Loop = loop
+}
+
+// SetShuffle toggles shuffle mode for Ahead. Enabling shuffle randomises
+// Ahead (keeping AheadUnshuffled as the source of truth); disabling it
+// restores Ahead to AheadUnshuffled's order, filtered down to whatever
+// entries haven't played yet.
+func (q *Queue) SetShuffle(shuffle bool, rng func(n int) int) {
+	if shuffle == q.Shuffled {
+		return
+	}
+	q.Shuffled = shuffle
+
+	if !shuffle {
+		q.Ahead = remainingInOrder(q.AheadUnshuffled, q.Ahead)
+		return
+	}
+
+	shuffledCopy := append([]models.VideoFile{}, q.Ahead...)
+	for i := len(shuffledCopy) - 1; i > 0; i-- {
+		j := rng(i + 1)
+		shuffledCopy[i], shuffledCopy[j] = shuffledCopy[j], shuffledCopy[i]
+	}
+	q.Ahead = shuffledCopy
+}
+
+// remainingInOrder returns the subset of original whose paths are still
+// present in current, in original's order. Used to restore original
+// ordering after unshuffling without reintroducing already-played or
+// removed entries.
+func remainingInOrder(original, current []models.VideoFile) []models.VideoFile {
+	present := make(map[string]bool, len(current))
+	for _, v := range current {
+		present[v.Path] = true
+	}
+	result := make([]models.VideoFile, 0, len(current))
+	for _, v := range original {
+		if present[v.Path] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Clear empties the queue entirely, including history.
+func (q *Queue) Clear() {
+	*q = Queue{}
+}
@@ -0,0 +1,25 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+)
+
+// AcoustIDProvider would match audio fingerprints against the AcoustID
+// database, but fingerprinting needs Chromaprint, and nothing in this
+// server generates one — internal/bpm deliberately avoids ffmpeg/cgo, and
+// no other package decodes audio outside the BPM pipeline's own scope.
+// Kept as a Provider (rather than omitted) so a future fingerprint step
+// can be dropped in here without touching Search's caller.
+type AcoustIDProvider struct{}
+
+// NewAcoustIDProvider returns a provider that always reports it can't run.
+func NewAcoustIDProvider() *AcoustIDProvider { return &AcoustIDProvider{} }
+
+func (p *AcoustIDProvider) Name() string { return "acoustid" }
+
+// Search always returns an error: AcoustID matches by audio fingerprint,
+// not by text query, and this server has no fingerprinter to produce one.
+func (p *AcoustIDProvider) Search(ctx context.Context, query string) ([]Candidate, error) {
+	return nil, errors.New("reconcile: acoustid requires a chromaprint fingerprint, which this server doesn't generate")
+}
@@ -0,0 +1,85 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DiscogsProvider queries the Discogs database search API. Discogs
+// requires an API token for anything beyond trivial rate limits, so this
+// provider is a no-op until the operator sets one via the "discogs_token"
+// config key.
+type DiscogsProvider struct {
+	client *http.Client
+	token  string
+}
+
+// NewDiscogsProvider creates a DiscogsProvider. token may be empty, in
+// which case Search always errors rather than making unauthenticated
+// requests that Discogs would throttle into uselessness.
+func NewDiscogsProvider(token string) *DiscogsProvider {
+	return &DiscogsProvider{client: &http.Client{Timeout: 5 * time.Second}, token: token}
+}
+
+func (p *DiscogsProvider) Name() string { return "discogs" }
+
+type discogsSearchResponse struct {
+	Results []discogsResult `json:"results"`
+}
+
+type discogsResult struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"` // "Artist - Album"
+	Type  string `json:"type"`  // "release", "master"
+	Year  string `json:"year"`
+	URI   string `json:"uri"`
+}
+
+// Search queries Discogs's database search endpoint. Returns an error
+// immediately if no token is configured.
+func (p *DiscogsProvider) Search(ctx context.Context, query string) ([]Candidate, error) {
+	if p.token == "" {
+		return nil, errors.New("reconcile: discogs_token not configured")
+	}
+
+	reqURL := "https://api.discogs.com/database/search?type=release&q=" + url.QueryEscape(query) + "&token=" + url.QueryEscape(p.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "vdj-video-sync/1.0 (+https://github.com/jota2rz/vdj-video-sync)")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: discogs request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reconcile: discogs status %d", resp.StatusCode)
+	}
+
+	var parsed discogsSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("reconcile: discogs decode: %w", err)
+	}
+
+	candidates := make([]Candidate, 0, len(parsed.Results))
+	for _, r := range parsed.Results {
+		year, _ := strconv.Atoi(r.Year)
+		candidates = append(candidates, Candidate{
+			ID:    strconv.Itoa(r.ID),
+			Name:  r.Title,
+			Type:  r.Type,
+			URI:   r.URI,
+			Album: r.Title,
+			Year:  year,
+		})
+	}
+	return candidates, nil
+}
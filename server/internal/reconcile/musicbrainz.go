@@ -0,0 +1,109 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MusicBrainzProvider queries the public MusicBrainz recording search API.
+type MusicBrainzProvider struct {
+	client *http.Client
+}
+
+// NewMusicBrainzProvider creates a MusicBrainzProvider using a client with
+// a short timeout — this runs inline in an operator-facing HTTP request,
+// not a background job, so it must fail fast rather than hang the request.
+func NewMusicBrainzProvider() *MusicBrainzProvider {
+	return &MusicBrainzProvider{client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+func (p *MusicBrainzProvider) Name() string { return "musicbrainz" }
+
+type mbSearchResponse struct {
+	Recordings []mbRecording `json:"recordings"`
+}
+
+type mbRecording struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Disambiguate string `json:"disambiguation"`
+	ArtistCredit []struct {
+		Name string `json:"name"`
+	} `json:"artist-credit"`
+	Releases []struct {
+		Title string `json:"title"`
+		Date  string `json:"date"`
+	} `json:"releases"`
+}
+
+// Search queries MusicBrainz's recording search endpoint and returns one
+// Candidate per result, with artist/album/year detail filled in from the
+// first associated release (if any) so Accept has something to persist
+// without a second round-trip.
+func (p *MusicBrainzProvider) Search(ctx context.Context, query string) ([]Candidate, error) {
+	reqURL := "https://musicbrainz.org/ws/2/recording?fmt=json&limit=10&query=" + url.QueryEscape(query)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	// MusicBrainz requires a descriptive User-Agent on every request.
+	req.Header.Set("User-Agent", "vdj-video-sync/1.0 (+https://github.com/jota2rz/vdj-video-sync)")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: musicbrainz request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reconcile: musicbrainz status %d", resp.StatusCode)
+	}
+
+	var parsed mbSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("reconcile: musicbrainz decode: %w", err)
+	}
+
+	candidates := make([]Candidate, 0, len(parsed.Recordings))
+	for _, rec := range parsed.Recordings {
+		artist := ""
+		if len(rec.ArtistCredit) > 0 {
+			artist = rec.ArtistCredit[0].Name
+		}
+		name := rec.Title
+		if artist != "" {
+			name = artist + " - " + rec.Title
+		}
+
+		c := Candidate{
+			ID:     rec.ID,
+			Name:   name,
+			Type:   "recording",
+			URI:    "https://musicbrainz.org/recording/" + rec.ID,
+			Artist: artist,
+		}
+		if len(rec.Releases) > 0 {
+			c.Album = rec.Releases[0].Title
+			c.Year = parseYear(rec.Releases[0].Date)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// parseYear extracts the leading YYYY from a MusicBrainz date string such
+// as "2014-03-17" or "2014". Returns 0 if it doesn't parse.
+func parseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	y, err := strconv.Atoi(date[:4])
+	if err != nil {
+		return 0
+	}
+	return y
+}
@@ -0,0 +1,187 @@
+// Package reconcile enriches a video's filename/title/artist with
+// authoritative track metadata from external music databases — album,
+// year, and musical key beyond what VDJ's own get_title/get_artist verbs
+// report — the same "reconciliation" idea used by library-metadata
+// services: normalize a noisy query, fetch candidates from one or more
+// providers, fuzzy-score them against the query, and let the operator
+// accept one to lock it in.
+//
+// MusicBrainz is the only provider implemented against a live API;
+// AcoustID (see acoustid.go) and Discogs (see discogs.go) are wired in as
+// Providers but can't do useful work in this server today — AcoustID
+// needs a chromaprint fingerprint of the audio, which nothing in this
+// pure-Go pipeline (see internal/bpm's package doc) produces, and Discogs
+// needs an API token the operator hasn't configured. Both report ok=false
+// rather than silently returning nothing indistinguishable from "no
+// matches".
+package reconcile
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Candidate is a single ranked external-metadata match.
+type Candidate struct {
+	ID    string  `json:"id"`
+	Name  string  `json:"name"`
+	Score float64 `json:"score"` // 0-1, fuzzy-match confidence against the query
+	Type  string  `json:"type"`  // provider-defined, e.g. "recording", "release"
+	URI   string  `json:"uri"`
+
+	// Detail fields available once a candidate is fetched in full, not
+	// part of the ranked search response but carried through to Accept.
+	Artist string `json:"artist,omitempty"`
+	Album  string `json:"album,omitempty"`
+	Year   int    `json:"year,omitempty"`
+	Key    string `json:"key,omitempty"` // Camelot notation, if the provider reports one
+}
+
+// Provider looks up candidate matches for a normalized query string.
+type Provider interface {
+	Name() string
+	Search(ctx context.Context, query string) ([]Candidate, error)
+}
+
+// noiseTokens are quality/codec/platform tags that show up in ripped
+// filenames but carry no identifying information, so they're stripped
+// before fuzzy matching.
+var noiseTokens = []string{
+	"1080p", "720p", "480p", "4k", "hd", "hq",
+	"official video", "official music video", "official audio", "official",
+	"lyrics", "lyric video", "music video", "mv",
+	"audio", "video", "clip", "remastered",
+}
+
+var nonWordRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Normalize lowercases a filename/title/artist query, strips noise
+// tokens and file extensions, and collapses punctuation to single
+// spaces, so "Artist - Song (Official Video) [1080p].mp4" reconciles
+// against the same provider results as "Artist - Song".
+func Normalize(s string) string {
+	s = strings.ToLower(s)
+	if i := strings.LastIndex(s, "."); i > 0 && len(s)-i <= 5 {
+		s = s[:i] // strip file extension
+	}
+	s = nonWordRe.ReplaceAllString(s, " ")
+	for _, tok := range noiseTokens {
+		s = strings.ReplaceAll(s, tok, " ")
+	}
+	fields := strings.Fields(s)
+	return strings.Join(fields, " ")
+}
+
+// levenshtein computes edit distance between two strings (rune-wise).
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			del := prev[j] + 1
+			ins := curr[j-1] + 1
+			sub := prev[j-1] + cost
+			m := del
+			if ins < m {
+				m = ins
+			}
+			if sub < m {
+				m = sub
+			}
+			curr[j] = m
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// fuzzyScore combines token-overlap (Jaccard over word sets) with
+// normalized Levenshtein distance into a single 0-1 confidence that
+// candidate matches query. Token overlap rewards matches that reorder
+// words ("Song - Artist" vs "Artist - Song"); Levenshtein catches close
+// spelling variants token overlap alone would miss.
+func fuzzyScore(query, candidate string) float64 {
+	query = Normalize(query)
+	candidate = Normalize(candidate)
+	if query == "" || candidate == "" {
+		return 0
+	}
+
+	qTokens := strings.Fields(query)
+	cTokens := strings.Fields(candidate)
+	overlap := tokenOverlap(qTokens, cTokens)
+
+	dist := levenshtein(query, candidate)
+	maxLen := len(query)
+	if len(candidate) > maxLen {
+		maxLen = len(candidate)
+	}
+	editScore := 1.0
+	if maxLen > 0 {
+		editScore = 1.0 - float64(dist)/float64(maxLen)
+	}
+	if editScore < 0 {
+		editScore = 0
+	}
+
+	return 0.6*overlap + 0.4*editScore
+}
+
+// tokenOverlap is the Jaccard index between two token sets.
+func tokenOverlap(a, b []string) float64 {
+	set := make(map[string]bool, len(a))
+	for _, t := range a {
+		set[t] = true
+	}
+	union := len(set)
+	var intersect int
+	seen := make(map[string]bool, len(b))
+	for _, t := range b {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		if set[t] {
+			intersect++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(intersect) / float64(union)
+}
+
+// Search normalizes query, fetches candidates from every provider, rescores
+// each against query with fuzzyScore (overriding whatever ranking the
+// provider itself returned, so results from different providers are
+// comparable), and returns them sorted by score descending. A provider
+// erroring or returning nothing just contributes no candidates — it
+// doesn't fail the whole search.
+func Search(ctx context.Context, providers []Provider, query string) []Candidate {
+	var all []Candidate
+	for _, p := range providers {
+		results, err := p.Search(ctx, query)
+		if err != nil {
+			continue
+		}
+		for _, c := range results {
+			c.Score = fuzzyScore(query, c.Name)
+			all = append(all, c)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Score > all[j].Score })
+	return all
+}
@@ -0,0 +1,57 @@
+package reconcile
+
+import "database/sql"
+
+// Metadata is the reconciled track data accepted for one video file,
+// persisted keyed by path+modTime (mirroring bpm.Cache's schema pattern)
+// so a re-encode or replace invalidates the stored match.
+type Metadata struct {
+	Name   string  `json:"name"`
+	Artist string  `json:"artist"`
+	Album  string  `json:"album"`
+	Year   int     `json:"year"`
+	Key    string  `json:"key"`
+	Score  float64 `json:"score"`
+	URI    string  `json:"uri"`
+}
+
+// Store persists accepted reconciliation matches in SQLite.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a reconciliation store backed by the given database.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Get retrieves the accepted metadata for the given file path and
+// modification time. Returns a zero Metadata, false if nothing has been
+// accepted or if the file has been modified since.
+func (s *Store) Get(path string, modTime int64) (Metadata, bool) {
+	var m Metadata
+	err := s.db.QueryRow(
+		`SELECT name, artist, album, year, key, score, uri FROM video_metadata WHERE path = ? AND mod_time = ?`,
+		path, modTime,
+	).Scan(&m.Name, &m.Artist, &m.Album, &m.Year, &m.Key, &m.Score, &m.URI)
+	if err != nil {
+		return Metadata{}, false
+	}
+	return m, true
+}
+
+// Accept stores the chosen candidate as the authoritative metadata for
+// the given file path and modification time, replacing any previously
+// accepted candidate.
+func (s *Store) Accept(path string, modTime int64, m Metadata) error {
+	_, err := s.db.Exec(
+		`INSERT INTO video_metadata (path, mod_time, name, artist, album, year, key, score, uri)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET
+		   mod_time = excluded.mod_time, name = excluded.name, artist = excluded.artist,
+		   album = excluded.album, year = excluded.year, key = excluded.key,
+		   score = excluded.score, uri = excluded.uri`,
+		path, modTime, m.Name, m.Artist, m.Album, m.Year, m.Key, m.Score, m.URI,
+	)
+	return err
+}
@@ -0,0 +1,164 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// AMF0 type markers used by the connect/createStream/publish handshake.
+// Only the subset those commands actually use is implemented — AMF0 also
+// has ECMA arrays, dates, references, etc. that this package never needs
+// to produce or consume.
+const (
+	amf0Number    = 0x00
+	amf0Boolean   = 0x01
+	amf0String    = 0x02
+	amf0Object    = 0x03
+	amf0Null      = 0x05
+	amf0Undefined = 0x06
+	amf0ObjectEnd = 0x09
+)
+
+// decodeAMF0All decodes a sequence of concatenated AMF0 values (the
+// shape of an RTMP command message's payload: command name, transaction
+// ID, then zero or more argument values) and returns them in order.
+func decodeAMF0All(b []byte) ([]any, error) {
+	var values []any
+	for len(b) > 0 {
+		v, rest, err := decodeAMF0(b)
+		if err != nil {
+			return values, err
+		}
+		values = append(values, v)
+		b = rest
+	}
+	return values, nil
+}
+
+// decodeAMF0 decodes a single AMF0 value from the front of b, returning
+// the decoded value and the remaining bytes.
+func decodeAMF0(b []byte) (value any, rest []byte, err error) {
+	if len(b) < 1 {
+		return nil, nil, fmt.Errorf("amf0: empty input")
+	}
+	marker := b[0]
+	b = b[1:]
+
+	switch marker {
+	case amf0Number:
+		if len(b) < 8 {
+			return nil, nil, fmt.Errorf("amf0: truncated number")
+		}
+		bits := binary.BigEndian.Uint64(b[:8])
+		return math.Float64frombits(bits), b[8:], nil
+
+	case amf0Boolean:
+		if len(b) < 1 {
+			return nil, nil, fmt.Errorf("amf0: truncated boolean")
+		}
+		return b[0] != 0, b[1:], nil
+
+	case amf0String:
+		s, rest, err := decodeAMF0ShortString(b)
+		return s, rest, err
+
+	case amf0Null, amf0Undefined:
+		return nil, b, nil
+
+	case amf0Object:
+		obj := make(map[string]any)
+		for {
+			if len(b) >= 3 && b[0] == 0 && b[1] == 0 && b[2] == amf0ObjectEnd {
+				return obj, b[3:], nil
+			}
+			key, rest, err := decodeAMF0ShortString(b)
+			if err != nil {
+				return obj, rest, err
+			}
+			val, rest2, err := decodeAMF0(rest)
+			if err != nil {
+				return obj, rest2, err
+			}
+			obj[key] = val
+			b = rest2
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("amf0: unsupported type marker 0x%02x", marker)
+	}
+}
+
+// decodeAMF0ShortString reads an AMF0 "short string" (2-byte big-endian
+// length prefix, no type marker — used both as a top-level string value
+// and as an object's property keys).
+func decodeAMF0ShortString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, fmt.Errorf("amf0: truncated string length")
+	}
+	n := int(binary.BigEndian.Uint16(b[:2]))
+	b = b[2:]
+	if len(b) < n {
+		return "", nil, fmt.Errorf("amf0: truncated string body")
+	}
+	return string(b[:n]), b[n:], nil
+}
+
+// amf0Writer incrementally builds an AMF0-encoded command payload.
+type amf0Writer struct {
+	buf []byte
+}
+
+func (w *amf0Writer) writeNumber(n float64) {
+	w.buf = append(w.buf, amf0Number)
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(n))
+	w.buf = append(w.buf, b[:]...)
+}
+
+func (w *amf0Writer) writeString(s string) {
+	w.buf = append(w.buf, amf0String)
+	var lenBuf [2]byte
+	binary.BigEndian.PutUint16(lenBuf[:], uint16(len(s)))
+	w.buf = append(w.buf, lenBuf[:]...)
+	w.buf = append(w.buf, s...)
+}
+
+func (w *amf0Writer) writeNull() {
+	w.buf = append(w.buf, amf0Null)
+}
+
+// writeObject writes an AMF0 object from key/value string pairs, in the
+// order given (order matters to some picky RTMP clients, so callers pass
+// an ordered slice rather than a map).
+func (w *amf0Writer) writeObject(fields []amf0Field) {
+	w.buf = append(w.buf, amf0Object)
+	for _, f := range fields {
+		var lenBuf [2]byte
+		binary.BigEndian.PutUint16(lenBuf[:], uint16(len(f.key)))
+		w.buf = append(w.buf, lenBuf[:]...)
+		w.buf = append(w.buf, f.key...)
+		switch v := f.value.(type) {
+		case string:
+			w.writeString(v)
+		case float64:
+			w.writeNumber(v)
+		case bool:
+			w.buf = append(w.buf, amf0Boolean)
+			if v {
+				w.buf = append(w.buf, 1)
+			} else {
+				w.buf = append(w.buf, 0)
+			}
+		default:
+			w.writeNull()
+		}
+	}
+	w.buf = append(w.buf, 0, 0, amf0ObjectEnd)
+}
+
+// amf0Field is one property of an AMF0 object written by writeObject.
+type amf0Field struct {
+	key   string
+	value any
+}
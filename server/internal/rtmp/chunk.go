@@ -0,0 +1,282 @@
+package rtmp
+
+import (
+	"fmt"
+	"io"
+)
+
+// defaultChunkSize is the RTMP spec's starting chunk size, in effect
+// until either side sends a "Set Chunk Size" control message.
+const defaultChunkSize = 128
+
+// writeChunkSize is the chunk size we ask the peer to use for data it
+// sends *us* once handshake completes (sent as our own "Set Chunk Size"
+// message), and the size we split our own outgoing messages into.
+// Comfortably above a single audio/video tag in the common case, so
+// most messages need only one chunk.
+const writeChunkSize = 60000
+
+// Message is one fully reassembled RTMP message — a command, control
+// message, or audio/video payload — independent of how many chunks it
+// arrived split across.
+type Message struct {
+	TypeID    byte
+	StreamID  uint32
+	Timestamp uint32
+	Payload   []byte
+}
+
+// RTMP message type IDs this package acts on.
+const (
+	msgTypeSetChunkSize     = 1
+	msgTypeAck              = 3
+	msgTypeUserControl      = 4
+	msgTypeWindowAckSize    = 5
+	msgTypeSetPeerBandwidth = 6
+	msgTypeAudio            = 8
+	msgTypeVideo            = 9
+	msgTypeAMF0Data         = 18
+	msgTypeAMF0Command      = 20
+)
+
+// chunkStreamState tracks the in-progress message on one chunk stream
+// ID, since RTMP interleaves multiple logical messages (e.g. audio and
+// video) across the same TCP connection by splitting each into chunks.
+type chunkStreamState struct {
+	typeID    byte
+	streamID  uint32
+	timestamp uint32 // absolute timestamp of the message in progress
+	length    uint32 // total message length, from the last type 0/1 header seen
+	buf       []byte // bytes of the current message accumulated so far
+	extended  bool   // current message's timestamp (delta) used the extended-timestamp field
+	lastDelta uint32 // most recent type-1/2 timestamp delta seen on this chunk stream
+}
+
+// chunkReader reassembles RTMP chunks from a byte stream into complete
+// Messages, tracking per-chunk-stream state and the negotiated chunk
+// size (raised from defaultChunkSize by a peer's "Set Chunk Size"
+// message, handled transparently here since it has to take effect before
+// the next chunk is parsed).
+type chunkReader struct {
+	r         io.Reader
+	chunkSize uint32
+	states    map[uint32]*chunkStreamState
+}
+
+func newChunkReader(r io.Reader) *chunkReader {
+	return &chunkReader{r: r, chunkSize: defaultChunkSize, states: make(map[uint32]*chunkStreamState)}
+}
+
+// ReadMessage reads chunks until one full message is assembled. "Set
+// Chunk Size" messages update cr.chunkSize and are also returned, so
+// callers that care can log them; callers that don't can just ignore
+// that TypeID.
+func (cr *chunkReader) ReadMessage() (Message, error) {
+	for {
+		fmtByte, csid, err := readBasicHeader(cr.r)
+		if err != nil {
+			return Message{}, err
+		}
+
+		st, ok := cr.states[csid]
+		if !ok {
+			st = &chunkStreamState{}
+			cr.states[csid] = st
+		}
+
+		var tsOrDelta uint32
+		switch fmtByte {
+		case 0:
+			var hdr [11]byte
+			if _, err := io.ReadFull(cr.r, hdr[:]); err != nil {
+				return Message{}, fmt.Errorf("rtmp: read type-0 header: %w", err)
+			}
+			tsOrDelta = be24(hdr[0:3])
+			st.length = be24(hdr[3:6])
+			st.typeID = hdr[6]
+			st.streamID = le32(hdr[7:11])
+			st.timestamp = 0
+			st.buf = st.buf[:0]
+		case 1:
+			var hdr [7]byte
+			if _, err := io.ReadFull(cr.r, hdr[:]); err != nil {
+				return Message{}, fmt.Errorf("rtmp: read type-1 header: %w", err)
+			}
+			tsOrDelta = be24(hdr[0:3])
+			st.length = be24(hdr[3:6])
+			st.typeID = hdr[6]
+			st.buf = st.buf[:0]
+		case 2:
+			var hdr [3]byte
+			if _, err := io.ReadFull(cr.r, hdr[:]); err != nil {
+				return Message{}, fmt.Errorf("rtmp: read type-2 header: %w", err)
+			}
+			tsOrDelta = be24(hdr[:])
+			st.buf = st.buf[:0]
+		case 3:
+			// No header fields: continuation of the in-progress message,
+			// or (when buf is empty) a repeat of the previous chunk's
+			// timestamp delta with no new header at all. Per spec, if the
+			// message this continues used an extended timestamp, every
+			// type-3 chunk of it repeats that same 4-byte field too — it
+			// has to be consumed here or the chunk-stream byte offset
+			// desyncs for the rest of the connection.
+			if st.extended {
+				var ext [4]byte
+				if _, err := io.ReadFull(cr.r, ext[:]); err != nil {
+					return Message{}, fmt.Errorf("rtmp: read type-3 extended timestamp: %w", err)
+				}
+			}
+		default:
+			return Message{}, fmt.Errorf("rtmp: invalid chunk fmt %d", fmtByte)
+		}
+
+		if fmtByte <= 2 {
+			st.extended = tsOrDelta == 0xFFFFFF
+			if st.extended {
+				var ext [4]byte
+				if _, err := io.ReadFull(cr.r, ext[:]); err != nil {
+					return Message{}, fmt.Errorf("rtmp: read extended timestamp: %w", err)
+				}
+				tsOrDelta = be32(ext[:])
+			}
+			if fmtByte == 0 {
+				st.timestamp = tsOrDelta
+			} else {
+				st.timestamp += tsOrDelta
+				st.lastDelta = tsOrDelta
+			}
+		} else if len(st.buf) == 0 {
+			// Type 3 with no header at all starting a *new* message (as
+			// opposed to continuing one already in progress) means "same
+			// delta as last time" per spec — real encoders rely on this
+			// for constant-rate audio/CFR video. Without reapplying
+			// lastDelta here, st.timestamp would just sit at the previous
+			// message's value forever.
+			st.timestamp += st.lastDelta
+		}
+
+		remaining := st.length - uint32(len(st.buf))
+		toRead := remaining
+		if toRead > cr.chunkSize {
+			toRead = cr.chunkSize
+		}
+		chunk := make([]byte, toRead)
+		if _, err := io.ReadFull(cr.r, chunk); err != nil {
+			return Message{}, fmt.Errorf("rtmp: read chunk payload: %w", err)
+		}
+		st.buf = append(st.buf, chunk...)
+
+		if uint32(len(st.buf)) < st.length {
+			continue // message spans more chunks
+		}
+
+		msg := Message{TypeID: st.typeID, StreamID: st.streamID, Timestamp: st.timestamp, Payload: st.buf}
+		st.buf = nil
+
+		if msg.TypeID == msgTypeSetChunkSize {
+			if len(msg.Payload) < 4 {
+				return Message{}, fmt.Errorf("rtmp: short Set Chunk Size message")
+			}
+			cr.chunkSize = be32(msg.Payload[:4])
+		}
+
+		return msg, nil
+	}
+}
+
+// readBasicHeader reads an RTMP chunk basic header (1-3 bytes) and
+// returns the chunk type (fmt, 0-3) and chunk stream ID.
+func readBasicHeader(r io.Reader) (fmtByte byte, csid uint32, err error) {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, 0, err
+	}
+	fmtByte = b[0] >> 6
+	id := uint32(b[0] & 0x3F)
+
+	switch id {
+	case 0: // 2-byte form: csid = next byte + 64
+		var ext [1]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, 0, err
+		}
+		return fmtByte, uint32(ext[0]) + 64, nil
+	case 1: // 3-byte form: csid = next two bytes (LE) + 64
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, 0, err
+		}
+		return fmtByte, uint32(ext[0]) + uint32(ext[1])*256 + 64, nil
+	default:
+		return fmtByte, id, nil
+	}
+}
+
+// chunkWriter writes complete RTMP messages to a peer as one or more
+// chunks, splitting at writeChunkSize.
+type chunkWriter struct {
+	w io.Writer
+}
+
+func newChunkWriter(w io.Writer) *chunkWriter {
+	return &chunkWriter{w: w}
+}
+
+// WriteMessage sends one RTMP message on csid, splitting into
+// writeChunkSize-sized chunks (a type-0 header chunk, then type-3
+// continuation chunks) if it doesn't fit in one.
+func (cw *chunkWriter) WriteMessage(csid uint32, typeID byte, streamID uint32, payload []byte) error {
+	var hdr [12]byte // 1-byte basic header (fmt 0, csid < 64) + 11-byte message header
+	hdr[0] = byte(csid & 0x3F)
+	putBE24(hdr[1:4], 0) // timestamp
+	putBE24(hdr[4:7], uint32(len(payload)))
+	hdr[7] = typeID
+	putLE32(hdr[8:12], streamID)
+	if _, err := cw.w.Write(hdr[:]); err != nil {
+		return fmt.Errorf("rtmp: write message header: %w", err)
+	}
+
+	for len(payload) > 0 {
+		n := len(payload)
+		if n > writeChunkSize {
+			n = writeChunkSize
+		}
+		if _, err := cw.w.Write(payload[:n]); err != nil {
+			return fmt.Errorf("rtmp: write chunk payload: %w", err)
+		}
+		payload = payload[n:]
+		if len(payload) > 0 {
+			// Type-3 continuation: basic header only, fmt=3.
+			if _, err := cw.w.Write([]byte{0xC0 | byte(csid&0x3F)}); err != nil {
+				return fmt.Errorf("rtmp: write continuation header: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func be24(b []byte) uint32 {
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+func be32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+func putBE24(b []byte, v uint32) {
+	b[0] = byte(v >> 16)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v)
+}
+
+func putLE32(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+	b[3] = byte(v >> 24)
+}
@@ -0,0 +1,81 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// flvVersion is the FLV file header's version byte per the FLV spec —
+// unrelated to handshakeVersion, which is RTMP's own protocol version.
+const flvVersion = 1
+
+// FLVMuxer re-wraps the audio/video tag bodies this package already
+// receives (an RTMP message's payload IS an FLV tag body — RTMP reuses
+// FLV's tag framing for its audio/video message types) into a standard
+// FLV byte stream, so the repackaged video can be handed to ffmpeg
+// ("-f flv -i pipe:0") the same way internal/hls and internal/stream
+// already shell out to ffmpeg for everything container/codec related.
+type FLVMuxer struct {
+	w           io.Writer
+	wroteHeader bool
+	hasVideo    bool
+	hasAudio    bool
+}
+
+// NewFLVMuxer creates a muxer that writes an FLV stream to w. hasVideo
+// and hasAudio set the FLV file header's stream-presence flags.
+func NewFLVMuxer(w io.Writer, hasVideo, hasAudio bool) *FLVMuxer {
+	return &FLVMuxer{w: w, hasVideo: hasVideo, hasAudio: hasAudio}
+}
+
+// writeHeader emits the 9-byte FLV file header plus the initial
+// PreviousTagSize0, once.
+func (m *FLVMuxer) writeHeader() error {
+	if m.wroteHeader {
+		return nil
+	}
+	m.wroteHeader = true
+
+	flags := byte(0)
+	if m.hasVideo {
+		flags |= 0x01
+	}
+	if m.hasAudio {
+		flags |= 0x04
+	}
+
+	header := []byte{'F', 'L', 'V', flvVersion, flags, 0, 0, 0, 9}
+	if _, err := m.w.Write(header); err != nil {
+		return err
+	}
+	var prevTagSize [4]byte // PreviousTagSize0 = 0
+	_, err := m.w.Write(prevTagSize[:])
+	return err
+}
+
+// WriteTag appends one FLV tag (audio = 8, video = 9) carrying body at
+// timestamp (ms), followed by its PreviousTagSize field.
+func (m *FLVMuxer) WriteTag(tagType byte, timestamp uint32, body []byte) error {
+	if err := m.writeHeader(); err != nil {
+		return err
+	}
+
+	var hdr [11]byte
+	hdr[0] = tagType
+	putBE24(hdr[1:4], uint32(len(body)))
+	putBE24(hdr[4:7], timestamp&0xFFFFFF)
+	hdr[7] = byte(timestamp >> 24) // TimestampExtended
+	// hdr[8:11] StreamID is always 0.
+
+	if _, err := m.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := m.w.Write(body); err != nil {
+		return err
+	}
+
+	var prevTagSize [4]byte
+	binary.BigEndian.PutUint32(prevTagSize[:], uint32(len(hdr)+len(body)))
+	_, err := m.w.Write(prevTagSize[:])
+	return err
+}
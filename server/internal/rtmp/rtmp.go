@@ -0,0 +1,269 @@
+// Package rtmp implements a minimal RTMP ingest listener so a live
+// encoder (VirtualDJ's built-in broadcaster, OBS, a hardware mixer) can
+// publish straight into this server instead of it only ever polling
+// VDJ's plugin API. It speaks just enough of the protocol to accept one
+// "publish" stream and demux its FLV-framed audio/video tags —
+// handshake (simple, non-digest — see handshake.go), chunk
+// stream reassembly (chunk.go), and the connect/createStream/publish
+// AMF0 command sequence (amf0.go) every RTMP client sends before
+// publishing. It does not implement RTMP *playback* (play/seek/pause),
+// AMF3, or the digest handshake: this is an ingest-only listener, and
+// internal/stream already covers serving the resulting mix back out
+// over HTTP-FLV.
+//
+// Video tags are handed to the Handler as-is (still AVC-in-FLV framed)
+// for repackaging into fMP4 by internal/hls; audio tags are decoded to
+// PCM by internal/bpm's LiveDecoder and fed to its StreamDetector so a
+// live-mixed set gets live BPM updates the same way a library file does
+// after an offline analysis pass.
+package rtmp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// Handler receives demuxed events from a publishing RTMP client. Only
+// one publish is accepted at a time (see Server.ListenAndServe), so a
+// Handler never needs to distinguish between concurrent publishers.
+type Handler interface {
+	// OnPublishStart is called once a client has completed the
+	// connect/createStream/publish command sequence.
+	OnPublishStart()
+	// OnPublishStop is called when the publishing connection closes,
+	// cleanly or not. Always called exactly once per OnPublishStart.
+	OnPublishStop()
+	// OnVideoTag delivers one FLV video tag body (frame type/codec byte
+	// included), at its RTMP timestamp in milliseconds.
+	OnVideoTag(timestampMs uint32, payload []byte)
+	// OnAudioTag delivers one FLV audio tag body (sound format byte
+	// included), at its RTMP timestamp in milliseconds.
+	OnAudioTag(timestampMs uint32, payload []byte)
+}
+
+// Server listens for RTMP publish connections on a TCP address.
+type Server struct {
+	addr    string
+	handler Handler
+
+	mu         sync.Mutex
+	publishing bool
+}
+
+// NewServer creates a Server bound to addr (e.g. ":1935") once
+// ListenAndServe is called. Every demuxed event is delivered to handler.
+func NewServer(addr string, handler Handler) *Server {
+	return &Server{addr: addr, handler: handler}
+}
+
+// ListenAndServe listens on the server's address and serves connections
+// until ctx is canceled. Only one publisher is accepted at a time —
+// this mirrors the single active "deck" this ingest path feeds; a second
+// connection attempting to publish while one is already active is
+// rejected immediately.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("rtmp: listen: %w", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	slog.Info("rtmp listening", "addr", s.addr)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("rtmp: accept: %w", err)
+		}
+
+		s.mu.Lock()
+		busy := s.publishing
+		if !busy {
+			s.publishing = true
+		}
+		s.mu.Unlock()
+
+		if busy {
+			slog.Warn("rtmp: rejecting publisher, one is already active", "remote", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	defer func() {
+		s.mu.Lock()
+		s.publishing = false
+		s.mu.Unlock()
+	}()
+
+	remote := conn.RemoteAddr()
+	if err := serverHandshake(conn); err != nil {
+		slog.Warn("rtmp: handshake failed", "remote", remote, "error", err)
+		return
+	}
+
+	cr := newChunkReader(conn)
+	cw := newChunkWriter(conn)
+
+	var published bool
+	defer func() {
+		if published {
+			s.handler.OnPublishStop()
+		}
+	}()
+
+	for {
+		msg, err := cr.ReadMessage()
+		if err != nil {
+			slog.Debug("rtmp: connection ended", "remote", remote, "error", err)
+			return
+		}
+
+		switch msg.TypeID {
+		case msgTypeAMF0Command:
+			if err := s.handleCommand(cw, msg, &published); err != nil {
+				slog.Warn("rtmp: command handling failed", "remote", remote, "error", err)
+				return
+			}
+		case msgTypeVideo:
+			if published {
+				s.handler.OnVideoTag(msg.Timestamp, msg.Payload)
+			}
+		case msgTypeAudio:
+			if published {
+				s.handler.OnAudioTag(msg.Timestamp, msg.Payload)
+			}
+		default:
+			// Window ack size, set peer bandwidth, user control, AMF0
+			// data (onMetaData), and acks are all informational for an
+			// ingest-only listener — nothing to react to.
+		}
+	}
+}
+
+// handleCommand decodes and responds to one AMF0 command message. *published
+// is set once the client's "publish" command arrives.
+func (s *Server) handleCommand(cw *chunkWriter, msg Message, published *bool) error {
+	values, err := decodeAMF0All(msg.Payload)
+	if err != nil || len(values) < 2 {
+		return fmt.Errorf("rtmp: decode command: %w", err)
+	}
+	name, ok := values[0].(string)
+	if !ok {
+		return fmt.Errorf("rtmp: command name is not a string")
+	}
+	txID, _ := values[1].(float64)
+
+	switch name {
+	case "connect":
+		if err := s.replyConnect(cw, txID); err != nil {
+			return err
+		}
+	case "createStream":
+		if err := s.replyCreateStream(cw, txID); err != nil {
+			return err
+		}
+	case "publish":
+		if err := s.replyPublish(cw, txID); err != nil {
+			return err
+		}
+		if !*published {
+			*published = true
+			s.handler.OnPublishStart()
+		}
+	case "deleteStream", "closeStream", "FCUnpublish":
+		if *published {
+			*published = false
+			s.handler.OnPublishStop()
+		}
+	default:
+		// releaseStream, FCPublish, and anything else: no response
+		// needed for publish to proceed.
+	}
+	return nil
+}
+
+// protoCSID is the chunk stream ID protocol control messages (window ack
+// size, set peer bandwidth, set chunk size) and command replies are sent
+// on — csid 2 and 3 are the conventional choices real RTMP servers use.
+const (
+	protoCSID = 2
+	cmdCSID   = 3
+)
+
+func (s *Server) replyConnect(cw *chunkWriter, txID float64) error {
+	var ackSize [4]byte
+	putBE32(ackSize[:], 2500000)
+	if err := cw.WriteMessage(protoCSID, msgTypeWindowAckSize, 0, ackSize[:]); err != nil {
+		return err
+	}
+
+	bw := append(append([]byte{}, ackSize[:]...), 2) // limit type 2 = "dynamic"
+	if err := cw.WriteMessage(protoCSID, msgTypeSetPeerBandwidth, 0, bw); err != nil {
+		return err
+	}
+
+	var chunkSizeBuf [4]byte
+	putBE32(chunkSizeBuf[:], writeChunkSize)
+	if err := cw.WriteMessage(protoCSID, msgTypeSetChunkSize, 0, chunkSizeBuf[:]); err != nil {
+		return err
+	}
+
+	w := &amf0Writer{}
+	w.writeString("_result")
+	w.writeNumber(txID)
+	w.writeObject([]amf0Field{
+		{"fmsVer", "FMS/3,0,1,123"},
+		{"capabilities", float64(31)},
+	})
+	w.writeObject([]amf0Field{
+		{"level", "status"},
+		{"code", "NetConnection.Connect.Success"},
+		{"description", "Connection succeeded."},
+	})
+	return cw.WriteMessage(cmdCSID, msgTypeAMF0Command, 0, w.buf)
+}
+
+func (s *Server) replyCreateStream(cw *chunkWriter, txID float64) error {
+	w := &amf0Writer{}
+	w.writeString("_result")
+	w.writeNumber(txID)
+	w.writeNull()
+	w.writeNumber(1) // stream ID
+	return cw.WriteMessage(cmdCSID, msgTypeAMF0Command, 0, w.buf)
+}
+
+func (s *Server) replyPublish(cw *chunkWriter, txID float64) error {
+	w := &amf0Writer{}
+	w.writeString("onStatus")
+	w.writeNumber(txID)
+	w.writeNull()
+	w.writeObject([]amf0Field{
+		{"level", "status"},
+		{"code", "NetStream.Publish.Start"},
+		{"description", "Publishing live stream."},
+	})
+	return cw.WriteMessage(cmdCSID, msgTypeAMF0Command, 1, w.buf)
+}
+
+func putBE32(b []byte, v uint32) {
+	b[0] = byte(v >> 24)
+	b[1] = byte(v >> 16)
+	b[2] = byte(v >> 8)
+	b[3] = byte(v)
+}
@@ -0,0 +1,317 @@
+// Package scanner walks the video library directories, populating the
+// video_bpm cache (internal/bpm) for every file it finds. video.Matcher
+// already runs its own analysis pass over the directory it serves, but
+// only synchronously at startup/rescan — this package exists to do the
+// same work continuously in the background, report its progress via
+// GET /api/scan/status, and pick up files dropped in after startup
+// within seconds via fsnotify instead of waiting for the next restart.
+package scanner
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/jota2rz/vdj-video-sync/server/internal/bpm"
+)
+
+// videoExt is the only video container the analysis/matching pipeline
+// understands (see the unexported videoExts in internal/video/matcher.go,
+// which this mirrors — it isn't exported there to share directly).
+const videoExt = ".mp4"
+
+// DirsFunc returns the directories to scan, resolved fresh on every
+// call so a videos_dir/transition_videos_dir config change is picked up
+// by the next scan pass without a restart.
+type DirsFunc func() []string
+
+// Status reports a scan's progress for GET /api/scan/status.
+type Status struct {
+	Running   bool      `json:"running"`
+	Seen      int       `json:"seen"`     // files enqueued so far (initial walk + fsnotify)
+	Analyzed  int       `json:"analyzed"` // cache misses that ran BPM analysis
+	Cached    int       `json:"cached"`   // cache hits, skipped analysis
+	Errored   int       `json:"errored"`
+	StartedAt time.Time `json:"startedAt"`
+	ETA       time.Time `json:"eta,omitempty"`
+}
+
+// job is one file queued for processing. force skips the cache check —
+// set when the file was queued by Rescan rather than discovered fresh.
+type job struct {
+	path  string
+	force bool
+}
+
+// Scanner walks DirsFunc's directories for video files, analyses any
+// whose video_bpm cache entry is missing or stale (see internal/bpm)
+// using a worker pool, and watches those directories so newly-dropped
+// files are picked up within seconds instead of at the next restart.
+type Scanner struct {
+	cache   *bpm.Cache
+	dirs    DirsFunc
+	workers int
+	jobs    chan job
+
+	mu     sync.Mutex
+	status Status
+	queued map[string]bool // in-flight dedup so a burst of fsnotify events or an overlapping rescan can't double-queue a path
+}
+
+// New creates a Scanner. workers <= 0 defaults to runtime.NumCPU().
+func New(cache *bpm.Cache, dirs DirsFunc, workers int) *Scanner {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &Scanner{
+		cache:   cache,
+		dirs:    dirs,
+		workers: workers,
+		jobs:    make(chan job, 4096),
+		queued:  make(map[string]bool),
+		status:  Status{StartedAt: time.Now()},
+	}
+}
+
+// Run starts the worker pool and an fsnotify watcher over dirs(), then
+// performs an initial cache-aware walk of every directory. It blocks
+// until ctx is cancelled. A worker always finishes the file it's
+// currently analysing before checking ctx again, so shutdown never
+// truncates a file mid-analysis — it just stops starting new ones.
+func (s *Scanner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			defer wg.Done()
+			s.worker(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.watch(ctx, 2*time.Second)
+	}()
+
+	s.walk(false)
+
+	<-ctx.Done()
+	wg.Wait()
+}
+
+// Rescan forces every file under dirs() to be re-analysed, ignoring the
+// video_bpm cache, via a background walk — it returns once the walk has
+// been queued, not once analysis finishes (see Status for progress).
+func (s *Scanner) Rescan() {
+	go s.walk(true)
+}
+
+// Status returns a snapshot of scan progress, with Running/ETA derived
+// from the current counters rather than stored directly.
+func (s *Scanner) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.status
+	processed := st.Analyzed + st.Cached + st.Errored
+	st.Running = processed < st.Seen
+	if st.Running && processed > 0 {
+		elapsed := time.Since(st.StartedAt)
+		perItem := elapsed / time.Duration(processed)
+		st.ETA = time.Now().Add(perItem * time.Duration(st.Seen-processed))
+	}
+	return st
+}
+
+// walk recursively lists every video file under dirs() and enqueues it.
+func (s *Scanner) walk(force bool) {
+	for _, dir := range s.dirs() {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if strings.ToLower(filepath.Ext(path)) != videoExt {
+				return nil
+			}
+			s.enqueue(path, force)
+			return nil
+		})
+	}
+}
+
+// enqueue adds path to the work queue unless it's already queued. A full
+// queue drops the job rather than blocking the caller (the watcher's
+// debounce timer or an in-progress walk) — it'll be picked up again on
+// the next walk or file-change event.
+func (s *Scanner) enqueue(path string, force bool) {
+	s.mu.Lock()
+	if s.queued[path] {
+		s.mu.Unlock()
+		return
+	}
+	s.queued[path] = true
+	s.status.Seen++
+	s.mu.Unlock()
+
+	select {
+	case s.jobs <- job{path: path, force: force}:
+	default:
+		s.mu.Lock()
+		delete(s.queued, path)
+		s.status.Seen--
+		s.mu.Unlock()
+		slog.Warn("scanner: job queue full, dropping", "path", path)
+	}
+}
+
+func (s *Scanner) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-s.jobs:
+			s.process(j)
+		}
+	}
+}
+
+func (s *Scanner) process(j job) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.queued, j.path)
+		s.mu.Unlock()
+	}()
+
+	info, err := os.Stat(j.path)
+	if err != nil {
+		s.mu.Lock()
+		s.status.Errored++
+		s.mu.Unlock()
+		slog.Warn("scanner: stat failed", "path", j.path, "error", err)
+		return
+	}
+	modTime := info.ModTime().Unix()
+
+	if !j.force {
+		if _, _, _, ok := s.cache.GetTempo(j.path, modTime); ok {
+			s.mu.Lock()
+			s.status.Cached++
+			s.mu.Unlock()
+			return
+		}
+	}
+
+	result, err := bpm.AnalyseFile(j.path)
+	if err != nil {
+		s.mu.Lock()
+		s.status.Errored++
+		s.mu.Unlock()
+		slog.Warn("scanner: analysis failed", "path", j.path, "error", err)
+		return
+	}
+	if err := s.cache.SetTempo(j.path, modTime, result.BPM, result.Confidence, result.FirstBeatSec); err != nil {
+		s.mu.Lock()
+		s.status.Errored++
+		s.mu.Unlock()
+		slog.Warn("scanner: cache write failed", "path", j.path, "error", err)
+		return
+	}
+	s.mu.Lock()
+	s.status.Analyzed++
+	s.mu.Unlock()
+}
+
+// addWatches registers an fsnotify watch on dir and every subdirectory
+// beneath it, mirroring video.Matcher's own recursive watch setup.
+func addWatches(watcher *fsnotify.Watcher, dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if werr := watcher.Add(path); werr != nil {
+			slog.Warn("scanner watch: add failed", "dir", path, "error", werr)
+		}
+		return nil
+	})
+}
+
+// watch uses fsnotify to monitor dirs() (recursively) and enqueues a
+// changed file once debounce has passed since its last event, so a file
+// still being copied into the library isn't analysed mid-write.
+func (s *Scanner) watch(ctx context.Context, debounce time.Duration) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("scanner: watch disabled, fsnotify init failed", "error", err)
+		return
+	}
+	defer watcher.Close()
+
+	for _, dir := range s.dirs() {
+		addWatches(watcher, dir)
+	}
+
+	var timersMu sync.Mutex
+	timers := make(map[string]*time.Timer)
+	defer func() {
+		timersMu.Lock()
+		for _, t := range timers {
+			t.Stop()
+		}
+		timersMu.Unlock()
+	}()
+
+	debounceFile := func(path string) {
+		timersMu.Lock()
+		defer timersMu.Unlock()
+		if t, ok := timers[path]; ok {
+			t.Reset(debounce)
+			return
+		}
+		timers[path] = time.AfterFunc(debounce, func() {
+			timersMu.Lock()
+			delete(timers, path)
+			timersMu.Unlock()
+			s.enqueue(path, false)
+		})
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watcher.Add(event.Name)
+					continue
+				}
+			}
+			if strings.ToLower(filepath.Ext(event.Name)) != videoExt {
+				continue
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+			debounceFile(event.Name)
+
+		case werr, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("scanner watch error", "error", werr)
+		}
+	}
+}
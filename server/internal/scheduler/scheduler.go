@@ -0,0 +1,82 @@
+// Package scheduler fires transitions on a beat-grid boundary (a bar or
+// phrase) instead of at end-of-file, so a crossfade lands on the downbeat
+// instead of wherever the previous video happened to run out.
+//
+// Each active deck gets its own time.Timer, armed from that deck's BPM
+// and current playback position: NextBoundary computes how far away the
+// next bar/phrase start is, and Schedule arms a timer to fire there. The
+// timer is re-armed whenever the deck starts a new video (new BPM, new
+// playback position) and canceled if the deck stops or loses its match.
+package scheduler
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Scheduler tracks one beat-grid timer per deck.
+type Scheduler struct {
+	mu     sync.Mutex
+	timers map[int]*time.Timer
+}
+
+// New creates an empty Scheduler.
+func New() *Scheduler {
+	return &Scheduler{timers: make(map[int]*time.Timer)}
+}
+
+// NextBoundary returns the next bar/phrase boundary at or after now, for a
+// deck that started playing at startedAt with the given bpm, where a
+// boundary occurs every phraseBeats beats (4 for a bar, 16 or 32 for a
+// phrase). Returns the zero Time if bpm or phraseBeats isn't usable.
+func NextBoundary(startedAt time.Time, bpm float64, phraseBeats int, now time.Time) time.Time {
+	if bpm <= 0 || phraseBeats <= 0 {
+		return time.Time{}
+	}
+	secPerBeat := 60 / bpm
+	elapsedBeats := now.Sub(startedAt).Seconds() / secPerBeat
+	beatsIntoPhrase := math.Mod(elapsedBeats, float64(phraseBeats))
+	if beatsIntoPhrase < 0 {
+		beatsIntoPhrase += float64(phraseBeats)
+	}
+	beatsToNext := float64(phraseBeats) - beatsIntoPhrase
+	if beatsToNext <= 0 {
+		beatsToNext = float64(phraseBeats)
+	}
+	return now.Add(time.Duration(beatsToNext * secPerBeat * float64(time.Second)))
+}
+
+// Schedule arms (re-arming if already set) the timer for deck so onBoundary
+// fires at the next bar/phrase boundary computed from startedAt, bpm, and
+// phraseBeats, returning that boundary so the caller can announce it ahead
+// of time (e.g. for client pre-roll). ok is false, and any existing timer
+// for deck is canceled, if bpm/phraseBeats can't produce a boundary.
+func (s *Scheduler) Schedule(deck int, startedAt time.Time, bpm float64, phraseBeats int, onBoundary func(deck int, boundary time.Time)) (boundary time.Time, ok bool) {
+	now := time.Now()
+	boundary = NextBoundary(startedAt, bpm, phraseBeats, now)
+	if boundary.IsZero() {
+		s.Cancel(deck)
+		return time.Time{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.timers[deck]; ok {
+		t.Stop()
+	}
+	s.timers[deck] = time.AfterFunc(boundary.Sub(now), func() {
+		onBoundary(deck, boundary)
+	})
+	return boundary, true
+}
+
+// Cancel stops any timer scheduled for deck.
+func (s *Scheduler) Cancel(deck int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.timers[deck]; ok {
+		t.Stop()
+		delete(s.timers, deck)
+	}
+}
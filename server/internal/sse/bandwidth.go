@@ -0,0 +1,167 @@
+package sse
+
+import (
+	"sync"
+	"time"
+)
+
+// Trend classifies the recent direction of a client's estimated
+// bandwidth, similar to the overuse detector used by WebRTC-style
+// bandwidth estimators (and, closer to home, Neko's): it's not just a
+// point estimate, it's whether throughput is degrading, holding steady,
+// or has headroom to spare.
+type Trend int
+
+const (
+	TrendNormal   Trend = iota // samples are stable
+	TrendOveruse               // throughput degrading — be conservative
+	TrendUnderuse              // throughput has headroom — safe to step up
+)
+
+// bandwidthWindowSize bounds how many RTT/throughput samples feed the
+// trend detector; older samples age out.
+const bandwidthWindowSize = 10
+
+// unstableDuration is the hysteresis window: a candidate trend must
+// persist for at least this long before it's committed, so a single
+// noisy sample can't flip the classification back and forth.
+const unstableDuration = 3 * time.Second
+
+type bandwidthSample struct {
+	throughputBps float64
+	rttMs         float64
+}
+
+type pendingPing struct {
+	sentAt      time.Time
+	payloadSize int
+}
+
+// BandwidthEstimator tracks one SSE client's round-trip latency and
+// throughput from periodic ping/ack round trips, classifying the trend
+// with hysteresis so playback quality doesn't thrash on a single slow
+// sample.
+type BandwidthEstimator struct {
+	mu      sync.Mutex
+	pending map[string]pendingPing
+	samples []bandwidthSample
+
+	trend          Trend
+	candidate      Trend
+	candidateSince time.Time
+}
+
+// NewBandwidthEstimator creates an estimator with no samples yet;
+// EstimatedBps returns 0 until the first ping is acknowledged.
+func NewBandwidthEstimator() *BandwidthEstimator {
+	return &BandwidthEstimator{
+		pending:        make(map[string]pendingPing),
+		trend:          TrendNormal,
+		candidate:      TrendNormal,
+		candidateSince: time.Now(),
+	}
+}
+
+// RecordPingSent notes that a ping of payloadSize bytes with the given
+// ID was just sent, so a later RecordAck can compute its RTT.
+func (b *BandwidthEstimator) RecordPingSent(pingID string, payloadSize int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[pingID] = pendingPing{sentAt: time.Now(), payloadSize: payloadSize}
+}
+
+// RecordAck completes a ping round trip, folding a new RTT/throughput
+// sample into the window and re-evaluating the trend. Acks for unknown
+// or already-acked ping IDs are ignored.
+func (b *BandwidthEstimator) RecordAck(pingID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.pending[pingID]
+	if !ok {
+		return
+	}
+	delete(b.pending, pingID)
+
+	rtt := time.Since(p.sentAt)
+	if rtt <= 0 {
+		return
+	}
+
+	sample := bandwidthSample{
+		throughputBps: float64(p.payloadSize*8) / rtt.Seconds(),
+		rttMs:         float64(rtt.Milliseconds()),
+	}
+	b.samples = append(b.samples, sample)
+	if len(b.samples) > bandwidthWindowSize {
+		b.samples = b.samples[len(b.samples)-bandwidthWindowSize:]
+	}
+	b.updateTrend()
+}
+
+// updateTrend compares the older and newer halves of the sample window
+// to classify a candidate trend, then applies hysteresis: the candidate
+// must hold for unstableDuration before it's committed. Must be called
+// with mu held.
+func (b *BandwidthEstimator) updateTrend() {
+	if len(b.samples) < 4 {
+		return
+	}
+
+	mid := len(b.samples) / 2
+	older := meanThroughput(b.samples[:mid])
+	newer := meanThroughput(b.samples[mid:])
+	if older <= 0 {
+		return
+	}
+	ratio := newer / older
+
+	candidate := TrendNormal
+	switch {
+	case ratio < 0.85:
+		candidate = TrendOveruse
+	case ratio > 1.20:
+		candidate = TrendUnderuse
+	}
+
+	if candidate != b.candidate {
+		b.candidate = candidate
+		b.candidateSince = time.Now()
+		return
+	}
+	if candidate != b.trend && time.Since(b.candidateSince) >= unstableDuration {
+		b.trend = candidate
+	}
+}
+
+func meanThroughput(samples []bandwidthSample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, s := range samples {
+		sum += s.throughputBps
+	}
+	return sum / float64(len(samples))
+}
+
+// EstimatedBps returns the client's current estimated throughput in
+// bits per second, biased conservatively on overuse and optimistically
+// on underuse. Returns 0 if no samples have landed yet — callers should
+// treat 0 as "unknown" and not downgrade quality on it.
+func (b *BandwidthEstimator) EstimatedBps() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.samples) == 0 {
+		return 0
+	}
+	avg := meanThroughput(b.samples)
+	switch b.trend {
+	case TrendOveruse:
+		avg *= 0.7
+	case TrendUnderuse:
+		avg *= 1.1
+	}
+	return avg
+}
@@ -4,30 +4,83 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 )
 
 // Client represents a connected SSE browser client.
 type Client struct {
-	ID     string
-	Events chan []byte // outbound event data
+	ID        string
+	Events    chan []byte         // outbound event data
+	Bandwidth *BandwidthEstimator // per-client throughput estimate, nil if not tracked
+	Topics    map[string]bool     // subscribed topics; nil/empty means "every topic"
+}
+
+// Wants reports whether c should receive an event published under topic.
+// An untagged event (topic == "") always reaches every client, and a
+// client with no declared Topics wants everything — topic filtering only
+// narrows delivery once both the event and the client opt in.
+func (c *Client) Wants(topic string) bool {
+	if topic == "" || len(c.Topics) == 0 {
+		return true
+	}
+	return c.Topics[topic]
+}
+
+// ringSize is how many recent broadcast events the hub retains for
+// Last-Event-ID replay on reconnect.
+const ringSize = 1024
+
+// ringEvent is one buffered broadcast, keyed by its monotonic event ID.
+type ringEvent struct {
+	id    uint64
+	topic string
+	data  []byte
+}
+
+// broadcastMsg is one event in flight between Broadcast/BroadcastTopic
+// and the hub's Run loop.
+type broadcastMsg struct {
+	topic string
+	data  []byte
+}
+
+// replayResult is what a registration's reply channel carries back: the
+// buffered events the client missed, and whether the ring actually
+// covered lastEventID (ok=false means "too old, caller should fall back
+// to its own snapshot replay", distinct from "covered it, nothing missed").
+type replayResult struct {
+	events [][]byte
+	ok     bool
+}
+
+// registration is one client's Register request, carrying whatever
+// Last-Event-ID replay it's owed back to the caller via reply.
+type registration struct {
+	client      *Client
+	lastEventID uint64
+	reply       chan replayResult
 }
 
 // Hub manages SSE client connections and broadcasts events.
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
-	register   chan *Client
+	broadcast  chan broadcastMsg
+	register   chan registration
 	unregister chan *Client
 	mu         sync.RWMutex
 	done       chan struct{}
+
+	lastID uint64 // monotonic event ID counter, assigned by Broadcast
+	ringMu sync.Mutex
+	ring   []ringEvent // last ringSize broadcasts, oldest first, for replay
 }
 
 // NewHub creates a new SSE hub.
 func NewHub() *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte, 64),
-		register:   make(chan *Client),
+		broadcast:  make(chan broadcastMsg, 64),
+		register:   make(chan registration),
 		unregister: make(chan *Client),
 		done:       make(chan struct{}),
 	}
@@ -37,11 +90,17 @@ func NewHub() *Hub {
 func (h *Hub) Run() {
 	for {
 		select {
-		case client := <-h.register:
+		case req := <-h.register:
 			h.mu.Lock()
-			h.clients[client] = true
+			h.clients[req.client] = true
 			h.mu.Unlock()
-			slog.Info("sse client connected", "id", client.ID, "total", h.Count())
+			// Computed after the client joins h.clients but still inside
+			// this case body, so no broadcast processed by this same loop
+			// can land between "registered" and "replay captured" — it's
+			// either in the replay list or delivered live, never neither.
+			events, ok := h.replaySince(req.client, req.lastEventID)
+			req.reply <- replayResult{events: events, ok: ok}
+			slog.Info("sse client connected", "id", req.client.ID, "total", h.Count())
 
 		case client := <-h.unregister:
 			h.mu.Lock()
@@ -52,11 +111,14 @@ func (h *Hub) Run() {
 			h.mu.Unlock()
 			slog.Info("sse client disconnected", "id", client.ID, "total", h.Count())
 
-		case data := <-h.broadcast:
+		case msg := <-h.broadcast:
 			h.mu.RLock()
 			for client := range h.clients {
+				if !client.Wants(msg.topic) {
+					continue
+				}
 				select {
-				case client.Events <- data:
+				case client.Events <- msg.data:
 				default:
 					// Client buffer full — drop message rather than block
 					slog.Warn("sse client buffer full, dropping message", "id", client.ID)
@@ -76,12 +138,22 @@ func (h *Hub) Run() {
 	}
 }
 
-// Register adds a client to the hub.
+// Register adds a client to the hub and, if lastEventID is nonzero,
+// reports any buffered events the client missed (id > lastEventID) so
+// the caller can replay them before streaming live events from
+// client.Events. ok is false if lastEventID is 0 or older than the ring
+// can cover (e.g. the client was gone long enough for the missed events
+// to be evicted) — the caller should fall back to its own snapshot-cache
+// replay in that case.
 // Uses a select so that sends after Close() don't block forever.
-func (h *Hub) Register(c *Client) {
+func (h *Hub) Register(c *Client, lastEventID uint64) (replay [][]byte, ok bool) {
+	reply := make(chan replayResult, 1)
 	select {
-	case h.register <- c:
+	case h.register <- registration{client: c, lastEventID: lastEventID, reply: reply}:
+		result := <-reply
+		return result.events, result.ok
 	case <-h.done:
+		return nil, false
 	}
 }
 
@@ -94,16 +166,97 @@ func (h *Hub) Unregister(c *Client) {
 	}
 }
 
-// Broadcast sends a named SSE event to all connected clients.
-// Uses a select so that sends after Close() don't block forever.
+// Broadcast sends a named SSE event to all connected clients, regardless
+// of any topic they've subscribed to. Equivalent to
+// BroadcastTopic("", event, data).
 func (h *Hub) Broadcast(event string, data []byte) {
-	msg := fmt.Appendf(nil, "event: %s\ndata: %s\n\n", event, data)
+	h.BroadcastTopic("", event, data)
+}
+
+// BroadcastTopic sends a named SSE event to clients subscribed to topic
+// (plus any client with no topic filter), assigning it the next
+// monotonic event ID and buffering it in the replay ring so a
+// reconnecting client's Last-Event-ID can be honored. An empty topic
+// reaches every client, same as Broadcast.
+// Uses a select so that sends after Close() don't block forever.
+func (h *Hub) BroadcastTopic(topic, event string, data []byte) {
+	id := atomic.AddUint64(&h.lastID, 1)
+	msg := fmt.Appendf(nil, "id: %d\nevent: %s\ndata: %s\n\n", id, event, data)
+	h.addToRing(id, topic, msg)
 	select {
-	case h.broadcast <- msg:
+	case h.broadcast <- broadcastMsg{topic: topic, data: msg}:
 	case <-h.done:
 	}
 }
 
+// addToRing appends a broadcast event to the replay ring, evicting the
+// oldest entry once the ring exceeds ringSize.
+func (h *Hub) addToRing(id uint64, topic string, msg []byte) {
+	h.ringMu.Lock()
+	defer h.ringMu.Unlock()
+	h.ring = append(h.ring, ringEvent{id: id, topic: topic, data: msg})
+	if len(h.ring) > ringSize {
+		h.ring = h.ring[len(h.ring)-ringSize:]
+	}
+}
+
+// replaySince returns the buffered events after lastID that c is
+// subscribed to. ok is false if lastID is 0 or older than the ring can
+// replay (e.g. the client was disconnected long enough for the missed
+// events to be evicted), in which case events is always nil.
+func (h *Hub) replaySince(c *Client, lastID uint64) (events [][]byte, ok bool) {
+	if lastID == 0 {
+		return nil, false
+	}
+	h.ringMu.Lock()
+	defer h.ringMu.Unlock()
+	if len(h.ring) == 0 || lastID < h.ring[0].id-1 {
+		return nil, false
+	}
+	for _, e := range h.ring {
+		if e.id > lastID && c.Wants(e.topic) {
+			events = append(events, e.data)
+		}
+	}
+	return events, true
+}
+
+// BroadcastPerClient sends a named SSE event to every connected client
+// subscribed to topic (plus any client with no topic filter), calling
+// build separately for each one so the payload can be customized per
+// client (e.g. substituting a bandwidth-appropriate video variant URL).
+// An empty topic reaches every client. Uses the same drop-rather-than-
+// block backpressure as Broadcast; unlike Broadcast, per-client events
+// aren't assigned an ID or buffered for replay, since each carries a
+// full current snapshot that's superseded by the next one anyway.
+func (h *Hub) BroadcastPerClient(topic, event string, build func(c *Client) []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients {
+		if !client.Wants(topic) {
+			continue
+		}
+		msg := fmt.Appendf(nil, "event: %s\ndata: %s\n\n", event, build(client))
+		select {
+		case client.Events <- msg:
+		default:
+			slog.Warn("sse client buffer full, dropping message", "id", client.ID)
+		}
+	}
+}
+
+// ClientByID returns the connected client with the given ID, if any.
+func (h *Hub) ClientByID(id string) (*Client, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
 // Count returns the number of connected clients.
 func (h *Hub) Count() int {
 	h.mu.RLock()
@@ -0,0 +1,235 @@
+// Package stream provides a live HTTP-FLV (and, via ffmpeg's "tee" muxer,
+// RTMP push) output of whatever video is currently feeding the active
+// deck, so OBS, VLC, or a browser <video> tag can watch the mix without
+// the web dashboard.
+//
+// Like the hls package, muxing is delegated to ffmpeg rather than
+// reimplemented — FLV/AMF framing is fiddly to get byte-exact and
+// ffmpeg already does it well. One consequence: this package cannot
+// inject custom onMetaData/script tags mid-stream (that would require
+// owning the muxer), so transition boundaries are NOT signaled inline
+// in the FLV stream — consumers that need transition awareness should
+// subscribe to the existing SSE "transition-play" event alongside the
+// live feed. RTMP *ingest* (acting as an RTMP server a publisher like
+// OBS or VDJ's broadcaster pushes into) lives in internal/rtmp instead —
+// that needs a standing listener implementing the handshake/chunk-stream
+// protocol, which this package's ffmpeg-based approach can't provide.
+// HandleRTMPPublish here only covers RTMP *egress* (pushing the mix out
+// to an external RTMP endpoint via config), which is the part ffmpeg can
+// do directly.
+package stream
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"sync"
+)
+
+// Reader is one HTTP-FLV client's fanout queue. The channel's fixed
+// capacity bounds how much unread data a slow HTTP-FLV client can have
+// queued before it's dropped, so one stalled reader can't back up the
+// publisher.
+type Reader struct {
+	id string
+	ch chan []byte
+}
+
+// Broadcaster fans a single byte stream out to many readers.
+type Broadcaster struct {
+	mu      sync.RWMutex
+	readers map[string]*Reader
+}
+
+// NewBroadcaster creates an empty fanout.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{readers: make(map[string]*Reader)}
+}
+
+// AddReader registers a new reader under id (e.g. a per-connection ID),
+// returning its channel of FLV chunks.
+func (b *Broadcaster) AddReader(id string) *Reader {
+	r := &Reader{id: id, ch: make(chan []byte, 256)}
+	b.mu.Lock()
+	b.readers[id] = r
+	b.mu.Unlock()
+	return r
+}
+
+// RemoveReader unregisters a reader, closing its channel.
+func (b *Broadcaster) RemoveReader(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if r, ok := b.readers[id]; ok {
+		delete(b.readers, id)
+		close(r.ch)
+	}
+}
+
+// Publish fans a chunk of muxed bytes out to every reader. A reader
+// whose queue is full is dropped entirely (rather than blocking the
+// publisher or silently desyncing it) — an FLV stream can't resume
+// mid-tag, so a stalled client has to reconnect anyway.
+func (b *Broadcaster) Publish(chunk []byte) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for id, r := range b.readers {
+		select {
+		case r.ch <- chunk:
+		default:
+			slog.Warn("stream reader buffer full, dropping reader", "id", id)
+			go b.RemoveReader(id)
+		}
+	}
+}
+
+// Count returns the number of connected readers.
+func (b *Broadcaster) Count() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.readers)
+}
+
+// Manager owns the ffmpeg process that transcodes the active deck's
+// current source video into a live FLV feed.
+type Manager struct {
+	broadcaster *Broadcaster
+
+	mu         sync.Mutex
+	sourcePath string
+	cmd        *exec.Cmd
+	cancel     func()
+}
+
+// NewManager creates an idle Manager; call SetSource to start streaming.
+func NewManager() *Manager {
+	return &Manager{broadcaster: NewBroadcaster()}
+}
+
+// AddReader registers a new HTTP-FLV client.
+func (m *Manager) AddReader(id string) *Reader {
+	return m.broadcaster.AddReader(id)
+}
+
+// RemoveReader unregisters an HTTP-FLV client.
+func (m *Manager) RemoveReader(id string) {
+	m.broadcaster.RemoveReader(id)
+}
+
+// C returns the reader's channel of FLV chunks, closed once the reader
+// is removed.
+func (r *Reader) C() <-chan []byte {
+	return r.ch
+}
+
+// ReaderCount returns the number of connected HTTP-FLV clients.
+func (m *Manager) ReaderCount() int {
+	return m.broadcaster.Count()
+}
+
+// Publishing reports whether an ffmpeg session is currently running.
+func (m *Manager) Publishing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cmd != nil
+}
+
+// Source returns the path currently being streamed, or "" if idle.
+func (m *Manager) Source() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sourcePath
+}
+
+// SetSource (re)starts the ffmpeg session to stream sourcePath, doing
+// nothing if it's already streaming that exact path. If rtmpPushURL is
+// non-empty, the mix is simultaneously pushed there via ffmpeg's "tee"
+// muxer (RTMP egress), alongside the in-process FLV fanout used for
+// HTTP-FLV readers.
+func (m *Manager) SetSource(sourcePath, rtmpPushURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sourcePath == sourcePath && m.cmd != nil {
+		return nil
+	}
+	m.stopLocked()
+	if sourcePath == "" {
+		return nil
+	}
+
+	outputs := "[f=flv]pipe:1"
+	if rtmpPushURL != "" {
+		outputs += fmt.Sprintf("|[f=flv]%s", rtmpPushURL)
+	}
+
+	args := []string{
+		"-y",
+		"-re",
+		"-i", sourcePath,
+		"-c", "copy",
+		"-f", "tee",
+		outputs,
+	}
+	cmd := exec.Command("ffmpeg", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("stream: stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("stream: start ffmpeg: %w", err)
+	}
+
+	m.sourcePath = sourcePath
+	m.cmd = cmd
+	m.cancel = func() { cmd.Process.Kill() }
+
+	go m.pump(stdout, cmd)
+	slog.Info("live stream session started", "source", sourcePath, "rtmpPush", rtmpPushURL != "")
+	return nil
+}
+
+// pump copies ffmpeg's stdout into the broadcaster chunk by chunk until
+// it closes or errors.
+func (m *Manager) pump(stdout io.ReadCloser, cmd *exec.Cmd) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := stdout.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			m.broadcaster.Publish(chunk)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		slog.Warn("live stream ffmpeg session exited", "error", err)
+	}
+
+	m.mu.Lock()
+	if m.cmd == cmd {
+		m.cmd = nil
+		m.sourcePath = ""
+		m.cancel = nil
+	}
+	m.mu.Unlock()
+}
+
+// Stop tears down the running ffmpeg session, if any.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopLocked()
+}
+
+func (m *Manager) stopLocked() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.cmd = nil
+	m.sourcePath = ""
+	m.cancel = nil
+}
@@ -0,0 +1,39 @@
+package tagreader
+
+import (
+	"github.com/jota2rz/vdj-video-sync/server/internal/bpm"
+	"github.com/jota2rz/vdj-video-sync/server/internal/musickey"
+)
+
+// AudioAnalysisReader is the last-resort TagReader: it decodes the
+// actual audio (bpm.AnalyseFile's tempogram) and runs keyfinder-cli
+// (musickey.AnalyseFile) rather than trusting any embedded metadata.
+// It's the most accurate backend and by far the most expensive, which is
+// why it belongs last in a reader list — callers that want to skip it
+// entirely (e.g. a fast filename-only mode) just build their reader list
+// without it.
+//
+// video.Matcher doesn't register this reader directly: its own
+// analyseBPM/analyseKey already call bpm.AnalyseFile/musickey.AnalyseFile
+// for this exact purpose, returning the richer bpm.AnalyseResult
+// (confidence, first-beat phase) that the generic Tags shape doesn't
+// carry. This type exists so other TagReader callers — a future
+// batch-tagging command, or anything that only needs BPM/key and not the
+// extra fields — get the same fallback behavior without depending on
+// internal/video.
+type AudioAnalysisReader struct{}
+
+// ReadTags never returns an error for a readable file — BPM/key analysis
+// failing just means those fields stay zero, the same as any other
+// backend with nothing to contribute. An error is only returned if the
+// file can't even be opened for decoding.
+func (AudioAnalysisReader) ReadTags(absPath string) (Tags, error) {
+	var tags Tags
+	if result, err := bpm.AnalyseFile(absPath); err == nil {
+		tags.BPM = result.BPM
+	}
+	if key, err := musickey.AnalyseFile(absPath); err == nil {
+		tags.Key = key
+	}
+	return tags, nil
+}
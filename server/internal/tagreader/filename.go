@@ -0,0 +1,71 @@
+package tagreader
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// FilenameReader extracts a BPM from filename conventions like
+// "Something - 128bpm.mp4" or "track_128bpm.mp4". It's the cheapest and
+// highest-priority backend — a DJ who names files this way has already
+// done the hard work, so there's no reason to read container metadata
+// or decode audio first.
+type FilenameReader struct{}
+
+// ReadTags never returns an error: a filename either contains a BPM
+// hint or it doesn't.
+func (FilenameReader) ReadTags(absPath string) (Tags, error) {
+	return Tags{BPM: parseBPMFromName(filepath.Base(absPath))}, nil
+}
+
+// parseBPMFromName tries to extract a BPM value from a filename.
+// Expected format: "Something - 128bpm.mp4" or "track_128bpm.mp4".
+func parseBPMFromName(name string) float64 {
+	lower := strings.ToLower(name)
+	idx := strings.Index(lower, "bpm")
+	if idx <= 0 {
+		return 0
+	}
+
+	// Walk backwards from "bpm" to collect digits and dots
+	numStr := ""
+	for i := idx - 1; i >= 0; i-- {
+		c := lower[i]
+		if (c >= '0' && c <= '9') || c == '.' {
+			numStr = string(c) + numStr
+		} else if len(numStr) > 0 {
+			break
+		}
+	}
+
+	var bpmVal float64
+	if numStr != "" {
+		scanDecimal(numStr, &bpmVal)
+	}
+	return bpmVal
+}
+
+// scanDecimal parses a plain decimal number (digits and at most one
+// dot), avoiding strconv's stricter format requirements.
+func scanDecimal(s string, v *float64) {
+	var result float64
+	var decimal float64 = 1
+	pastDot := false
+	for _, c := range s {
+		if c == '.' {
+			if pastDot {
+				break // second dot → stop parsing
+			}
+			pastDot = true
+			continue
+		}
+		digit := float64(c - '0')
+		if pastDot {
+			decimal *= 10
+			result += digit / decimal
+		} else {
+			result = result*10 + digit
+		}
+	}
+	*v = result
+}
@@ -0,0 +1,193 @@
+package tagreader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// id3v1Genres is the classic 80-entry ID3v1 genre list that ID3v2's TCON
+// frame can still reference by index (e.g. "(17)" for Rock), for tags
+// written by tools that never moved past the v1 genre enum.
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel",
+	"Noise", "Alternative Rock", "Bass", "Soul", "Punk", "Space",
+	"Meditative", "Instrumental Pop", "Instrumental Rock", "Ethnic",
+	"Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk",
+	"Eurodance", "Dream", "Southern Rock", "Comedy", "Cult", "Gangsta",
+	"Top 40", "Christian Rap", "Pop/Funk", "Jungle", "Native American",
+	"Cabaret", "New Wave", "Psychedelic", "Rave", "Showtunes", "Trailer",
+	"Lo-Fi", "Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro",
+	"Musical", "Rock & Roll", "Hard Rock",
+}
+
+// ID3Reader extracts BPM (TBPM), initial key (TKEY), and genre (TCON)
+// from an ID3v2 tag prepended to the file — MP3s, and any other
+// container that happens to carry one. There's no ID3 library in this
+// module's dependency set, so this is a minimal hand-rolled parser: just
+// enough of the ID3v2.2/2.3/2.4 frame format to pull out three text
+// frames, not a general-purpose tag editor.
+type ID3Reader struct{}
+
+// ReadTags returns a zero Tags, nil error if the file has no leading
+// "ID3" magic — that's the common case for MP4/MKV libraries, not a
+// failure.
+func (ID3Reader) ReadTags(absPath string) (Tags, error) {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return Tags{}, fmt.Errorf("tagreader: open %s: %w", absPath, err)
+	}
+	defer f.Close()
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return Tags{}, nil // too short to hold a tag; not an error
+	}
+	if string(header[0:3]) != "ID3" {
+		return Tags{}, nil
+	}
+
+	majorVersion := header[3]
+	tagSize := synchsafeToInt(header[6:10])
+
+	body := make([]byte, tagSize)
+	if _, err := io.ReadFull(f, body); err != nil {
+		return Tags{}, fmt.Errorf("tagreader: read id3 body of %s: %w", absPath, err)
+	}
+
+	var frames map[string]string
+	if majorVersion == 2 {
+		frames = parseID3v22Frames(body)
+	} else {
+		frames = parseID3v23Frames(body, majorVersion >= 4)
+	}
+
+	var tags Tags
+	if v := strings.TrimSpace(frames["TBPM"]); v != "" {
+		if bpmVal, err := strconv.ParseFloat(v, 64); err == nil {
+			tags.BPM = bpmVal
+		}
+	}
+	tags.Key = strings.TrimSpace(frames["TKEY"])
+	tags.Genre = resolveGenre(frames["TCON"])
+	return tags, nil
+}
+
+// synchsafeToInt decodes a 4-byte ID3v2 "synchsafe" integer, where only
+// the low 7 bits of each byte carry data (the high bit is always 0 so a
+// tag's size can never be mistaken for a sync signal in the audio
+// stream).
+func synchsafeToInt(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// parseID3v22Frames walks ID3v2.2 frames: 3-char IDs, 3-byte sizes, no
+// flags.
+func parseID3v22Frames(body []byte) map[string]string {
+	frames := make(map[string]string)
+	pos := 0
+	for pos+6 <= len(body) {
+		id := string(body[pos : pos+3])
+		if id == "\x00\x00\x00" {
+			break // padding reached
+		}
+		size := int(body[pos+3])<<16 | int(body[pos+4])<<8 | int(body[pos+5])
+		pos += 6
+		if size <= 0 || pos+size > len(body) {
+			break
+		}
+		// v2.2 used 3-char frame IDs (e.g. "TBP", "TKE", "TCO"); map to
+		// their v2.3+ equivalents so callers only need one frame name.
+		switch id {
+		case "TBP":
+			frames["TBPM"] = decodeID3Text(body[pos : pos+size])
+		case "TKE":
+			frames["TKEY"] = decodeID3Text(body[pos : pos+size])
+		case "TCO":
+			frames["TCON"] = decodeID3Text(body[pos : pos+size])
+		}
+		pos += size
+	}
+	return frames
+}
+
+// parseID3v23Frames walks ID3v2.3/2.4 frames: 4-char IDs, 4-byte sizes
+// (synchsafe only in v2.4), 2 bytes of flags.
+func parseID3v23Frames(body []byte, synchsafeSizes bool) map[string]string {
+	frames := make(map[string]string)
+	pos := 0
+	for pos+10 <= len(body) {
+		id := string(body[pos : pos+4])
+		if id == "\x00\x00\x00\x00" {
+			break // padding reached
+		}
+		var size int
+		if synchsafeSizes {
+			size = synchsafeToInt(body[pos+4 : pos+8])
+		} else {
+			size = int(body[pos+4])<<24 | int(body[pos+5])<<16 | int(body[pos+6])<<8 | int(body[pos+7])
+		}
+		pos += 10
+		if size <= 0 || pos+size > len(body) {
+			break
+		}
+		switch id {
+		case "TBPM", "TKEY", "TCON":
+			frames[id] = decodeID3Text(body[pos : pos+size])
+		}
+		pos += size
+	}
+	return frames
+}
+
+// decodeID3Text decodes a text frame's payload, whose first byte is an
+// encoding marker (0 = ISO-8859-1, 1 = UTF-16 with BOM, 2 = UTF-16BE,
+// 3 = UTF-8). BPM/key/genre values are always plain ASCII digits/codes
+// in practice, so UTF-16 frames are decoded by simply dropping the
+// high/null bytes rather than pulling in a full UTF-16 decoder.
+func decodeID3Text(payload []byte) string {
+	if len(payload) == 0 {
+		return ""
+	}
+	encoding := payload[0]
+	text := payload[1:]
+	switch encoding {
+	case 1, 2:
+		var sb strings.Builder
+		for i := 0; i+1 < len(text); i += 2 {
+			if text[i] != 0 {
+				sb.WriteByte(text[i])
+			} else if text[i+1] != 0 {
+				sb.WriteByte(text[i+1])
+			}
+		}
+		return strings.Trim(sb.String(), "\x00")
+	default:
+		return strings.Trim(string(text), "\x00")
+	}
+}
+
+// resolveGenre turns a TCON value into a display genre string. Modern
+// taggers store plain text directly; older ones reference the ID3v1
+// genre list by index, either bare ("17") or parenthesised ("(17)").
+func resolveGenre(tcon string) string {
+	tcon = strings.TrimSpace(tcon)
+	if tcon == "" {
+		return ""
+	}
+	idx := tcon
+	if strings.HasPrefix(tcon, "(") && strings.HasSuffix(tcon, ")") {
+		idx = tcon[1 : len(tcon)-1]
+	}
+	if n, err := strconv.Atoi(idx); err == nil && n >= 0 && n < len(id3v1Genres) {
+		return id3v1Genres[n]
+	}
+	return tcon
+}
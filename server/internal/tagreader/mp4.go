@@ -0,0 +1,130 @@
+package tagreader
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	gomp4 "github.com/abema/go-mp4"
+)
+
+// MP4AtomReader extracts BPM, key, and genre from an MP4's iTunes-style
+// metadata list (moov/udta/meta/ilst): the standard "tmpo" (BPM) and
+// "©gen"/"gnre" (genre) atoms, plus the freeform "----" atoms that
+// Serato, Traktor, and Mixed In Key write their own analysis into (a
+// "mean" atom naming the tool, a "name" atom naming the tag, and a
+// sibling "data" atom holding the value).
+type MP4AtomReader struct{}
+
+// freeformMeans are the "----" atom "mean" strings (lowercased) this
+// reader treats as trustworthy — the standard iTunes namespace plus the
+// DJ tools known to write BPM/key/genre into it.
+var freeformMeans = []string{"itunes", "serato", "traktor", "mixedinkey"}
+
+// ReadTags opens absPath and walks its ilst atom. An absent or
+// unparseable ilst isn't an error — it just means this backend has
+// nothing to contribute, same as every other zero Tags field.
+func (MP4AtomReader) ReadTags(absPath string) (Tags, error) {
+	f, err := os.Open(absPath)
+	if err != nil {
+		return Tags{}, fmt.Errorf("tagreader: open %s: %w", absPath, err)
+	}
+	defer f.Close()
+
+	var tags Tags
+	var curMean, curName string
+
+	_, _ = gomp4.ReadBoxStructure(f, func(h *gomp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type {
+		case gomp4.BoxTypeMoov(), gomp4.StrToBoxType("udta"), gomp4.StrToBoxType("meta"),
+			gomp4.StrToBoxType("ilst"):
+			return h.Expand()
+
+		case gomp4.StrToBoxType("----"):
+			curMean, curName = "", ""
+			return h.Expand()
+
+		case gomp4.StrToBoxType("tmpo"):
+			if data, err := readPayload(h); err == nil && len(data) >= 10 {
+				// 4 bytes version/flags + 2 bytes reserved + big-endian uint16 BPM
+				tags.BPM = float64(uint16(data[8])<<8 | uint16(data[9]))
+			}
+			return nil, nil
+
+		case gomp4.StrToBoxType("©gen"), gomp4.StrToBoxType("gnre"):
+			if data, err := readPayload(h); err == nil {
+				tags.Genre = decodeITunesText(data)
+			}
+			return nil, nil
+
+		case gomp4.StrToBoxType("mean"):
+			if data, err := readPayload(h); err == nil && len(data) > 4 {
+				curMean = strings.ToLower(string(data[4:]))
+			}
+			return nil, nil
+
+		case gomp4.StrToBoxType("name"):
+			if data, err := readPayload(h); err == nil && len(data) > 4 {
+				curName = strings.ToLower(string(data[4:]))
+			}
+			return nil, nil
+
+		case gomp4.StrToBoxType("data"):
+			if data, err := readPayload(h); err == nil {
+				applyFreeformTag(&tags, curMean, curName, decodeITunesText(data))
+			}
+			return nil, nil
+		}
+		return nil, nil
+	})
+
+	return tags, nil
+}
+
+// readPayload reads a leaf box's raw payload bytes via the handle's
+// ReadData callback. ReadPayload() is for boxes go-mp4 already has a
+// typed definition for; these are opaque/unregistered atom types, so we
+// read the bytes directly instead.
+func readPayload(h *gomp4.ReadHandle) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := h.ReadData(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeITunesText strips an iTunes metadata "data" atom's 8-byte header
+// (type/locale flags) and returns the remaining UTF-8 text.
+func decodeITunesText(data []byte) string {
+	if len(data) <= 8 {
+		return ""
+	}
+	return strings.TrimSpace(string(data[8:]))
+}
+
+// applyFreeformTag records a freeform "----" atom's value if its mean/
+// name identify a tag this reader understands.
+func applyFreeformTag(tags *Tags, mean, name, value string) {
+	known := false
+	for _, m := range freeformMeans {
+		if strings.Contains(mean, m) {
+			known = true
+			break
+		}
+	}
+	if !known || value == "" {
+		return
+	}
+	switch name {
+	case "bpm", "com.serato.bpm":
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			tags.BPM = v
+		}
+	case "initialkey", "key", "com.serato.key", "traktor4.key":
+		tags.Key = value
+	case "genre":
+		tags.Genre = value
+	}
+}
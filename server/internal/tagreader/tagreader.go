@@ -0,0 +1,76 @@
+// Package tagreader extracts BPM, musical key, genre, and duration
+// metadata embedded in a video's audio track, independently of full
+// audio analysis. Several backends read progressively more expensive
+// sources — a filename convention, embedded MP4 atoms, ID3v2 frames —
+// so a caller can resolve a video's tags cheaply in the common case and
+// only fall back to decoding audio when nothing else had an answer.
+package tagreader
+
+// Tags holds whatever metadata a TagReader backend could extract. Zero
+// values (0, "") mean "not found by this backend" — callers are expected
+// to merge several backends' Tags together (filling in only the zero
+// fields) rather than trusting any one exclusively.
+type Tags struct {
+	BPM      float64
+	Key      string // Camelot wheel notation (e.g. "8A"), if the backend can express it
+	Genre    string
+	Duration float64 // seconds; 0 if unknown
+}
+
+// TagReader extracts Tags from a video (or its embedded audio track) at
+// absPath. An error means the backend couldn't read the file at all —
+// not merely that a tag was absent, which is just a zero Tags field —
+// so callers should treat an error as "skip this backend", not "fail the
+// whole lookup".
+type TagReader interface {
+	ReadTags(absPath string) (Tags, error)
+}
+
+// IsZero reports whether every field is unset, i.e. this backend found
+// nothing at all.
+func (t Tags) IsZero() bool {
+	return t.BPM == 0 && t.Key == "" && t.Genre == "" && t.Duration == 0
+}
+
+// Merge fills any of t's zero fields in from other, leaving fields t
+// already has untouched. Used to combine several readers' results in
+// priority order without a later, lower-priority reader overwriting an
+// earlier one's answer.
+func (t Tags) Merge(other Tags) Tags {
+	if t.BPM == 0 {
+		t.BPM = other.BPM
+	}
+	if t.Key == "" {
+		t.Key = other.Key
+	}
+	if t.Genre == "" {
+		t.Genre = other.Genre
+	}
+	if t.Duration == 0 {
+		t.Duration = other.Duration
+	}
+	return t
+}
+
+// Resolve runs readers in order starting from seed (already-known fields
+// take precedence over anything a reader finds), merging each one's Tags
+// into the result and stopping once BPM, Key, and Genre are all filled or
+// readers are exhausted. Duration is deliberately excluded from that
+// exit check: no backend in this package resolves it yet, so requiring
+// it would mean Resolve never short-circuits. Errors from individual
+// readers are swallowed — a backend that can't read the file just
+// contributes nothing.
+func Resolve(readers []TagReader, absPath string, seed Tags) Tags {
+	tags := seed
+	for _, r := range readers {
+		if tags.BPM != 0 && tags.Key != "" && tags.Genre != "" {
+			break
+		}
+		found, err := r.ReadTags(absPath)
+		if err != nil {
+			continue
+		}
+		tags = tags.Merge(found)
+	}
+	return tags
+}
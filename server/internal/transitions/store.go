@@ -3,21 +3,34 @@ package transitions
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/jota2rz/vdj-video-sync/server/internal/models"
+	"github.com/jota2rz/vdj-video-sync/server/internal/udiff"
 )
 
 // ErrSeedProtected is returned when attempting to delete a built-in effect.
 var ErrSeedProtected = errors.New("built-in effects cannot be deleted")
 
+// DefaultHistoryLimit caps how many non-zero revisions Store keeps per
+// effect (see pruneRevisions) when the caller doesn't pass its own limit
+// to NewStore. Mirrors overlay.DefaultHistoryLimit.
+const DefaultHistoryLimit = 50
+
 // Store provides CRUD operations for transition CSS effects.
 type Store struct {
-	db *sql.DB
+	db           *sql.DB
+	historyLimit int
 }
 
-// NewStore creates a Store backed by the given database.
-func NewStore(db *sql.DB) *Store {
-	return &Store{db: db}
+// NewStore creates a Store backed by the given database. historyLimit
+// caps how many non-zero revisions are kept per effect; <= 0 falls back
+// to DefaultHistoryLimit.
+func NewStore(db *sql.DB, historyLimit int) *Store {
+	if historyLimit <= 0 {
+		historyLimit = DefaultHistoryLimit
+	}
+	return &Store{db: db, historyLimit: historyLimit}
 }
 
 // List returns all transition effects, optionally filtered by direction ("in" or "out").
@@ -27,11 +40,11 @@ func (s *Store) List(direction string) ([]models.TransitionEffect, error) {
 	var err error
 	if direction != "" {
 		rows, err = s.db.Query(
-			"SELECT id, name, direction, css, enabled, is_seed FROM transition_effects WHERE direction = ? ORDER BY is_seed DESC, id",
+			"SELECT id, name, direction, css, enabled, is_seed, vote_weight, pack_id FROM transition_effects WHERE direction = ? ORDER BY is_seed DESC, id",
 			direction,
 		)
 	} else {
-		rows, err = s.db.Query("SELECT id, name, direction, css, enabled, is_seed FROM transition_effects ORDER BY is_seed DESC, id")
+		rows, err = s.db.Query("SELECT id, name, direction, css, enabled, is_seed, vote_weight, pack_id FROM transition_effects ORDER BY is_seed DESC, id")
 	}
 	if err != nil {
 		return nil, err
@@ -41,9 +54,11 @@ func (s *Store) List(direction string) ([]models.TransitionEffect, error) {
 	var effects []models.TransitionEffect
 	for rows.Next() {
 		var e models.TransitionEffect
-		if err := rows.Scan(&e.ID, &e.Name, &e.Direction, &e.CSS, &e.Enabled, &e.IsSeed); err != nil {
+		var packID sql.NullString
+		if err := rows.Scan(&e.ID, &e.Name, &e.Direction, &e.CSS, &e.Enabled, &e.IsSeed, &e.VoteWeight, &packID); err != nil {
 			return nil, err
 		}
+		e.PackID = packID.String
 		effects = append(effects, e)
 	}
 	return effects, rows.Err()
@@ -52,12 +67,14 @@ func (s *Store) List(direction string) ([]models.TransitionEffect, error) {
 // Get returns a single transition effect by ID.
 func (s *Store) Get(id int) (*models.TransitionEffect, error) {
 	var e models.TransitionEffect
+	var packID sql.NullString
 	err := s.db.QueryRow(
-		"SELECT id, name, direction, css, enabled, is_seed FROM transition_effects WHERE id = ?", id,
-	).Scan(&e.ID, &e.Name, &e.Direction, &e.CSS, &e.Enabled, &e.IsSeed)
+		"SELECT id, name, direction, css, enabled, is_seed, vote_weight, pack_id FROM transition_effects WHERE id = ?", id,
+	).Scan(&e.ID, &e.Name, &e.Direction, &e.CSS, &e.Enabled, &e.IsSeed, &e.VoteWeight, &packID)
 	if err != nil {
 		return nil, err
 	}
+	e.PackID = packID.String
 	return &e, nil
 }
 
@@ -71,26 +88,237 @@ func (s *Store) Create(name, direction, css string) (*models.TransitionEffect, e
 		return nil, err
 	}
 	id, _ := res.LastInsertId()
-	return &models.TransitionEffect{ID: int(id), Name: name, Direction: direction, CSS: css, Enabled: true, IsSeed: false}, nil
+	return &models.TransitionEffect{ID: int(id), Name: name, Direction: direction, CSS: css, Enabled: true, IsSeed: false, VoteWeight: 1.0}, nil
+}
+
+// FindByNameDirection returns the transition effect with the given name
+// and direction, or sql.ErrNoRows if none exists. Used by internal/pack
+// to detect a collision when importing a pack — transition effects have
+// no single unique key, so name+direction stands in for one.
+func (s *Store) FindByNameDirection(name, direction string) (*models.TransitionEffect, error) {
+	var id int
+	if err := s.db.QueryRow("SELECT id FROM transition_effects WHERE name = ? AND direction = ?", name, direction).Scan(&id); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
+}
+
+// DeleteByPackID removes every transition effect tagged with packID — the
+// uninstall counterpart to the insert internal/pack.Apply does on
+// import.
+func (s *Store) DeleteByPackID(packID string) error {
+	_, err := s.db.Exec("DELETE FROM transition_effects WHERE pack_id = ?", packID)
+	return err
+}
+
+// SetVoteWeight adjusts id's bias in audience-vote winner selection (see
+// poll.WeightedWinner) so the DJ can make an effect more or less likely to
+// win a tied or close poll without needing more voters.
+func (s *Store) SetVoteWeight(id int, weight float64) error {
+	_, err := s.db.Exec("UPDATE transition_effects SET vote_weight = ? WHERE id = ?", weight, id)
+	return err
+}
+
+// VoteWeight returns id's vote_weight, or 1.0 if id doesn't exist. Shaped
+// to be passed directly as poll.WeightedWinner's weight function.
+func (s *Store) VoteWeight(id int) float64 {
+	var w float64
+	if err := s.db.QueryRow("SELECT vote_weight FROM transition_effects WHERE id = ?", id).Scan(&w); err != nil {
+		return 1.0
+	}
+	return w
+}
+
+// snapshotRevision inserts effect id's current name/direction/css as the
+// next transition_effect_versions row. It must run inside tx so the
+// snapshot and the caller's own mutation commit (or roll back) together.
+func snapshotRevision(tx *sql.Tx, id int, note string) error {
+	var name, direction, css string
+	if err := tx.QueryRow("SELECT name, direction, css FROM transition_effects WHERE id = ?", id).Scan(&name, &direction, &css); err != nil {
+		return err
+	}
+
+	var nextRev int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(revision), -1) + 1 FROM transition_effect_versions WHERE element_id = ?", id).Scan(&nextRev); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO transition_effect_versions (element_id, revision, name, direction, css, note) VALUES (?, ?, ?, ?, ?, ?)",
+		id, nextRev, name, direction, css, note,
+	)
+	return err
+}
+
+// pruneRevisions keeps at most limit rows per effect, oldest first,
+// never touching revision 0 — the seed/creation snapshot Revert(id, 0)
+// depends on must never age out.
+func pruneRevisions(tx *sql.Tx, id, limit int) error {
+	_, err := tx.Exec(
+		`DELETE FROM transition_effect_versions
+		 WHERE element_id = ? AND revision != 0 AND revision NOT IN (
+		   SELECT revision FROM transition_effect_versions
+		   WHERE element_id = ? AND revision != 0
+		   ORDER BY revision DESC LIMIT ?
+		 )`,
+		id, id, limit,
+	)
+	return err
 }
 
 // Update modifies an existing transition effect.
 func (s *Store) Update(id int, name, direction, css string) error {
-	_, err := s.db.Exec(
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := snapshotRevision(tx, id, ""); err != nil {
+		return err
+	}
+	if err := pruneRevisions(tx, id, s.historyLimit); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
 		"UPDATE transition_effects SET name = ?, direction = ?, css = ? WHERE id = ?",
 		name, direction, css, id,
-	)
-	return err
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // SetEnabled toggles the enabled state of a transition effect.
 func (s *Store) SetEnabled(id int, enabled bool) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := snapshotRevision(tx, id, ""); err != nil {
+		return err
+	}
+	if err := pruneRevisions(tx, id, s.historyLimit); err != nil {
+		return err
+	}
+
 	v := 0
 	if enabled {
 		v = 1
 	}
-	_, err := s.db.Exec("UPDATE transition_effects SET enabled = ? WHERE id = ?", v, id)
-	return err
+	if _, err := tx.Exec("UPDATE transition_effects SET enabled = ? WHERE id = ?", v, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// History returns id's saved revisions, most recent first. Revision 0 is
+// always the effect's seed or pack-import snapshot.
+func (s *Store) History(id int) ([]models.TransitionRevision, error) {
+	rows, err := s.db.Query(
+		"SELECT revision, name, direction, css, created_at, note FROM transition_effect_versions WHERE element_id = ? ORDER BY revision DESC",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.TransitionRevision
+	for rows.Next() {
+		var r models.TransitionRevision
+		if err := rows.Scan(&r.Revision, &r.Name, &r.Direction, &r.CSS, &r.CreatedAt, &r.Note); err != nil {
+			return nil, err
+		}
+		history = append(history, r)
+	}
+	return history, rows.Err()
+}
+
+// revisionAt resolves a diffable snapshot for effect id at revision —
+// either a stored transition_effect_versions row, or, when revision is
+// one past the last stored row, the effect's current live values (the
+// row most recently written is never snapshotted until the *next*
+// Update/SetEnabled call).
+func (s *Store) revisionAt(id, revision int) (*models.TransitionRevision, error) {
+	var r models.TransitionRevision
+	err := s.db.QueryRow(
+		"SELECT revision, name, direction, css FROM transition_effect_versions WHERE element_id = ? AND revision = ?",
+		id, revision,
+	).Scan(&r.Revision, &r.Name, &r.Direction, &r.CSS)
+	if err == nil {
+		return &r, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	e, getErr := s.Get(id)
+	if getErr != nil {
+		return nil, getErr
+	}
+	var maxRev int
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(revision), -1) FROM transition_effect_versions WHERE element_id = ?", id).Scan(&maxRev); err != nil {
+		return nil, err
+	}
+	if revision != maxRev+1 {
+		return nil, sql.ErrNoRows
+	}
+	return &models.TransitionRevision{Revision: revision, Name: e.Name, Direction: e.Direction, CSS: e.CSS}, nil
+}
+
+// Diff returns a unified diff of fromRev and toRev's CSS for effect id.
+func (s *Store) Diff(id, fromRev, toRev int) (*models.TransitionDiff, error) {
+	from, err := s.revisionAt(id, fromRev)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.revisionAt(id, toRev)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TransitionDiff{
+		CSS: udiff.Unified(fmt.Sprintf("revision %d", fromRev), fmt.Sprintf("revision %d", toRev), from.CSS, to.CSS),
+	}, nil
+}
+
+// Revert restores effect id to a previously saved revision, snapshotting
+// the current state first so the revert itself isn't a dead end.
+func (s *Store) Revert(id, revision int) (*models.TransitionEffect, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var name, direction, css string
+	if err := tx.QueryRow(
+		"SELECT name, direction, css FROM transition_effect_versions WHERE element_id = ? AND revision = ?",
+		id, revision,
+	).Scan(&name, &direction, &css); err != nil {
+		return nil, err
+	}
+
+	if err := snapshotRevision(tx, id, fmt.Sprintf("before revert to revision %d", revision)); err != nil {
+		return nil, err
+	}
+	if err := pruneRevisions(tx, id, s.historyLimit); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE transition_effects SET name = ?, direction = ?, css = ?, enabled = 1 WHERE id = ?",
+		name, direction, css, id,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.Get(id)
 }
 
 // RandomEnabled returns a random enabled effect for the given direction ("in" or "out").
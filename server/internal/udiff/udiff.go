@@ -0,0 +1,111 @@
+// Package udiff produces a unified-diff-style rendering of the change
+// between two strings, line by line. It exists to back
+// overlay.Store.Diff and transitions.Store.Diff's revision comparisons —
+// there's no external diff library available to import here.
+package udiff
+
+import "strings"
+
+// Unified returns a unified-diff rendering of the change from a to b,
+// with fromLabel/toLabel used as the "---"/"+++" headers. It returns ""
+// if a and b are identical. Unlike a textbook unified diff it doesn't
+// collapse unchanged lines into windowed hunks — CSS/HTML/JS bodies are
+// short enough that a whole-file listing is easier to read than several
+// "@@ ... @@" hunks would be.
+func Unified(fromLabel, toLabel, a, b string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+	if !changed(ops) {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("--- " + fromLabel + "\n")
+	sb.WriteString("+++ " + toLabel + "\n")
+	for _, op := range ops {
+		switch op.kind {
+		case opEqual:
+			sb.WriteString("  " + op.line + "\n")
+		case opDelete:
+			sb.WriteString("- " + op.line + "\n")
+		case opInsert:
+			sb.WriteString("+ " + op.line + "\n")
+		}
+	}
+	return sb.String()
+}
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type lineOp struct {
+	kind opKind
+	line string
+}
+
+func changed(ops []lineOp) bool {
+	for _, op := range ops {
+		if op.kind != opEqual {
+			return true
+		}
+	}
+	return false
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffLines walks the standard LCS dynamic-programming table to produce
+// a line-level edit script. O(n*m) time and memory, which is fine for
+// the short CSS/HTML/JS bodies an overlay element or transition effect
+// holds.
+func diffLines(a, b []string) []lineOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []lineOp
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, lineOp{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, lineOp{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, lineOp{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, lineOp{opDelete, a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, lineOp{opInsert, b[j]})
+	}
+	return ops
+}
@@ -0,0 +1,27 @@
+package video
+
+import (
+	"os"
+
+	gomp4 "github.com/abema/go-mp4"
+)
+
+// probeDuration reads an MP4's movie header to get its overall duration
+// in seconds. Returns 0, err if the file can't be parsed; callers treat
+// 0 as "unknown" rather than failing the scan over it.
+func probeDuration(path string) (float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	info, err := gomp4.Probe(f)
+	if err != nil {
+		return 0, err
+	}
+	if info.Timescale == 0 {
+		return 0, nil
+	}
+	return float64(info.Duration) / float64(info.Timescale), nil
+}
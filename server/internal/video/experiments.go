@@ -0,0 +1,104 @@
+package video
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Experimental matcher tuning knobs, overridable via environment
+// variable so an operator can A/B tune matching on a live rig without a
+// rebuild. Each is parsed at first use, logged once at INFO with an
+// "[Experiment]" prefix, and falls back to the corresponding constant in
+// matcher.go if unset or malformed. The constants stay the defaults of
+// record — nothing here changes behaviour unless the env var is set.
+var (
+	matchBPMWeightOnce    sync.Once
+	matchBPMWeightVal     float64
+	fuzzyThresholdOnce    sync.Once
+	fuzzyThresholdVal     float64
+	bpmFuzzyThresholdOnce sync.Once
+	bpmFuzzyThresholdVal  float64
+	halfTimeToleranceOnce sync.Once
+	halfTimeToleranceVal  float64
+	matchTopNOnce         sync.Once
+	matchTopNVal          int
+)
+
+// envFloat parses the env var name as a float64, returning def if it's
+// unset. A set-but-malformed value is logged at WARN and also falls
+// back to def.
+func envFloat(name string, def float64) float64 {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		slog.Warn("[Experiment] ignoring malformed env override", "var", name, "value", raw, "default", def)
+		return def
+	}
+	slog.Info("[Experiment] env override active", "var", name, "value", v)
+	return v
+}
+
+// envInt parses the env var name as an int, returning def if it's unset.
+// A set-but-malformed value is logged at WARN and also falls back to def.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		slog.Warn("[Experiment] ignoring malformed env override", "var", name, "value", raw, "default", def)
+		return def
+	}
+	slog.Info("[Experiment] env override active", "var", name, "value", v)
+	return v
+}
+
+// matchBPMWeight scales bpmDiff's contribution relative to harmonicCost
+// when scoring Level-3/4 BPM candidates. VDJ_MATCH_BPM_WEIGHT, default
+// 1.0 (today's unweighted behaviour).
+func matchBPMWeight() float64 {
+	matchBPMWeightOnce.Do(func() { matchBPMWeightVal = envFloat("VDJ_MATCH_BPM_WEIGHT", 1.0) })
+	return matchBPMWeightVal
+}
+
+// fuzzyThresholdExp overrides fuzzyThreshold (Level 2's minimum filename
+// similarity) via VDJ_FUZZY_THRESHOLD.
+func fuzzyThresholdExp() float64 {
+	fuzzyThresholdOnce.Do(func() { fuzzyThresholdVal = envFloat("VDJ_FUZZY_THRESHOLD", fuzzyThreshold) })
+	return fuzzyThresholdVal
+}
+
+// bpmFuzzyThresholdExp overrides bpmFuzzyThreshold (Level 3's minimum
+// filename similarity) via VDJ_BPM_FUZZY_THRESHOLD.
+func bpmFuzzyThresholdExp() float64 {
+	bpmFuzzyThresholdOnce.Do(func() { bpmFuzzyThresholdVal = envFloat("VDJ_BPM_FUZZY_THRESHOLD", bpmFuzzyThreshold) })
+	return bpmFuzzyThresholdVal
+}
+
+// halfTimeToleranceExp overrides halfTimeTolerance (BPM tolerance for
+// half-time detection) via VDJ_HALFTIME_TOLERANCE.
+func halfTimeToleranceExp() float64 {
+	halfTimeToleranceOnce.Do(func() { halfTimeToleranceVal = envFloat("VDJ_HALFTIME_TOLERANCE", halfTimeTolerance) })
+	return halfTimeToleranceVal
+}
+
+// matchTopN overrides how many closest-BPM candidates Level 3/4 pick
+// randomly among (hardcoded to 5 previously) via VDJ_MATCH_TOP_N. Values
+// below 1 would leave stableIndex nothing to pick from, so they're
+// treated the same as malformed input and fall back to the default.
+func matchTopN() int {
+	matchTopNOnce.Do(func() {
+		matchTopNVal = envInt("VDJ_MATCH_TOP_N", 5)
+		if matchTopNVal < 1 {
+			slog.Warn("[Experiment] ignoring out-of-range env override", "var", "VDJ_MATCH_TOP_N", "value", matchTopNVal, "default", 5)
+			matchTopNVal = 5
+		}
+	})
+	return matchTopNVal
+}
@@ -3,18 +3,26 @@ package video
 import (
 	"context"
 	"hash/fnv"
+	"io/fs"
 	"log/slog"
 	"math"
 	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+
 	"github.com/jota2rz/vdj-video-sync/server/internal/bpm"
+	"github.com/jota2rz/vdj-video-sync/server/internal/loudness"
 	"github.com/jota2rz/vdj-video-sync/server/internal/models"
+	"github.com/jota2rz/vdj-video-sync/server/internal/musickey"
+	"github.com/jota2rz/vdj-video-sync/server/internal/phash"
+	"github.com/jota2rz/vdj-video-sync/server/internal/tagreader"
 )
 
 // Supported video file extensions.
@@ -22,14 +30,15 @@ var videoExts = map[string]bool{
 	".mp4": true,
 }
 
-// Match level constants (0 = best, 5 = worst).
+// Match level constants (0 = best, 6 = worst).
 const (
 	MatchExact    = 0 // Exact filename (with extension)
 	MatchStem     = 1 // Filename without extension
 	MatchFuzzy    = 2 // ≥70% filename similarity
 	MatchBPMFuzzy = 3 // Closest BPM + ≥30% filename similarity
 	MatchBPM      = 4 // Closest BPM (random among ties)
-	MatchRandom   = 5 // Any random video
+	MatchVisual   = 5 // Closest perceptual-hash fingerprint to a reference video for this song
+	MatchRandom   = 6 // Any random video
 )
 
 // Similarity thresholds.
@@ -39,39 +48,156 @@ const (
 	halfTimeTolerance = 3.0  // BPM tolerance for half-time detection
 )
 
+// harmonicStepPenalty is the BPM-equivalent cost added per Camelot-wheel
+// step of harmonic distance (see musickey.Distance) when scoring Level
+// 3/4 BPM candidates against a deck's known key. It only applies when
+// both the deck and the candidate have a known key — most BPM
+// differences between plausible candidates are under a beat or two, so
+// a single incompatible-key step (distance 2) is enough to make an
+// otherwise-closer-BPM candidate lose to a harmonically compatible one.
+const harmonicStepPenalty = 4.0
+
+// VisualMaxHamming is the max Hamming distance (of 64 bits) between two
+// fingerprints for MatchVisual to treat them as the same video. Exported
+// so callers exposing their own "find similar" lookup (e.g.
+// handlers.HandleSimilarVideos) can default to the same threshold
+// instead of hardcoding a second copy of it.
+const VisualMaxHamming = 8
+
+// variantSuffixRe matches a resolution-variant stem such as
+// "track.720p", produced by an offline transcode step and expected to
+// sit alongside the original "track.mp4". Submatch 1 is the base stem,
+// submatch 2 the label ("720p").
+var variantSuffixRe = regexp.MustCompile(`^(.+)\.(\d{3,4}p)$`)
+
+// classifyVariant reports whether stem (a filename without its
+// extension) names a resolution variant, and if so, the base stem it
+// belongs to and its label.
+func classifyVariant(stem string) (baseStem, label string, isVariant bool) {
+	m := variantSuffixRe.FindStringSubmatch(stem)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// scanVariants finds resolution-variant files in dir and groups them by
+// the lowercase base stem they belong to, sorted highest bitrate first.
+// Bitrate is derived from file size and probed duration rather than
+// guessed from the label, since it varies by source encode.
+func scanVariants(dir, pathPrefix string, entries []os.DirEntry) (map[string][]models.Variant, map[string]bool) {
+	byBase := make(map[string][]models.Variant)
+	names := make(map[string]bool)
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if !videoExts[ext] {
+			continue
+		}
+		stem := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		baseStem, label, isVariant := classifyVariant(stem)
+		if !isVariant {
+			continue
+		}
+
+		names[e.Name()] = true
+		absPath := filepath.Join(dir, e.Name())
+		v := models.Variant{Label: label, Path: pathPrefix + e.Name()}
+		if info, err := os.Stat(absPath); err == nil {
+			if d, err := probeDuration(absPath); err == nil && d > 0 {
+				v.BitrateKbps = int(float64(info.Size()) * 8 / d / 1000)
+			}
+		}
+		key := strings.ToLower(baseStem)
+		byBase[key] = append(byBase[key], v)
+	}
+
+	for key, vs := range byBase {
+		sort.Slice(vs, func(i, j int) bool { return vs[i].BitrateKbps > vs[j].BitrateKbps })
+		byBase[key] = vs
+	}
+	return byBase, names
+}
+
 // indexedFile stores a video file with its pre-computed lowercase stem
-// for fast matching. Stems are computed once during scan and reused
-// across all Match() calls.
+// for fast matching, plus its perceptual-hash fingerprint if one could
+// be computed. Stems are computed once during scan and reused across
+// all Match() calls.
 type indexedFile struct {
-	file models.VideoFile
-	stem string // lowercase name without extension
+	file           models.VideoFile
+	stem           string // lowercase name without extension
+	fingerprint    uint64 // pHash fingerprint; only valid if hasFingerprint
+	hasFingerprint bool
 }
 
 // Matcher scans a directory for video files and matches them by
 // filename, similarity, or BPM using a tiered fallback strategy.
 type Matcher struct {
-	dir          string
-	pathPrefix   string
-	bpmCache     *bpm.Cache // optional; nil disables BPM analysis
-	mu           sync.RWMutex
-	indexed      []indexedFile   // pre-computed stems
-	bpmMu        sync.Mutex      // protects bpmCorrected (separate from mu to avoid contention)
-	bpmCorrected map[string]bool // paths whose BPM has been half-time corrected (prevent re-correction)
+	dir           string
+	pathPrefix    string
+	bpmCache      *bpm.Cache            // optional; nil disables BPM analysis
+	loudnessCache *loudness.Cache       // optional; nil disables loudness analysis
+	keyCache      *musickey.Cache       // optional; nil disables musical key analysis
+	phashCache    *phash.Cache          // optional; nil disables perceptual-hash analysis
+	tagReaders    []tagreader.TagReader // consulted in order before falling back to bpmCache/keyCache analysis
+	mu            sync.RWMutex
+	indexed       []indexedFile   // pre-computed stems
+	fpTree        *phash.Tree     // BK-tree over indexed's fingerprints; rebuilt alongside indexed, under mu
+	bpmMu         sync.Mutex      // protects bpmCorrected (separate from mu to avoid contention)
+	bpmCorrected  map[string]bool // paths whose BPM has been half-time corrected (prevent re-correction)
+
+	// visualRef maps a song's lowercase stem to the fingerprint of the
+	// video MatchVisual last resolved it to, so a later call with no
+	// filename/BPM signal can still find its way back to the same video.
+	// Protected separately from mu (like bpmCorrected) to avoid
+	// contention with the read-heavy indexed/fpTree path.
+	visualRefMu sync.Mutex
+	visualRef   map[string]uint64
+
+	// dirChanged notifies a running Watch goroutine that SetDir moved the
+	// watched directory, so it can relocate its fsnotify watches. Buffered
+	// by 1 so SetDir never blocks on a slow or absent watcher.
+	dirChanged chan struct{}
 }
 
 // NewMatcher creates a Matcher for the given directory.
 // pathPrefix is prepended to filenames in the served path (e.g. "/videos/").
-// bpmCache is optional (pass nil to skip audio BPM analysis).
+// bpmCache, loudnessCache, keyCache, and phashCache are each optional
+// (pass nil to skip that analysis pass). readers are consulted in the
+// given priority order to resolve BPM/key/genre before falling back to
+// bpmCache/keyCache's own audio-analysis passes — pass none to rely
+// entirely on that fallback, or register additional backends (e.g. a new
+// tag source) without changing the matcher itself.
 // The matcher starts empty — call Scan() to populate it.
-func NewMatcher(dir string, pathPrefix string, bpmCache *bpm.Cache) *Matcher {
-	return &Matcher{dir: dir, pathPrefix: pathPrefix, bpmCache: bpmCache, bpmCorrected: make(map[string]bool)}
+func NewMatcher(dir string, pathPrefix string, bpmCache *bpm.Cache, loudnessCache *loudness.Cache, keyCache *musickey.Cache, phashCache *phash.Cache, readers ...tagreader.TagReader) *Matcher {
+	return &Matcher{
+		dir:           dir,
+		pathPrefix:    pathPrefix,
+		bpmCache:      bpmCache,
+		loudnessCache: loudnessCache,
+		keyCache:      keyCache,
+		phashCache:    phashCache,
+		tagReaders:    readers,
+		bpmCorrected:  make(map[string]bool),
+		visualRef:     make(map[string]uint64),
+		dirChanged:    make(chan struct{}, 1),
+	}
 }
 
-// SetDir updates the directory to scan for videos.
+// SetDir updates the directory to scan for videos. If Watch is running,
+// it relocates its fsnotify watches to the new directory atomically.
 func (m *Matcher) SetDir(dir string) {
 	m.mu.Lock()
 	m.dir = dir
 	m.mu.Unlock()
+
+	select {
+	case m.dirChanged <- struct{}{}:
+	default:
+	}
 }
 
 // Dir returns the current video directory.
@@ -95,6 +221,7 @@ func (m *Matcher) Scan() {
 	}
 
 	indexed := make([]indexedFile, 0, len(entries))
+	variantsByBase, variantNames := scanVariants(dir, m.pathPrefix, entries)
 
 	for _, e := range entries {
 		if e.IsDir() {
@@ -104,29 +231,83 @@ func (m *Matcher) Scan() {
 		if !videoExts[ext] {
 			continue
 		}
+		if variantNames[e.Name()] {
+			continue // indexed as a Variant of its base file below, not standalone
+		}
 
 		vf := models.VideoFile{
 			Name: e.Name(),
 			Path: m.pathPrefix + e.Name(),
 		}
 
-		// Try filename-based BPM first (e.g. "track_128bpm.mp4")
-		vf.BPM = parseBPMFromName(e.Name())
+		// Resolve BPM/key/genre from filename and embedded tags first —
+		// far cheaper than decoding audio or running keyfinder-cli.
+		if info, err := e.Info(); err == nil {
+			tags := m.resolveTags(filepath.Join(dir, e.Name()), info.ModTime().Unix())
+			vf.BPM = tags.BPM
+			vf.Key = tags.Key
+			vf.Genre = tags.Genre
+		}
 
-		// If no filename BPM and we have a cache, try audio analysis (MP4 only)
+		// If tags didn't resolve a BPM and we have a cache, fall back to
+		// audio analysis (MP4 only)
+		var bpmResult bpm.AnalyseResult
 		if vf.BPM <= 0 && m.bpmCache != nil && ext == ".mp4" {
 			absPath := filepath.Join(dir, e.Name())
-			vf.BPM = m.analyseBPM(absPath, e)
+			bpmResult = m.analyseBPM(absPath, e)
+			vf.BPM = bpmResult.BPM
+			vf.FirstBeatSec = bpmResult.FirstBeatSec
+		}
+		if ext == ".mp4" {
+			absPath := filepath.Join(dir, e.Name())
+			if d, err := probeDuration(absPath); err == nil {
+				vf.Duration = d
+			}
+		}
+		if m.loudnessCache != nil && ext == ".mp4" {
+			absPath := filepath.Join(dir, e.Name())
+			vf.GainDB, vf.Peak = m.analyseLoudness(absPath, e)
+			if vf.GainDB == 0 && vf.Peak == 0 {
+				if info, err := e.Info(); err == nil {
+					if gainDB, peak, ok := m.loudnessFromBPM(absPath, e.Name(), info.ModTime().Unix(), bpmResult); ok {
+						vf.GainDB, vf.Peak = gainDB, peak
+					}
+				}
+			}
+		}
+		if vf.Key == "" && m.keyCache != nil && ext == ".mp4" {
+			absPath := filepath.Join(dir, e.Name())
+			vf.Key = m.analyseKey(absPath, e)
 		}
 
+		var fingerprint uint64
+		var hasFingerprint bool
+		if m.phashCache != nil && ext == ".mp4" {
+			absPath := filepath.Join(dir, e.Name())
+			fingerprint, hasFingerprint = m.analyseFingerprint(absPath, e)
+		}
+
+		stem := strings.ToLower(strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())))
+		vf.Variants = variantsByBase[stem]
+
 		indexed = append(indexed, indexedFile{
-			file: vf,
-			stem: strings.ToLower(strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))),
+			file:           vf,
+			stem:           stem,
+			fingerprint:    fingerprint,
+			hasFingerprint: hasFingerprint,
 		})
 	}
 
+	// os.ReadDir returns entries in lexicographic order, which orders
+	// "track10.mp4" before "track2.mp4"; re-sort naturally for display.
+	sort.Slice(indexed, func(i, j int) bool {
+		return NaturalLess(indexed[i].file.Name, indexed[j].file.Name)
+	})
+
+	fpTree := buildFPTree(indexed)
 	m.mu.Lock()
 	m.indexed = indexed
+	m.fpTree = fpTree
 	m.mu.Unlock()
 
 	var analysed int
@@ -138,59 +319,273 @@ func (m *Matcher) Scan() {
 	slog.Info("video scan complete", "count", len(indexed), "withBPM", analysed)
 }
 
-// analyseBPM checks the cache for a stored BPM, or runs audio analysis.
-func (m *Matcher) analyseBPM(absPath string, entry os.DirEntry) float64 {
+// analyseLoudness checks the cache for a stored gain/peak pair, or runs
+// EBU R128 analysis. Returns (0, 0) — the "not yet analysed" sentinel
+// used throughout VideoFile — if analysis fails.
+func (m *Matcher) analyseLoudness(absPath string, entry os.DirEntry) (gainDB, peak float64) {
 	info, err := entry.Info()
 	if err != nil {
-		return 0
+		return 0, 0
 	}
 	modTime := info.ModTime().Unix()
 
-	// Check cache first
-	if cached, ok := m.bpmCache.Get(absPath, modTime); ok {
-		slog.Debug("bpm cache hit", "file", entry.Name(), "bpm", cached)
+	if cachedGain, cachedPeak, ok := m.loudnessCache.Get(absPath, modTime); ok {
+		slog.Debug("loudness cache hit", "file", entry.Name(), "gainDb", cachedGain, "peak", cachedPeak)
+		return cachedGain, cachedPeak
+	}
+
+	gainDB, peak, err = loudness.AnalyseFile(absPath)
+	if err != nil {
+		slog.Warn("loudness analysis failed", "file", entry.Name(), "error", err)
+		return 0, 0
+	}
+
+	if err := m.loudnessCache.Set(absPath, modTime, gainDB, peak); err != nil {
+		slog.Warn("loudness cache write failed", "file", entry.Name(), "error", err)
+	}
+	slog.Info("loudness analysed", "file", entry.Name(), "gainDb", gainDB, "peak", peak)
+	return gainDB, peak
+}
+
+// analyseLoudnessDirect checks the cache or runs EBU R128 analysis using
+// a file path, name, and mod time directly (without an os.DirEntry).
+func (m *Matcher) analyseLoudnessDirect(absPath, name string, modTime int64) (gainDB, peak float64) {
+	if cachedGain, cachedPeak, ok := m.loudnessCache.Get(absPath, modTime); ok {
+		slog.Debug("loudness cache hit", "file", name, "gainDb", cachedGain, "peak", cachedPeak)
+		return cachedGain, cachedPeak
+	}
+
+	gainDB, peak, err := loudness.AnalyseFile(absPath)
+	if err != nil {
+		slog.Warn("loudness analysis failed", "file", name, "error", err)
+		return 0, 0
+	}
+
+	if err := m.loudnessCache.Set(absPath, modTime, gainDB, peak); err != nil {
+		slog.Warn("loudness cache write failed", "file", name, "error", err)
+	}
+	slog.Info("loudness analysed", "file", name, "gainDb", gainDB, "peak", peak)
+	return gainDB, peak
+}
+
+// analyseKey checks the cache for a stored Camelot key, or runs keyfinder
+// analysis. Returns "" — the "not yet analysed" sentinel used throughout
+// VideoFile — if analysis fails.
+func (m *Matcher) analyseKey(absPath string, entry os.DirEntry) string {
+	info, err := entry.Info()
+	if err != nil {
+		return ""
+	}
+	modTime := info.ModTime().Unix()
+
+	if cached, ok := m.keyCache.Get(absPath, modTime); ok {
+		slog.Debug("key cache hit", "file", entry.Name(), "key", cached)
+		return cached
+	}
+
+	key, err := musickey.AnalyseFile(absPath)
+	if err != nil {
+		slog.Warn("key analysis failed", "file", entry.Name(), "error", err)
+		return ""
+	}
+
+	if err := m.keyCache.Set(absPath, modTime, key); err != nil {
+		slog.Warn("key cache write failed", "file", entry.Name(), "error", err)
+	}
+	slog.Info("key analysed", "file", entry.Name(), "key", key)
+	return key
+}
+
+// analyseKeyDirect checks the cache or runs keyfinder analysis using a
+// file path, name, and mod time directly (without an os.DirEntry).
+func (m *Matcher) analyseKeyDirect(absPath, name string, modTime int64) string {
+	if cached, ok := m.keyCache.Get(absPath, modTime); ok {
+		slog.Debug("key cache hit", "file", name, "key", cached)
 		return cached
 	}
 
+	key, err := musickey.AnalyseFile(absPath)
+	if err != nil {
+		slog.Warn("key analysis failed", "file", name, "error", err)
+		return ""
+	}
+
+	if err := m.keyCache.Set(absPath, modTime, key); err != nil {
+		slog.Warn("key cache write failed", "file", name, "error", err)
+	}
+	slog.Info("key analysed", "file", name, "key", key)
+	return key
+}
+
+// analyseFingerprint checks the cache for a stored pHash fingerprint, or
+// computes one. ok is false if computation fails (e.g. ffmpeg missing or
+// an unreadable file) — MatchVisual and FindSimilar simply skip videos
+// with no fingerprint rather than treating this as a scan failure.
+func (m *Matcher) analyseFingerprint(absPath string, entry os.DirEntry) (uint64, bool) {
+	info, err := entry.Info()
+	if err != nil {
+		return 0, false
+	}
+	modTime := info.ModTime().Unix()
+
+	if cached, ok := m.phashCache.Get(absPath, modTime); ok {
+		slog.Debug("phash cache hit", "file", entry.Name())
+		return cached, true
+	}
+
+	fp, err := phash.Fingerprint(absPath)
+	if err != nil {
+		slog.Warn("phash analysis failed", "file", entry.Name(), "error", err)
+		return 0, false
+	}
+
+	if err := m.phashCache.Set(absPath, modTime, fp); err != nil {
+		slog.Warn("phash cache write failed", "file", entry.Name(), "error", err)
+	}
+	slog.Info("phash computed", "file", entry.Name())
+	return fp, true
+}
+
+// analyseFingerprintDirect checks the cache or computes a fingerprint
+// using a file path, name, and mod time directly (without an
+// os.DirEntry).
+func (m *Matcher) analyseFingerprintDirect(absPath, name string, modTime int64) (uint64, bool) {
+	if cached, ok := m.phashCache.Get(absPath, modTime); ok {
+		slog.Debug("phash cache hit", "file", name)
+		return cached, true
+	}
+
+	fp, err := phash.Fingerprint(absPath)
+	if err != nil {
+		slog.Warn("phash analysis failed", "file", name, "error", err)
+		return 0, false
+	}
+
+	if err := m.phashCache.Set(absPath, modTime, fp); err != nil {
+		slog.Warn("phash cache write failed", "file", name, "error", err)
+	}
+	slog.Info("phash computed", "file", name)
+	return fp, true
+}
+
+// resolveTags checks bpmCache for an already-resolved genre, then runs
+// m.tagReaders in priority order (typically filename, then MP4-atom,
+// then ID3v2) to fill in whatever's still missing, without decoding
+// audio. A newly resolved genre is persisted back to bpmCache so future
+// lookups can skip straight past the readers that found it. Callers
+// still fall back to bpmCache/keyCache's own audio-analysis passes for
+// whichever fields come back zero — tagReaders never replaces that
+// fallback, it just runs first since it's far cheaper.
+func (m *Matcher) resolveTags(absPath string, modTime int64) tagreader.Tags {
+	var seed tagreader.Tags
+	if m.bpmCache != nil {
+		if genre, ok := m.bpmCache.GetGenre(absPath, modTime); ok {
+			seed.Genre = genre
+		}
+	}
+	tags := tagreader.Resolve(m.tagReaders, absPath, seed)
+	if tags.Genre != "" && tags.Genre != seed.Genre && m.bpmCache != nil {
+		if err := m.bpmCache.SetGenre(absPath, modTime, tags.Genre); err != nil {
+			slog.Warn("genre cache write failed", "file", filepath.Base(absPath), "error", err)
+		}
+	}
+	return tags
+}
+
+// buildFPTree builds a BK-tree over indexed's fingerprints, keyed to the
+// index into indexed itself so a lookup's Match.Value can be used
+// directly as a slice index. Returns nil if no entry has a fingerprint.
+func buildFPTree(indexed []indexedFile) *phash.Tree {
+	var fps []uint64
+	var values []int
+	for i, ix := range indexed {
+		if !ix.hasFingerprint {
+			continue
+		}
+		fps = append(fps, ix.fingerprint)
+		values = append(values, i)
+	}
+	if len(fps) == 0 {
+		return nil
+	}
+	return phash.NewTree(fps, values)
+}
+
+// analyseBPM checks the cache for a stored BPM/confidence/first-beat
+// tempo reading, or runs audio analysis. The returned AnalyseResult's
+// loudness fields are zero on a cache hit (the cache only stores tempo)
+// — callers needing a loudness fallback only get one on a fresh analysis.
+func (m *Matcher) analyseBPM(absPath string, entry os.DirEntry) bpm.AnalyseResult {
+	info, err := entry.Info()
+	if err != nil {
+		return bpm.AnalyseResult{}
+	}
+	modTime := info.ModTime().Unix()
+
+	// Check cache first
+	if bpmVal, confidence, firstBeatSec, ok := m.bpmCache.GetTempo(absPath, modTime); ok {
+		slog.Debug("bpm cache hit", "file", entry.Name(), "bpm", bpmVal)
+		return bpm.AnalyseResult{BPM: bpmVal, Confidence: confidence, FirstBeatSec: firstBeatSec}
+	}
+
 	// Run audio analysis
-	detected, err := bpm.AnalyseFile(absPath)
+	result, err := bpm.AnalyseFile(absPath)
 	if err != nil {
 		slog.Warn("bpm analysis failed", "file", entry.Name(), "error", err)
-		return 0
+		return bpm.AnalyseResult{}
 	}
 
-	if detected > 0 {
+	if result.BPM > 0 {
 		// Store in cache
-		if err := m.bpmCache.Set(absPath, modTime, detected); err != nil {
+		if err := m.bpmCache.SetTempo(absPath, modTime, result.BPM, result.Confidence, result.FirstBeatSec); err != nil {
 			slog.Warn("bpm cache write failed", "file", entry.Name(), "error", err)
 		}
-		slog.Info("bpm detected", "file", entry.Name(), "bpm", detected)
+		slog.Info("bpm detected", "file", entry.Name(), "bpm", result.BPM, "confidence", result.Confidence)
 	}
 
-	return detected
+	return result
 }
 
 // analyseBPMDirect checks the cache or runs audio analysis using a file
 // path, name, and mod time directly (without an os.DirEntry).
-func (m *Matcher) analyseBPMDirect(absPath, name string, modTime int64) float64 {
-	if cached, ok := m.bpmCache.Get(absPath, modTime); ok {
-		slog.Debug("bpm cache hit", "file", name, "bpm", cached)
-		return cached
+func (m *Matcher) analyseBPMDirect(absPath, name string, modTime int64) bpm.AnalyseResult {
+	if bpmVal, confidence, firstBeatSec, ok := m.bpmCache.GetTempo(absPath, modTime); ok {
+		slog.Debug("bpm cache hit", "file", name, "bpm", bpmVal)
+		return bpm.AnalyseResult{BPM: bpmVal, Confidence: confidence, FirstBeatSec: firstBeatSec}
 	}
 
-	detected, err := bpm.AnalyseFile(absPath)
+	result, err := bpm.AnalyseFile(absPath)
 	if err != nil {
 		slog.Warn("bpm analysis failed", "file", name, "error", err)
-		return 0
+		return bpm.AnalyseResult{}
 	}
 
-	if detected > 0 {
-		if err := m.bpmCache.Set(absPath, modTime, detected); err != nil {
+	if result.BPM > 0 {
+		if err := m.bpmCache.SetTempo(absPath, modTime, result.BPM, result.Confidence, result.FirstBeatSec); err != nil {
 			slog.Warn("bpm cache write failed", "file", name, "error", err)
 		}
-		slog.Info("bpm detected", "file", name, "bpm", detected)
+		slog.Info("bpm detected", "file", name, "bpm", result.BPM, "confidence", result.Confidence)
 	}
-	return detected
+	return result
+}
+
+// loudnessFromBPM turns the approximate loudness reading bpm.AnalyseFile
+// produces as a side effect of decoding PCM for BPM detection into a
+// gain/peak pair, and caches it like a normal loudness analysis. Used as
+// a fallback when ffmpeg-based analysis hasn't run or isn't available;
+// internal/loudness's value always wins when present (see its package
+// doc).
+func (m *Matcher) loudnessFromBPM(absPath, name string, modTime int64, result bpm.AnalyseResult) (gainDB, peak float64, ok bool) {
+	if result.IntegratedLUFS == 0 && result.TruePeakDB == 0 {
+		return 0, 0, false
+	}
+	gainDB = loudness.TargetLUFS - result.IntegratedLUFS
+	peak = result.TruePeakDB
+	if err := m.loudnessCache.Set(absPath, modTime, gainDB, peak); err != nil {
+		slog.Warn("loudness cache write failed", "file", name, "error", err)
+	}
+	slog.Info("loudness estimated from bpm decode", "file", name, "gainDb", gainDB, "peak", peak)
+	return gainDB, peak, true
 }
 
 // ── Directory Watching ──────────────────────────────────
@@ -226,31 +621,110 @@ func (m *Matcher) dirSnapshot() (map[string]int64, string) {
 	return snap, dir
 }
 
-// Watch polls the video directory at the given interval and calls onChange
-// whenever files are added, modified, or deleted. Only changed files are
-// processed (incremental scan). Cancel the context to stop watching.
-func (m *Matcher) Watch(ctx context.Context, interval time.Duration, onChange func()) {
-	prev, _ := m.dirSnapshot()
+// addWatches registers an fsnotify watch on dir and every subdirectory
+// beneath it, so renames/creates/deletes nested under the video
+// directory are still observed even though dirSnapshot itself only
+// indexes the top level.
+func addWatches(watcher *fsnotify.Watcher, dir string) {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if werr := watcher.Add(path); werr != nil {
+			slog.Warn("video watch: add failed", "dir", path, "error", werr)
+		}
+		return nil
+	})
+}
+
+// removeWatches tears down every watch currently held by watcher.
+func removeWatches(watcher *fsnotify.Watcher) {
+	for _, path := range watcher.WatchList() {
+		watcher.Remove(path)
+	}
+}
+
+// Watch uses fsnotify to monitor the video directory (recursively) and
+// incrementally reindexes on change, calling onChange afterwards. Events
+// are coalesced with the given debounce so a burst of writes (e.g. a
+// multi-file copy) triggers one reindex rather than one per event.
+// SetDir relocates the watch set atomically — Watch doesn't need to be
+// restarted when the directory changes. Cancel the context to stop
+// watching.
+func (m *Matcher) Watch(ctx context.Context, debounce time.Duration, onChange func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("video watch disabled: fsnotify init failed", "error", err)
+		return
+	}
+	defer watcher.Close()
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	prev, dir := m.dirSnapshot()
+	addWatches(watcher, dir)
+
+	reindex := func(curDir string) {
+		curr, _ := m.dirSnapshot()
+		if curr == nil || snapshotsEqual(prev, curr) {
+			return
+		}
+		m.applyChanges(prev, curr, curDir)
+		prev = curr
+		if onChange != nil {
+			onChange()
+		}
+	}
+
+	var debounceTimer *time.Timer
+	var debounceC <-chan time.Time
+	stopDebounce := func() {
+		if debounceTimer != nil {
+			debounceTimer.Stop()
+			debounceTimer = nil
+			debounceC = nil
+		}
+	}
+	defer stopDebounce()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			curr, dir := m.dirSnapshot()
-			if curr == nil {
-				continue
+
+		case <-m.dirChanged:
+			// SetDir moved us to a new directory — relocate watches and
+			// reindex against it right away.
+			stopDebounce()
+			removeWatches(watcher)
+			prev, dir = m.dirSnapshot()
+			addWatches(watcher, dir)
+			reindex(dir)
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
 			}
-			if !snapshotsEqual(prev, curr) {
-				m.applyChanges(prev, curr, dir)
-				if onChange != nil {
-					onChange()
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watcher.Add(event.Name)
 				}
-				prev = curr
 			}
+			if debounceTimer == nil {
+				debounceTimer = time.NewTimer(debounce)
+				debounceC = debounceTimer.C
+			} else {
+				debounceTimer.Reset(debounce)
+			}
+
+		case <-debounceC:
+			debounceTimer = nil
+			debounceC = nil
+			reindex(dir)
+
+		case werr, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("video watch error", "error", werr)
 		}
 	}
 }
@@ -280,25 +754,74 @@ func (m *Matcher) applyChanges(prev, curr map[string]int64, dir string) {
 		}
 	}
 
-	// Build new indexed entries for added/modified files
+	// Build new indexed entries for added/modified files. Resolution
+	// variants (e.g. "track.720p.mp4") are skipped here — they're not
+	// standalone library entries — and are only reattached to their base
+	// file's Variants on the next full Scan().
 	newEntries := make(map[string]indexedFile, len(added))
 	for _, name := range added {
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		if _, _, isVariant := classifyVariant(stem); isVariant {
+			continue
+		}
+
 		ext := strings.ToLower(filepath.Ext(name))
 		vf := models.VideoFile{
 			Name: name,
 			Path: m.pathPrefix + name,
 		}
-		vf.BPM = parseBPMFromName(name)
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			tags := m.resolveTags(filepath.Join(dir, name), info.ModTime().Unix())
+			vf.BPM = tags.BPM
+			vf.Key = tags.Key
+			vf.Genre = tags.Genre
+		}
+		var bpmResult bpm.AnalyseResult
 		if vf.BPM <= 0 && m.bpmCache != nil && ext == ".mp4" {
 			absPath := filepath.Join(dir, name)
 			info, err := os.Stat(absPath)
 			if err == nil {
-				vf.BPM = m.analyseBPMDirect(absPath, name, info.ModTime().Unix())
+				bpmResult = m.analyseBPMDirect(absPath, name, info.ModTime().Unix())
+				vf.BPM = bpmResult.BPM
+				vf.FirstBeatSec = bpmResult.FirstBeatSec
+			}
+		}
+		if ext == ".mp4" {
+			absPath := filepath.Join(dir, name)
+			if d, err := probeDuration(absPath); err == nil {
+				vf.Duration = d
+			}
+		}
+		if m.loudnessCache != nil && ext == ".mp4" {
+			absPath := filepath.Join(dir, name)
+			if info, err := os.Stat(absPath); err == nil {
+				vf.GainDB, vf.Peak = m.analyseLoudnessDirect(absPath, name, info.ModTime().Unix())
+				if vf.GainDB == 0 && vf.Peak == 0 {
+					if gainDB, peak, ok := m.loudnessFromBPM(absPath, name, info.ModTime().Unix(), bpmResult); ok {
+						vf.GainDB, vf.Peak = gainDB, peak
+					}
+				}
+			}
+		}
+		if vf.Key == "" && m.keyCache != nil && ext == ".mp4" {
+			absPath := filepath.Join(dir, name)
+			if info, err := os.Stat(absPath); err == nil {
+				vf.Key = m.analyseKeyDirect(absPath, name, info.ModTime().Unix())
+			}
+		}
+		var fingerprint uint64
+		var hasFingerprint bool
+		if m.phashCache != nil && ext == ".mp4" {
+			absPath := filepath.Join(dir, name)
+			if info, err := os.Stat(absPath); err == nil {
+				fingerprint, hasFingerprint = m.analyseFingerprintDirect(absPath, name, info.ModTime().Unix())
 			}
 		}
 		newEntries[name] = indexedFile{
-			file: vf,
-			stem: strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name))),
+			file:           vf,
+			stem:           strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name))),
+			fingerprint:    fingerprint,
+			hasFingerprint: hasFingerprint,
 		}
 	}
 
@@ -328,9 +851,10 @@ func (m *Matcher) applyChanges(prev, curr map[string]int64, dir string) {
 	}
 	// Keep sorted by filename for consistent library display
 	sort.Slice(result, func(i, j int) bool {
-		return strings.ToLower(result[i].file.Name) < strings.ToLower(result[j].file.Name)
+		return NaturalLess(result[i].file.Name, result[j].file.Name)
 	})
 	m.indexed = result
+	m.fpTree = buildFPTree(result)
 	m.mu.Unlock()
 
 	slog.Info("incremental scan complete",
@@ -384,16 +908,21 @@ func (m *Matcher) UpdateBPM(videoPath string, newBPM float64) {
 //  0. Exact filename (with extension)
 //  1. Filename stem (without extension)
 //  2. Fuzzy filename (≥70% similarity)
-//  3. Closest BPM + ≥30% filename similarity
-//  4. Closest BPM (random among ties)
-//  5. Any random video
+//  3. Closest BPM + ≥30% filename similarity (optionally weighted by Camelot-key compatibility)
+//  4. Closest BPM (random among ties; optionally weighted by Camelot-key compatibility)
+//  5. Closest perceptual-hash fingerprint to a reference video for this song
+//  6. Any random video
 //
 // Also performs half-time BPM correction: if videoBPM*2 is closer to
 // deckBPM, the video's BPM is updated and persisted.
+// deckKey is the deck's detected Camelot key, if known ("" otherwise);
+// Levels 3/4 use it to favor harmonically compatible candidates (see
+// harmonicStepPenalty) but still match on BPM alone when it's unknown.
 // Pre-computed stems avoid redundant string work on hot-path calls.
-func (m *Matcher) Match(songFilename string, deckBPM float64) (models.VideoFile, bool) {
+func (m *Matcher) Match(songFilename string, deckBPM float64, deckKey string) (result models.VideoFile, ok bool) {
 	m.mu.RLock()
 	indexed := m.indexed // slice header copy; safe for read under RLock
+	fpTree := m.fpTree
 	m.mu.RUnlock()
 
 	if len(indexed) == 0 {
@@ -403,6 +932,15 @@ func (m *Matcher) Match(songFilename string, deckBPM float64) (models.VideoFile,
 	songLower := strings.ToLower(strings.TrimSpace(songFilename))
 	songStem := strings.TrimSuffix(songLower, strings.ToLower(filepath.Ext(songLower)))
 
+	// Whatever tier ends up matching, remember its fingerprint (if any) as
+	// the reference MatchVisual falls back to next time this song shows
+	// up with no usable filename or BPM.
+	defer func() {
+		if ok {
+			m.recordVisualReference(indexed, songStem, result.Path)
+		}
+	}()
+
 	// ── Level 0: Exact filename match ──
 	for _, ix := range indexed {
 		if strings.ToLower(ix.file.Name) == songLower {
@@ -434,7 +972,7 @@ func (m *Matcher) Match(songFilename string, deckBPM float64) (models.VideoFile,
 	bestIdx := -1
 	for i, ix := range indexed {
 		sim := similarity(songStem, ix.stem)
-		if sim >= fuzzyThreshold && sim > bestSim {
+		if sim >= fuzzyThresholdExp() && sim > bestSim {
 			bestSim = sim
 			bestIdx = i
 		}
@@ -463,17 +1001,17 @@ func (m *Matcher) Match(songFilename string, deckBPM float64) (models.VideoFile,
 				continue
 			}
 			sim := similarity(songStem, ix.stem)
-			if sim < bpmFuzzyThreshold {
+			if sim < bpmFuzzyThresholdExp() {
 				continue
 			}
-			diff := bpmDiff(deckBPM, ix.file.BPM)
+			diff := matchBPMWeight()*bpmDiff(deckBPM, ix.file.BPM) + harmonicCost(deckKey, ix.file.Key)
 			candidates = append(candidates, bpmFuzzyCandidate{ix, sim, diff})
 		}
 		if len(candidates) > 0 {
 			sort.Slice(candidates, func(i, j int) bool {
 				return candidates[i].diff < candidates[j].diff
 			})
-			top := 5
+			top := matchTopN()
 			if len(candidates) < top {
 				top = len(candidates)
 			}
@@ -500,14 +1038,14 @@ func (m *Matcher) Match(songFilename string, deckBPM float64) (models.VideoFile,
 			if ix.file.BPM <= 0 {
 				continue
 			}
-			diff := bpmDiff(deckBPM, ix.file.BPM)
+			diff := matchBPMWeight()*bpmDiff(deckBPM, ix.file.BPM) + harmonicCost(deckKey, ix.file.Key)
 			candidates = append(candidates, bpmCandidate{ix, diff})
 		}
 		if len(candidates) > 0 {
 			sort.Slice(candidates, func(i, j int) bool {
 				return candidates[i].diff < candidates[j].diff
 			})
-			top := 5
+			top := matchTopN()
 			if len(candidates) < top {
 				top = len(candidates)
 			}
@@ -520,7 +1058,23 @@ func (m *Matcher) Match(songFilename string, deckBPM float64) (models.VideoFile,
 		}
 	}
 
-	// ── Level 5: Any video (stable pick by song name) ──
+	// ── Level 5: Closest perceptual-hash fingerprint ──
+	// Only applies if this song has a reference fingerprint from a prior
+	// match (see recordVisualReference) and at least one indexed video
+	// has a computed fingerprint to compare it against.
+	if fpTree != nil {
+		if ref, haveRef := m.visualReference(songStem); haveRef {
+			if match, ok := fpTree.Nearest(ref, VisualMaxHamming); ok {
+				v := indexed[match.Value].file
+				v.MatchLevel = MatchVisual
+				v.MatchType = "visual"
+				m.correctHalfTimeBPM(&v, deckBPM)
+				return v, true
+			}
+		}
+	}
+
+	// ── Level 6: Any video (stable pick by song name) ──
 	v := indexed[stableIndex(songLower, len(indexed))].file
 	v.MatchLevel = MatchRandom
 	v.MatchType = "random"
@@ -528,6 +1082,38 @@ func (m *Matcher) Match(songFilename string, deckBPM float64) (models.VideoFile,
 	return v, true
 }
 
+// visualReference returns the fingerprint MatchVisual should compare a
+// song's candidates against: the fingerprint of the video most recently
+// matched to it by any tier (see recordVisualReference). There's no
+// user-mapped override mechanism yet, so this is the only source.
+func (m *Matcher) visualReference(songStem string) (uint64, bool) {
+	m.visualRefMu.Lock()
+	defer m.visualRefMu.Unlock()
+	fp, ok := m.visualRef[songStem]
+	return fp, ok
+}
+
+// recordVisualReference remembers path's fingerprint (if it has one) as
+// songStem's visual reference, so a later call that can't resolve this
+// song by filename or BPM still has something for MatchVisual to work
+// from.
+func (m *Matcher) recordVisualReference(indexed []indexedFile, songStem, path string) {
+	if songStem == "" {
+		return
+	}
+	for _, ix := range indexed {
+		if ix.file.Path == path {
+			if !ix.hasFingerprint {
+				return
+			}
+			m.visualRefMu.Lock()
+			m.visualRef[songStem] = ix.fingerprint
+			m.visualRefMu.Unlock()
+			return
+		}
+	}
+}
+
 // correctHalfTimeBPM checks if the video's BPM is a half-time false positive.
 // If videoBPM*2 is closer to deckBPM than videoBPM itself (within tolerance),
 // it doubles the stored BPM and persists the correction.
@@ -544,7 +1130,7 @@ func (m *Matcher) correctHalfTimeBPM(v *models.VideoFile, deckBPM float64) {
 	}
 	diffDirect := math.Abs(v.BPM - deckBPM)
 	diffDoubled := math.Abs(v.BPM*2 - deckBPM)
-	if diffDoubled < diffDirect && diffDoubled <= halfTimeTolerance {
+	if diffDoubled < diffDirect && diffDoubled <= halfTimeToleranceExp() {
 		newBPM := v.BPM * 2
 		m.bpmCorrected[v.Path] = true
 		m.bpmMu.Unlock()
@@ -556,6 +1142,25 @@ func (m *Matcher) correctHalfTimeBPM(v *models.VideoFile, deckBPM float64) {
 	}
 }
 
+// harmonicCost scores how much a BPM-proximity candidate should be
+// penalised for harmonic incompatibility with deckKey. Returns 0 if
+// either key is unknown or unparseable — harmonic weighting is strictly
+// optional on top of BPM distance, never a hard filter.
+func harmonicCost(deckKey, candidateKey string) float64 {
+	if deckKey == "" || candidateKey == "" {
+		return 0
+	}
+	dk, ok := musickey.Parse(deckKey)
+	if !ok {
+		return 0
+	}
+	ck, ok := musickey.Parse(candidateKey)
+	if !ok {
+		return 0
+	}
+	return harmonicStepPenalty * float64(musickey.Distance(dk, ck))
+}
+
 // bpmDiff returns the BPM distance, accounting for half-time:
 // min(|a-b|, |a-2b|, |2a-b|)
 func bpmDiff(a, b float64) float64 {
@@ -635,6 +1240,45 @@ func (m *Matcher) GetByPath(path string) (models.VideoFile, bool) {
 	return models.VideoFile{}, false
 }
 
+// FindSimilar returns every indexed video other than path itself whose
+// perceptual-hash fingerprint is within maxDist Hamming distance of
+// path's, nearest first — so the UI can list visual near-duplicates
+// (e.g. the same clip re-encoded at a different bitrate) without relying
+// on filename similarity. Returns nil if path isn't indexed or has no
+// computed fingerprint.
+func (m *Matcher) FindSimilar(path string, maxDist int) []models.VideoFile {
+	m.mu.RLock()
+	indexed := m.indexed
+	fpTree := m.fpTree
+	m.mu.RUnlock()
+
+	if fpTree == nil {
+		return nil
+	}
+
+	var ref uint64
+	var haveRef bool
+	for _, ix := range indexed {
+		if ix.file.Path == path && ix.hasFingerprint {
+			ref, haveRef = ix.fingerprint, true
+			break
+		}
+	}
+	if !haveRef {
+		return nil
+	}
+
+	matches := fpTree.FindWithin(ref, maxDist)
+	out := make([]models.VideoFile, 0, len(matches))
+	for _, match := range matches {
+		if indexed[match.Value].file.Path == path {
+			continue // the query video itself always matches at distance 0
+		}
+		out = append(out, indexed[match.Value].file)
+	}
+	return out
+}
+
 // ── String similarity ───────────────────────────────────
 
 // similarity returns a 0-1 similarity score between two strings using
@@ -696,55 +1340,3 @@ func levenshtein(a, b string) int {
 	}
 	return row[lb]
 }
-
-// ── BPM from filename ───────────────────────────────────
-
-// parseBPMFromName tries to extract a BPM value from a filename.
-// Expected format: "Something - 128bpm.mp4" or "track_128bpm.mp4"
-func parseBPMFromName(name string) float64 {
-	lower := strings.ToLower(name)
-	idx := strings.Index(lower, "bpm")
-	if idx <= 0 {
-		return 0
-	}
-
-	// Walk backwards from "bpm" to collect digits and dots
-	numStr := ""
-	for i := idx - 1; i >= 0; i-- {
-		c := lower[i]
-		if (c >= '0' && c <= '9') || c == '.' {
-			numStr = string(c) + numStr
-		} else if len(numStr) > 0 {
-			break
-		}
-	}
-
-	var bpmVal float64
-	if numStr != "" {
-		fmt_scan(numStr, &bpmVal)
-	}
-	return bpmVal
-}
-
-func fmt_scan(s string, v *float64) {
-	var result float64
-	var decimal float64 = 1
-	pastDot := false
-	for _, c := range s {
-		if c == '.' {
-			if pastDot {
-				break // second dot → stop parsing
-			}
-			pastDot = true
-			continue
-		}
-		digit := float64(c - '0')
-		if pastDot {
-			decimal *= 10
-			result += digit / decimal
-		} else {
-			result = result*10 + digit
-		}
-	}
-	*v = result
-}
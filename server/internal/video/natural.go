@@ -0,0 +1,46 @@
+package video
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NaturalLess reports whether a sorts before b under natural-order
+// comparison: runs of digits are compared numerically rather than
+// character-by-character, so "track2.mp4" sorts before "track10.mp4".
+// Comparison case-folds both strings first, matching the rest of the
+// package's filename handling (see strings.ToLower use elsewhere in
+// Matcher). Leading zeros don't affect ordering ("track02" sorts the
+// same as "track2"), though the two remain distinct strings otherwise.
+func NaturalLess(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		ca, cb := ra[i], rb[j]
+		if unicode.IsDigit(ca) && unicode.IsDigit(cb) {
+			starta, startb := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			numA := strings.TrimLeft(string(ra[starta:i]), "0")
+			numB := strings.TrimLeft(string(rb[startb:j]), "0")
+			if len(numA) != len(numB) {
+				return len(numA) < len(numB)
+			}
+			if numA != numB {
+				return numA < numB
+			}
+			continue
+		}
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	return len(ra)-i < len(rb)-j
+}
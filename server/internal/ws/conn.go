@@ -0,0 +1,190 @@
+// Package ws implements just enough of RFC 6455 to run this project's
+// small, broadcast-style WebSocket endpoints (audience poll tallies,
+// overlay live-reload): a plain handshake plus unfragmented text frames.
+// It does not support extensions, compression, or fragmented messages —
+// none of our endpoints need them, and a server that only ever pushes
+// small JSON snapshots doesn't need a general-purpose client.
+package ws
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// acceptGUID is the fixed key defined by RFC 6455 §1.3 for deriving
+// Sec-WebSocket-Accept from the client's Sec-WebSocket-Key.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// maxFrameSize bounds how large a single frame's claimed payload length
+// may be. Our endpoints (audience poll votes, overlay live-reload) only
+// ever need small JSON payloads; without this cap, a client could send
+// one frame with the 127-length form claiming e.g. 2^40 bytes and have
+// the server try to allocate it, which is an easy unauthenticated DoS
+// against /ws/poll. Mirrors ctlsocket's own readFrame cap.
+const maxFrameSize = 1 << 20 // 1 MiB
+
+// Conn is an accepted WebSocket connection.
+type Conn struct {
+	nc net.Conn
+	br *bufio.Reader
+}
+
+// Accept performs the WebSocket opening handshake (RFC 6455 §4) by
+// hijacking the HTTP connection, and returns a Conn ready for
+// ReadMessage/WriteMessage. The caller is responsible for calling Close.
+func Accept(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, errors.New("ws: not a websocket upgrade request")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("ws: missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("ws: hijacking not supported")
+	}
+	nc, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(resp); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return &Conn{nc: nc, br: rw.Reader}, nil
+}
+
+func acceptKey(clientKey string) string {
+	h := sha1.New()
+	h.Write([]byte(clientKey + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// WriteMessage sends data as a single unfragmented text frame.
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode} // FIN set, server frames are never masked
+	n := len(payload)
+	switch {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		var ext [2]byte
+		binary.BigEndian.PutUint16(ext[:], uint16(n))
+		header = append(header, 126)
+		header = append(header, ext[:]...)
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		header = append(header, 127)
+		header = append(header, ext[:]...)
+	}
+	if _, err := c.nc.Write(header); err != nil {
+		return err
+	}
+	_, err := c.nc.Write(payload)
+	return err
+}
+
+// ReadMessage blocks for the next text frame, answering pings with pongs
+// and unmasking client frames (required of servers by RFC 6455 §5.3)
+// transparently. It returns io.EOF once a close frame is received or the
+// connection drops.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	for {
+		opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opText:
+			return payload, nil
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+		case opClose:
+			c.writeFrame(opClose, nil)
+			return nil, io.EOF
+		}
+	}
+}
+
+func (c *Conn) readFrame() (opcode byte, payload []byte, err error) {
+	head := make([]byte, 2)
+	if _, err = io.ReadFull(c.br, head); err != nil {
+		return 0, nil, err
+	}
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(c.br, ext); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(c.br, ext); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > maxFrameSize {
+		return 0, nil, fmt.Errorf("ws: frame too large (%d bytes)", length)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
+
+// Close closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.nc.Close()
+}
@@ -3,21 +3,38 @@ package main
 import (
 	"context"
 	"flag"
-	"log/slog"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/jota2rz/vdj-video-sync/server/internal/bpm"
 	"github.com/jota2rz/vdj-video-sync/server/internal/browser"
 	"github.com/jota2rz/vdj-video-sync/server/internal/config"
+	"github.com/jota2rz/vdj-video-sync/server/internal/ctlsocket"
 	"github.com/jota2rz/vdj-video-sync/server/internal/db"
 	"github.com/jota2rz/vdj-video-sync/server/internal/handlers"
+	"github.com/jota2rz/vdj-video-sync/server/internal/hls"
+	"github.com/jota2rz/vdj-video-sync/server/internal/loudness"
+	"github.com/jota2rz/vdj-video-sync/server/internal/musickey"
+	"github.com/jota2rz/vdj-video-sync/server/internal/output/ndi"
+	"github.com/jota2rz/vdj-video-sync/server/internal/overlay"
+	"github.com/jota2rz/vdj-video-sync/server/internal/phash"
+	"github.com/jota2rz/vdj-video-sync/server/internal/poll"
+	"github.com/jota2rz/vdj-video-sync/server/internal/reconcile"
+	"github.com/jota2rz/vdj-video-sync/server/internal/rtmp"
+	"github.com/jota2rz/vdj-video-sync/server/internal/scanner"
 	"github.com/jota2rz/vdj-video-sync/server/internal/sse"
+	"github.com/jota2rz/vdj-video-sync/server/internal/stream"
+	"github.com/jota2rz/vdj-video-sync/server/internal/tagreader"
+	"github.com/jota2rz/vdj-video-sync/server/internal/transitions"
 	"github.com/jota2rz/vdj-video-sync/server/internal/video"
 )
 
@@ -28,6 +45,7 @@ func main() {
 	videosDir := flag.String("videos", "./videos", "Directory containing video files")
 	debug := flag.Bool("debug", false, "Enable debug logging")
 	noBrowser := flag.Bool("no-browser", false, "Do not open the dashboard in a browser on startup")
+	rtmpAddr := flag.String("rtmp", ":1935", "RTMP ingest listen address for live-mixed publishers (VDJ broadcaster, OBS, ...); empty to disable")
 	flag.Parse()
 
 	// ── Logger ──────────────────────────────────────────
@@ -49,23 +67,60 @@ func main() {
 	// ── Config ──────────────────────────────────────────
 	cfg := config.New(database)
 
+	// browser_command/browser_disabled let an operator override how the
+	// dashboard is opened (wslview on WSL, `code --openExternal` over
+	// SSH, `open -a "Google Chrome"` pinning a browser on macOS) without
+	// patching the binary, same as $VDJ_BROWSER but settable at runtime
+	// via POST /api/config instead of the process environment.
+	browser.SetConfig(browser.Config{
+		Command:  strings.Fields(cfg.Get("browser_command", "")),
+		Disabled: cfg.Get("browser_disabled", "0") == "1",
+	})
+
 	// ── SSE Hub ─────────────────────────────────────────
 	hub := sse.NewHub()
 	go hub.Run()
 
-	// ── BPM Analysis Cache ────────────────────────────────
+	// ── BPM / Loudness / Key / Fingerprint Analysis Caches ────
 	bpmCache := bpm.NewCache(database)
+	loudnessCache := loudness.NewCache(database)
+	keyCache := musickey.NewCache(database)
+	phashCache := phash.NewCache(database)
 
 	// ── Video Matchers (deferred scan — will run after server starts) ──
+	// Tag readers run cheapest-first: filename convention, then embedded
+	// MP4/ID3v2 metadata, before the matcher's own bpmCache/keyCache fall
+	// back to decoding audio.
+	tagReaders := []tagreader.TagReader{
+		tagreader.FilenameReader{},
+		tagreader.MP4AtomReader{},
+		tagreader.ID3Reader{},
+	}
+
 	vDir := cfg.Get("videos_dir", *videosDir)
-	matcher := video.NewMatcher(vDir, "/videos/", bpmCache)
+	matcher := video.NewMatcher(vDir, "/videos/", bpmCache, loudnessCache, keyCache, phashCache, tagReaders...)
 
 	tDir := cfg.Get("transition_videos_dir", "./transition-videos")
-	transitionMatcher := video.NewMatcher(tDir, "/transition-videos/", bpmCache)
+	transitionMatcher := video.NewMatcher(tDir, "/transition-videos/", bpmCache, loudnessCache, keyCache, phashCache, tagReaders...)
 
 	// ── Routes ──────────────────────────────────────────
 	mux := http.NewServeMux()
-	h := handlers.New(cfg, hub, matcher, transitionMatcher)
+	historyLimit, err := strconv.Atoi(cfg.Get("overlay_history_limit", strconv.Itoa(overlay.DefaultHistoryLimit)))
+	if err != nil || historyLimit <= 0 {
+		historyLimit = overlay.DefaultHistoryLimit
+	}
+	transitionStore := transitions.NewStore(database, historyLimit)
+	hlsMgr := hls.NewManager(filepath.Join(os.TempDir(), "vdj-video-sync-hls"))
+	liveMgr := stream.NewManager()
+	ndiMgr := ndi.NewManager()
+	reconcileStore := reconcile.NewStore(database)
+	pollStore := poll.NewStore(database)
+	overlayStore := overlay.NewStore(database, historyLimit)
+	scannerInstance := scanner.New(bpmCache, func() []string {
+		return []string{cfg.Get("videos_dir", *videosDir), cfg.Get("transition_videos_dir", "./transition-videos")}
+	}, 0)
+	previewHub := overlay.NewHub()
+	h := handlers.New(cfg, hub, matcher, transitionMatcher, transitionStore, hlsMgr, liveMgr, ndiMgr, reconcileStore, pollStore, overlayStore, bpmCache, database, scannerInstance, previewHub)
 
 	// API – receives updates from VDJ plugin
 	mux.HandleFunc("POST /api/deck/update", h.HandleDeckUpdate)
@@ -81,11 +136,91 @@ func main() {
 
 	// Dashboard API
 	mux.HandleFunc("GET /api/config", h.HandleGetConfig)
+	mux.HandleFunc("GET /api/admin/migrations", h.HandleMigrationsStatus)
 	mux.HandleFunc("POST /api/config", h.HandleSetConfig)
 	mux.HandleFunc("GET /api/videos", h.HandleListVideos)
+	mux.HandleFunc("GET /api/videos/similar", h.HandleSimilarVideos)
 	mux.HandleFunc("POST /api/force-video", h.HandleForceVideo)
 	mux.HandleFunc("POST /api/force-deck-video", h.HandleForceDeckVideo)
 	mux.HandleFunc("POST /api/deck/video-ended", h.HandleVideoEnded)
+	mux.HandleFunc("POST /api/ping-ack", h.HandlePingAck)
+
+	// Metadata reconciliation – external track metadata enrichment
+	mux.HandleFunc("GET /api/reconcile/search", h.HandleReconcileSearch)
+	mux.HandleFunc("POST /api/reconcile/accept", h.HandleReconcileAccept)
+
+	// Audience voting – jukebox-style polls on the next transition
+	// effect / overlay theme
+	mux.HandleFunc("GET /ws/poll", h.HandlePollWS)
+	mux.HandleFunc("GET /api/poll/state", h.HandlePollState)
+	mux.HandleFunc("POST /api/poll/vote", h.HandlePollVote)
+	mux.HandleFunc("POST /api/transitions/{id}/vote-weight", h.HandleSetVoteWeight)
+	mux.HandleFunc("GET /api/transitions/{id}/history", h.HandleTransitionHistory)
+	mux.HandleFunc("POST /api/transitions/{id}/revert/{rev}", h.HandleTransitionRevert)
+
+	// Overlay/transition packs – import/export themed bundles (internal/pack)
+	mux.HandleFunc("GET /api/packs/export", h.HandleExportPack)
+	mux.HandleFunc("POST /api/packs/import", h.HandleImportPack)
+	mux.HandleFunc("DELETE /api/packs/{packId}", h.HandleUninstallPack)
+
+	mux.HandleFunc("GET /api/scan/status", h.HandleScanStatus)
+	mux.HandleFunc("POST /api/scan/rescan", h.HandleScanRescan)
+
+	mux.HandleFunc("POST /api/overlay/{id}/preview", h.HandleOverlayPreview)
+	mux.HandleFunc("GET /ws/overlay-preview", h.HandleOverlayPreviewWS)
+	mux.HandleFunc("GET /api/overlay/{id}/history", h.HandleOverlayHistory)
+	mux.HandleFunc("POST /api/overlay/{id}/revert/{rev}", h.HandleOverlayRevert)
+
+	// Manual BPM correction – WebAudio mic-tap escape hatch for half/double-time errors
+	mux.HandleFunc("PUT /api/bpm/override", h.HandleBPMOverride)
+
+	// Live output – HTTP-FLV/RTMP mirror of the active deck's mix
+	mux.HandleFunc("GET /live/stream.flv", h.HandleFLV)
+	mux.HandleFunc("POST /live/rtmp-publish", h.HandleRTMPPublish)
+	mux.HandleFunc("GET /live/streams", h.HandleLiveStreams)
+
+	// Queue API
+	mux.HandleFunc("GET /queue", h.HandleQueueGet)
+	mux.HandleFunc("POST /queue/add", h.HandleQueueAdd)
+	mux.HandleFunc("POST /queue/remove", h.HandleQueueRemove)
+	mux.HandleFunc("POST /queue/jump", h.HandleQueueJump)
+	mux.HandleFunc("POST /queue/shuffle", h.HandleQueueShuffle)
+	mux.HandleFunc("POST /queue/loop", h.HandleQueueLoop)
+
+	// HLS segments – served dynamically per deck from the manager's temp dir
+	mux.HandleFunc("GET /hls/{deck}/{file}", func(w http.ResponseWriter, r *http.Request) {
+		deck := r.PathValue("deck")
+		dir := filepath.Join(hlsMgr.BaseDir(), deck)
+		http.StripPrefix("/hls/"+deck+"/", http.FileServer(http.Dir(dir))).ServeHTTP(w, r)
+	})
+
+	// HLS segments for library files (videos/transition-videos) – segmented
+	// on demand and cached, unlike the live deck mirror above
+	mux.HandleFunc("GET /hls/{kind}/{id}/{file}", func(w http.ResponseWriter, r *http.Request) {
+		kind := r.PathValue("kind")
+		id := r.PathValue("id")
+
+		var m *video.Matcher
+		switch kind {
+		case "videos":
+			m = matcher
+		case "transition-videos":
+			m = transitionMatcher
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		absPath := filepath.Join(m.Dir(), filepath.Base(id))
+		if err := hlsMgr.StartLibrary(kind, id, absPath); err != nil {
+			slog.Warn("hls library segmenting failed", "kind", kind, "id", id, "error", err)
+			http.Error(w, "hls unavailable", http.StatusInternalServerError)
+			return
+		}
+
+		dir := hlsMgr.LibraryDir(kind, id)
+		http.StripPrefix("/hls/"+kind+"/"+id+"/", http.FileServer(http.Dir(dir))).ServeHTTP(w, r)
+	})
 
 	// Graceful shutdown channel (created early so /api/shutdown can use it)
 	done := make(chan os.Signal, 1)
@@ -149,12 +284,38 @@ func main() {
 	// watchCtx is canceled on shutdown to stop directory watchers.
 	watchCtx, watchCancel := context.WithCancel(context.Background())
 
+	// ── Control Socket ────────────────────────────────────
+	// Optional: a Unix domain socket for external controllers. Disabled
+	// unless "ctl_socket" is configured with a path.
+	if sockPath := cfg.Get("ctl_socket", ""); sockPath != "" {
+		ctlSrv := ctlsocket.NewServer(sockPath, h, hub)
+		go func() {
+			if err := ctlSrv.ListenAndServe(watchCtx); err != nil {
+				slog.Error("ctlsocket server error", "error", err)
+			}
+		}()
+	}
+
+	// RTMP ingest – accepts a live-mixed publish (VDJ's broadcaster, OBS,
+	// a hardware mixer) as a virtual deck alongside VDJ's own polling
+	// updates. Disabled if -rtmp is passed an empty address.
+	if *rtmpAddr != "" {
+		rtmpSrv := rtmp.NewServer(*rtmpAddr, h.NewRTMPHandler())
+		go func() {
+			if err := rtmpSrv.ListenAndServe(watchCtx); err != nil {
+				slog.Error("rtmp server error", "error", err)
+			}
+		}()
+	}
+
 	go func() {
 		h.SetAnalysing(true)
 		slog.Info("bpm analysis starting")
 		matcher.Scan()
 		transitionMatcher.Scan()
 		bpmCache.Cleanup()
+		loudnessCache.Cleanup()
+		keyCache.Cleanup()
 		h.SetAnalysing(false)
 		slog.Info("bpm analysis complete")
 
@@ -162,13 +323,19 @@ func main() {
 		h.BroadcastLibraryUpdated("song")
 		h.BroadcastLibraryUpdated("transition")
 
-		// Start directory watchers — poll every 2 seconds for file changes
-		go matcher.Watch(watchCtx, 2*time.Second, func() {
+		// Start directory watchers — fsnotify-driven, debounced so a
+		// burst of filesystem events collapses into one reindex.
+		go matcher.Watch(watchCtx, 500*time.Millisecond, func() {
 			h.BroadcastLibraryUpdated("song")
 		})
-		go transitionMatcher.Watch(watchCtx, 2*time.Second, func() {
+		go transitionMatcher.Watch(watchCtx, 500*time.Millisecond, func() {
 			h.BroadcastLibraryUpdated("transition")
 		})
+
+		// Background scanner keeps warming the video_bpm cache for files
+		// dropped in after this initial pass (see internal/scanner); it
+		// runs its own fsnotify watch independent of matcher.Watch's.
+		go scannerInstance.Run(watchCtx)
 	}()
 
 	<-done